@@ -1,37 +1,79 @@
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"swatantra/core"
 	"swatantra/crypto"
 	"swatantra/mempool"
+	"swatantra/p2p"
 )
 
 type APIServer struct {
 	listenAddr string
 	blockchain *core.Blockchain
 	mempool    *mempool.Mempool
+	p2p        *p2p.Server
+	adminToken string // Kosong berarti endpoint admin hanya bisa diakses dari localhost
 }
 
-func NewAPIServer(listenAddr string, bc *core.Blockchain, mp *mempool.Mempool) *APIServer {
+func NewAPIServer(listenAddr string, bc *core.Blockchain, mp *mempool.Mempool, p2pServer *p2p.Server) *APIServer {
 	return &APIServer{
 		listenAddr: listenAddr,
 		blockchain: bc,
 		mempool:    mp,
+		p2p:        p2pServer,
 	}
 }
 
 func (s *APIServer) Start() error {
 	http.HandleFunc("/utxos/", s.handleGetUTXOs)
 	http.HandleFunc("/tx", s.handlePostTx)
+	http.HandleFunc("/address/", s.handleAddress)
+	http.HandleFunc("/outpoint/", s.handleGetOutpoint)
+	http.HandleFunc("/block/height/", s.handleGetBlockByHeight)
+	http.HandleFunc("/stats/utxocache", s.handleGetUTXOCacheStats)
+	http.HandleFunc("/peers", s.handleGetPeers)
+	http.HandleFunc("/admin/bans", s.adminOnly(s.handleBans))
+	http.HandleFunc("/admin/bans/", s.adminOnly(s.handleUnban))
 	fmt.Printf("API server running on %s\n", s.listenAddr)
 	return http.ListenAndServe(s.listenAddr, nil)
 }
 
+// SetAdminToken mengizinkan akses endpoint admin dari luar localhost dengan
+// header "Authorization: Bearer <token>".
+func (s *APIServer) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// adminOnly membatasi handler ke permintaan dari localhost atau yang membawa
+// admin token yang dikonfigurasi.
+func (s *APIServer) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+				next(w, r)
+				return
+			}
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.adminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			http.Error(w, "Admin endpoints are only available from localhost or with the admin token", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
 func (s *APIServer) handleGetUTXOs(w http.ResponseWriter, r *http.Request) {
 	addressHex := r.URL.Path[len("/utxos/"):]
 	addressBytes, err := hex.DecodeString(addressHex)
@@ -71,3 +113,292 @@ func (s *APIServer) handlePostTx(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Fprintf(w, "Transaction added to mempool")
 }
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// parsePagination membaca parameter query offset dan limit.
+func parsePagination(r *http.Request) (int, int, error) {
+	offset, limit := 0, defaultPageLimit
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", v)
+		}
+		offset = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q", v)
+		}
+		limit = n
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return offset, limit, nil
+}
+
+func parseAddress(addressHex string) (crypto.Address, error) {
+	var address crypto.Address
+	addressBytes, err := hex.DecodeString(addressHex)
+	if err != nil || len(addressBytes) != crypto.AddressLength {
+		return address, fmt.Errorf("invalid address %q", addressHex)
+	}
+	copy(address[:], addressBytes)
+	return address, nil
+}
+
+// handleAddress melayani /address/{addr}/balance, /address/{addr}/utxos dan /address/{addr}/history.
+func (s *APIServer) handleAddress(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/address/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	address, err := parseAddress(parts[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch parts[1] {
+	case "balance":
+		balance, count, err := s.blockchain.GetAddressBalance(address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"address":   address.ToHex(),
+			"balance":   balance,
+			"utxoCount": count,
+		})
+
+	case "utxos":
+		offset, limit, err := parsePagination(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		utxos, total, err := s.blockchain.GetAddressUTXOs(address, offset, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"address": address.ToHex(),
+			"offset":  offset,
+			"limit":   limit,
+			"total":   total,
+			"utxos":   utxos,
+		})
+
+	case "history":
+		offset, limit, err := parsePagination(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		history, total, err := s.blockchain.GetAddressHistory(address, offset, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"address": address.ToHex(),
+			"offset":  offset,
+			"limit":   limit,
+			"total":   total,
+			"history": history,
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleGetOutpoint melayani /outpoint/{txhash}/{index}.
+func (s *APIServer) handleGetOutpoint(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/outpoint/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	hashBytes, err := hex.DecodeString(parts[0])
+	if err != nil || len(hashBytes) != len(crypto.Hash{}) {
+		http.Error(w, "Invalid transaction hash", http.StatusBadRequest)
+		return
+	}
+	var txHash crypto.Hash
+	copy(txHash[:], hashBytes)
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid output index", http.StatusBadRequest)
+		return
+	}
+
+	info, err := s.blockchain.GetOutpoint(txHash, uint32(index))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"txHash":     txHash.ToHex(),
+		"index":      index,
+		"status":     info.Status,
+		"spentIndex": s.blockchain.HasSpentIndex(),
+	}
+	if info.Output != nil {
+		resp["output"] = info.Output
+	}
+	if info.SpentBy != nil {
+		resp["spentBy"] = map[string]interface{}{
+			"txHash":     info.SpentBy.TxHash.ToHex(),
+			"inputIndex": info.SpentBy.InputIndex,
+			"blockHash":  info.SpentBy.BlockHash.ToHex(),
+			"height":     info.SpentBy.Height,
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// handleGetBlockByHeight melayani /block/height/{n} untuk block di main chain.
+func (s *APIServer) handleGetBlockByHeight(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/block/height/"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid block height", http.StatusBadRequest)
+		return
+	}
+
+	block, err := s.blockchain.GetBlockByHeight(uint32(height))
+	if err != nil {
+		if errors.Is(err, core.ErrBlockNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, core.ErrBlockPruned) {
+			http.Error(w, fmt.Sprintf("block %d is no longer available: %v (node keeps blocks above height %d)", height, err, s.blockchain.PrunedHeight()), http.StatusGone)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hash, _ := block.Hash()
+	writeJSON(w, map[string]interface{}{
+		"hash":  hash.ToHex(),
+		"block": block,
+	})
+}
+
+// handleGetUTXOCacheStats melayani GET /stats/utxocache.
+func (s *APIServer) handleGetUTXOCacheStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.blockchain.UTXOCacheStats()
+	if stats == nil {
+		writeJSON(w, map[string]interface{}{"enabled": false})
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"enabled":    true,
+		"hits":       stats.Hits,
+		"misses":     stats.Misses,
+		"hitRate":    stats.HitRate(),
+		"entries":    stats.Entries,
+		"dirty":      stats.Dirty,
+		"maxEntries": stats.MaxEntries,
+		"flushes":    stats.Flushes,
+		"lastFlush":  stats.LastFlush,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleGetPeers melayani GET /peers.
+func (s *APIServer) handleGetPeers(w http.ResponseWriter, r *http.Request) {
+	peers := []map[string]interface{}{}
+	for _, p := range s.p2p.Peers() {
+		peers = append(peers, map[string]interface{}{
+			"addr":           p.Addr,
+			"listenAddr":     p.ListenAddr,
+			"outbound":       p.Outbound,
+			"persistent":     p.Persistent,
+			"connectedSince": p.ConnectedSince,
+			"bytesIn":        p.BytesIn,
+			"bytesOut":       p.BytesOut,
+			"rttMs":          float64(p.RTT.Microseconds()) / 1000,
+			"banScore":       p.BanScore,
+		})
+	}
+	writeJSON(w, peers)
+}
+
+// banRequest adalah body POST /admin/bans. Duration dalam detik; nol memakai
+// p2p.DefaultBanDuration.
+type banRequest struct {
+	IP       string `json:"ip"`
+	Duration int64  `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+// handleBans melayani GET /admin/bans untuk daftar ban dan POST /admin/bans
+// untuk mem-ban IP.
+func (s *APIServer) handleBans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, s.p2p.Bans())
+	case "POST":
+		var req banRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Duration < 0 {
+			http.Error(w, "duration must not be negative", http.StatusBadRequest)
+			return
+		}
+		duration := time.Duration(req.Duration) * time.Second
+		if duration == 0 {
+			duration = p2p.DefaultBanDuration
+		}
+		if req.Reason == "" {
+			req.Reason = "banned by admin"
+		}
+		if err := s.p2p.Ban(req.IP, duration, req.Reason); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"ip":    req.IP,
+			"until": time.Now().Add(duration),
+		})
+	default:
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUnban melayani DELETE /admin/bans/{ip}.
+func (s *APIServer) handleUnban(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Only DELETE method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ip := r.URL.Path[len("/admin/bans/"):]
+	removed, err := s.p2p.Unban(ip)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !removed {
+		http.Error(w, fmt.Sprintf("%s is not banned", ip), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"ip": ip, "removed": true})
+}
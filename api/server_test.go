@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminOnly(t *testing.T) {
+	s := &APIServer{}
+	handler := s.adminOnly(func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(remoteAddr, auth string) int {
+		r := httptest.NewRequest("GET", "/admin/bans", nil)
+		r.RemoteAddr = remoteAddr
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	if code := request("127.0.0.1:5000", ""); code != http.StatusOK {
+		t.Errorf("Localhost request: got status %d, expected 200", code)
+	}
+	if code := request("[::1]:5000", ""); code != http.StatusOK {
+		t.Errorf("IPv6 localhost request: got status %d, expected 200", code)
+	}
+	if code := request("203.0.113.7:5000", ""); code != http.StatusForbidden {
+		t.Errorf("Remote request without a token: got status %d, expected 403", code)
+	}
+	// Tanpa token yang dikonfigurasi, header kosong tidak boleh cocok.
+	if code := request("203.0.113.7:5000", "Bearer "); code != http.StatusForbidden {
+		t.Errorf("Remote request with an empty token: got status %d, expected 403", code)
+	}
+
+	s.SetAdminToken("secret")
+	if code := request("203.0.113.7:5000", "Bearer wrong"); code != http.StatusForbidden {
+		t.Errorf("Remote request with a wrong token: got status %d, expected 403", code)
+	}
+	if code := request("203.0.113.7:5000", "Bearer secret"); code != http.StatusOK {
+		t.Errorf("Remote request with the admin token: got status %d, expected 200", code)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"swatantra/config"
+	"swatantra/core"
+	"swatantra/crypto"
+)
+
+var dumpUTXOCmd = &cobra.Command{
+	Use:   "dump-utxo",
+	Short: "Tulis snapshot UTXO set di height tertentu beserta commitment-nya",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig(cmd)
+		outPath, _ := cmd.Flags().GetString("out")
+
+		store := openStore(cmd)
+		defer store.Close()
+		bc, err := core.NewBlockchainWithOptions(store, cfg.Chain.InitialDifficulty, core.Options{BlocksDir: blocksDir(cmd)})
+		if err != nil {
+			fmt.Println("Error inisialisasi blockchain:", err)
+			os.Exit(1)
+		}
+		defer bc.Close()
+
+		height := bc.Head().Height
+		if cmd.Flags().Changed("height") {
+			height, _ = cmd.Flags().GetUint32("height")
+		}
+
+		f, err := os.Create(outPath)
+		if err != nil {
+			fmt.Println("Error membuat file snapshot:", err)
+			os.Exit(1)
+		}
+		w := bufio.NewWriter(f)
+		params, err := bc.DumpUTXOSnapshot(height, w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Println("Error menulis snapshot:", err)
+			os.Remove(outPath)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Snapshot UTXO ditulis ke %s\n", outPath)
+		fmt.Printf("  height:       %d\n", params.Height)
+		fmt.Printf("  blockHash:    %s\n", params.BlockHash.ToHex())
+		fmt.Printf("  snapshotHash: %s\n", params.SnapshotHash.ToHex())
+	},
+}
+
+var loadUTXOCmd = &cobra.Command{
+	Use:   "load-utxo <snapshot-file>",
+	Short: "Mulai data directory kosong dari snapshot UTXO yang di-pin di parameter chain",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig(cmd)
+		pinned, err := pinnedSnapshots(cfg.Chain.UTXOSnapshots)
+		if err != nil {
+			fmt.Println("Error membaca utxoSnapshots di config:", err)
+			os.Exit(1)
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println("Error membuka file snapshot:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		store := openStore(cmd)
+		defer store.Close()
+		params, err := core.LoadUTXOSnapshot(store, cfg.Chain.InitialDifficulty, bufio.NewReader(f), pinned)
+		if err != nil {
+			fmt.Println("Error memuat snapshot:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Snapshot UTXO di height %d (%s) berhasil dimuat.\n", params.Height, params.BlockHash.ToHex())
+		fmt.Println("Jalankan start-node untuk sinkronisasi; history di bawah snapshot akan divalidasi di background.")
+	},
+}
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Hapus state turunan dan putar ulang semua block tersimpan dari genesis",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig(cmd)
+		store := openStore(cmd)
+		defer store.Close()
+
+		reindex := core.Reindex
+		if chainstateOnly, _ := cmd.Flags().GetBool("reindex-chainstate"); chainstateOnly {
+			reindex = core.ReindexChainState
+		}
+
+		start := time.Now()
+		lastReport := start
+		bc, err := reindex(store, cfg.Chain.InitialDifficulty, chainOptions(cmd, cfg), func(p core.ReindexProgress) {
+			if p.Done == p.Total || time.Since(lastReport) >= 5*time.Second {
+				lastReport = time.Now()
+				fmt.Printf("Reindex: %d/%d block (%.1f%%), height %d\n", p.Done, p.Total, 100*float64(p.Done)/float64(p.Total), p.Height)
+			}
+		})
+		if err != nil {
+			fmt.Println("Error reindex:", err)
+			os.Exit(1)
+		}
+		defer bc.Close()
+
+		head := bc.Head()
+		fmt.Printf("Reindex selesai dalam %s. Head: %s (height %d)\n", time.Since(start).Round(time.Second), head.Hash().ToHex(), head.Height)
+	},
+}
+
+var verifyChainCmd = &cobra.Command{
+	Use:   "verify-chain",
+	Short: "Periksa konsistensi block terakhir main chain (exit 0 = ok, 1 = error, 2 = inkonsisten)",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig(cmd)
+		depth, _ := cmd.Flags().GetUint32("depth")
+		level, _ := cmd.Flags().GetInt("level")
+
+		store := openStore(cmd)
+		bc, err := core.NewBlockchainWithOptions(store, cfg.Chain.InitialDifficulty, chainOptions(cmd, cfg))
+		if err != nil {
+			fmt.Println("Error inisialisasi blockchain:", err)
+			store.Close()
+			os.Exit(1)
+		}
+
+		fmt.Printf("Memeriksa %d block terakhir dari height %d pada level %d...\n", depth, bc.Head().Height, level)
+		checked := 0
+		err = bc.VerifyChain(depth, level, func(height uint32) { checked++ })
+		bc.Close()
+		store.Close()
+
+		var inconsistency *core.InconsistencyError
+		switch {
+		case errors.As(err, &inconsistency):
+			fmt.Println("INKONSISTEN:", inconsistency)
+			os.Exit(2)
+		case err != nil:
+			fmt.Println("Error memeriksa chain:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("OK: %d block diperiksa tanpa inkonsistensi.\n", checked)
+	},
+}
+
+var exportBlocksCmd = &cobra.Command{
+	Use:   "export-blocks",
+	Short: "Tulis block main chain ke file bootstrap (--out - untuk stdout)",
+	Run: func(cmd *cobra.Command, args []string) {
+		outPath, _ := cmd.Flags().GetString("out")
+		var out io.Writer
+		if outPath == "-" {
+			// Semua log dialihkan ke stderr agar stdout hanya berisi stream block
+			out = os.Stdout
+			os.Stdout = os.Stderr
+		}
+
+		cfg := loadConfig(cmd)
+		store := openStore(cmd)
+		defer store.Close()
+		bc, err := core.NewBlockchainWithOptions(store, cfg.Chain.InitialDifficulty, chainOptions(cmd, cfg))
+		if err != nil {
+			fmt.Println("Error inisialisasi blockchain:", err)
+			os.Exit(1)
+		}
+		defer bc.Close()
+
+		from, _ := cmd.Flags().GetUint32("from")
+		to := bc.Head().Height
+		if cmd.Flags().Changed("to") {
+			to, _ = cmd.Flags().GetUint32("to")
+		}
+
+		var f *os.File
+		if out == nil {
+			f, err = os.Create(outPath)
+			if err != nil {
+				fmt.Println("Error membuat file ekspor:", err)
+				os.Exit(1)
+			}
+			out = f
+		}
+		w := bufio.NewWriter(out)
+		n, err := bc.ExportBlocks(from, to, w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if f != nil {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Println("Error mengekspor block:", err)
+			if f != nil {
+				os.Remove(outPath)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("%d block (height %d-%d) diekspor ke %s\n", n, from, to, outPath)
+	},
+}
+
+var importBlocksCmd = &cobra.Command{
+	Use:   "import-blocks <file|->",
+	Short: "Tambahkan block dari file bootstrap dengan validasi penuh (- untuk stdin)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				fmt.Println("Error membuka file impor:", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			in = f
+		}
+
+		cfg := loadConfig(cmd)
+		store := openStore(cmd)
+		defer store.Close()
+		bc, err := core.NewBlockchainWithOptions(store, cfg.Chain.InitialDifficulty, chainOptions(cmd, cfg))
+		if err != nil {
+			fmt.Println("Error inisialisasi blockchain:", err)
+			os.Exit(1)
+		}
+		defer bc.Close()
+
+		lastReport := time.Now()
+		p, err := bc.ImportBlocks(in, func(p core.ImportProgress) {
+			if time.Since(lastReport) >= 5*time.Second {
+				lastReport = time.Now()
+				fmt.Printf("Impor: %d block dibaca, %d ditambahkan, %d sudah ada, height %d\n", p.Read, p.Imported, p.Skipped, p.Height)
+			}
+		})
+		fmt.Printf("Impor: %d block dibaca, %d ditambahkan, %d sudah ada. Head: height %d\n", p.Read, p.Imported, p.Skipped, bc.Head().Height)
+		if err != nil {
+			fmt.Println("Error mengimpor block:", err)
+			fmt.Println("Block yang sudah ditambahkan tetap tersimpan; jalankan ulang dengan file yang sama untuk melanjutkan.")
+			bc.Close()
+			store.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+var repairBlocksCmd = &cobra.Command{
+	Use:   "repair-blocks",
+	Short: "Bangun ulang index lokasi block dengan memindai file blkNNNNN.dat",
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore(cmd)
+		defer store.Close()
+		files, err := core.OpenBlockFiles(blocksDir(cmd), store)
+		if err != nil {
+			fmt.Println("Error membuka file block:", err)
+			os.Exit(1)
+		}
+		defer files.Close()
+
+		n, err := files.Repair()
+		if err != nil {
+			fmt.Println("Error memperbaiki index block:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Index block dibangun ulang: %d block ditemukan di %s\n", n, blocksDir(cmd))
+	},
+}
+
+var invalidateBlockCmd = &cobra.Command{
+	Use:   "invalidate-block <hash>",
+	Short: "Tandai block dan turunannya invalid, lalu pindah ke chain valid dengan work terbesar",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeBlockStatus(cmd, args[0], (*core.Blockchain).InvalidateBlock)
+	},
+}
+
+var reconsiderBlockCmd = &cobra.Command{
+	Use:   "reconsider-block <hash>",
+	Short: "Hapus tanda invalid dari block, ancestor dan turunannya, lalu pilih ulang chain terbaik",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeBlockStatus(cmd, args[0], (*core.Blockchain).ReconsiderBlock)
+	},
+}
+
+// changeBlockStatus membuka chain, menerapkan invalidate atau reconsider pada
+// block dengan hash hex, lalu mencetak head baru.
+func changeBlockStatus(cmd *cobra.Command, hashHex string, apply func(*core.Blockchain, crypto.Hash) error) {
+	hash, err := parseHash(hashHex)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(cmd)
+	store := openStore(cmd)
+	bc, err := core.NewBlockchainWithOptions(store, cfg.Chain.InitialDifficulty, chainOptions(cmd, cfg))
+	if err != nil {
+		fmt.Println("Error inisialisasi blockchain:", err)
+		store.Close()
+		os.Exit(1)
+	}
+
+	err = apply(bc, hash)
+	head := bc.Head()
+	if closeErr := bc.Close(); err == nil {
+		err = closeErr
+	}
+	store.Close()
+	if err != nil {
+		fmt.Println("Error mengubah status block:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Head: %s (height %d)\n", head.Hash().ToHex(), head.Height)
+}
+
+// pinnedSnapshots mengubah daftar snapshot di config menjadi parameter core.
+func pinnedSnapshots(snapshots []config.UTXOSnapshotConfig) ([]core.SnapshotParams, error) {
+	pinned := make([]core.SnapshotParams, 0, len(snapshots))
+	for _, s := range snapshots {
+		blockHash, err := parseHash(s.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		snapshotHash, err := parseHash(s.SnapshotHash)
+		if err != nil {
+			return nil, err
+		}
+		pinned = append(pinned, core.SnapshotParams{Height: s.Height, BlockHash: blockHash, SnapshotHash: snapshotHash})
+	}
+	return pinned, nil
+}
+
+// parseHash mengubah string hex menjadi crypto.Hash.
+func parseHash(s string) (crypto.Hash, error) {
+	var hash crypto.Hash
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(hash) {
+		return hash, fmt.Errorf("invalid hash %q", s)
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+func init() {
+	rootCmd.AddCommand(dumpUTXOCmd)
+	rootCmd.AddCommand(loadUTXOCmd)
+	rootCmd.AddCommand(repairBlocksCmd)
+	rootCmd.AddCommand(reindexCmd)
+	rootCmd.AddCommand(verifyChainCmd)
+	rootCmd.AddCommand(exportBlocksCmd)
+	rootCmd.AddCommand(importBlocksCmd)
+	rootCmd.AddCommand(invalidateBlockCmd)
+	rootCmd.AddCommand(reconsiderBlockCmd)
+
+	dumpUTXOCmd.Flags().String("datadir", "", "Direktori data blockchain (default: ./blockchain_db)")
+	dumpUTXOCmd.Flags().String("config", "./config/config.json", "Path ke file konfigurasi JSON")
+	dumpUTXOCmd.Flags().Uint32("height", 0, "Height snapshot (default: head)")
+	dumpUTXOCmd.Flags().String("out", "utxo-snapshot.dat", "Path file snapshot")
+
+	loadUTXOCmd.Flags().String("datadir", "", "Direktori data blockchain yang masih kosong (default: ./blockchain_db)")
+	loadUTXOCmd.Flags().String("config", "./config/config.json", "Path ke file konfigurasi JSON")
+
+	repairBlocksCmd.Flags().String("datadir", "", "Direktori data blockchain (default: ./blockchain_db)")
+
+	reindexCmd.Flags().String("datadir", "", "Direktori data blockchain (default: ./blockchain_db)")
+	reindexCmd.Flags().String("config", "./config/config.json", "Path ke file konfigurasi JSON")
+	reindexCmd.Flags().Bool("reindex-chainstate", false, "Pertahankan index block dan bangun ulang UTXO set saja")
+	reindexCmd.Flags().Bool("spentindex", false, "Aktifkan spent index selama reindex (override config)")
+
+	verifyChainCmd.Flags().String("datadir", "", "Direktori data blockchain (default: ./blockchain_db)")
+	verifyChainCmd.Flags().String("config", "./config/config.json", "Path ke file konfigurasi JSON")
+	verifyChainCmd.Flags().Uint32("depth", 6, "Jumlah block dari head yang diperiksa, 0 = seluruh chain")
+	exportBlocksCmd.Flags().String("datadir", "", "Direktori data blockchain (default: ./blockchain_db)")
+	exportBlocksCmd.Flags().String("config", "./config/config.json", "Path ke file konfigurasi JSON")
+	exportBlocksCmd.Flags().Uint32("from", 0, "Height block pertama yang diekspor")
+	exportBlocksCmd.Flags().Uint32("to", 0, "Height block terakhir yang diekspor (default: head)")
+	exportBlocksCmd.Flags().String("out", "chain.bin", "Path file ekspor, - untuk stdout")
+
+	importBlocksCmd.Flags().String("datadir", "", "Direktori data blockchain (default: ./blockchain_db)")
+	importBlocksCmd.Flags().String("config", "./config/config.json", "Path ke file konfigurasi JSON")
+
+	for _, c := range []*cobra.Command{invalidateBlockCmd, reconsiderBlockCmd} {
+		c.Flags().String("datadir", "", "Direktori data blockchain (default: ./blockchain_db)")
+		c.Flags().String("config", "./config/config.json", "Path ke file konfigurasi JSON")
+	}
+
+	verifyChainCmd.Flags().Int("level", 3, "Level pemeriksaan 0-4: 0 header, 1 PoW dan merkle root, 2 cumulative work, 3 data undo, 4 disconnect dan reconnect")
+}
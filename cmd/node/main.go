@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"swatantra/api"
@@ -57,29 +62,7 @@ var startNodeCmd = &cobra.Command{
 	Use:   "start-node",
 	Short: "Memulai node Swatantra",
 	Run: func(cmd *cobra.Command, args []string) {
-		configPath, _ := cmd.Flags().GetString("config")
-		cfg, err := config.Load(configPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Printf("Config file '%s' not found, using defaults.\n", configPath)
-				cfg = &config.Config{
-					P2P: config.P2PConfig{
-						ListenAddress: ":3000",
-					},
-					API: config.APIConfig{
-						ListenAddress: ":4000",
-					},
-					Chain: config.ChainConfig{
-						InitialDifficulty: 10,
-						MaxBlockSize:      1048576,
-						MempoolSize:       5000,
-					},
-				}
-			} else {
-				fmt.Println("Error loading config file:", err)
-				os.Exit(1)
-			}
-		}
+		cfg := loadConfig(cmd)
 
 		listenAddr := cfg.P2P.ListenAddress
 		if cmd.Flags().Changed("listen") {
@@ -96,33 +79,84 @@ var startNodeCmd = &cobra.Command{
 			}
 		}
 
-		dataDir, _ := cmd.Flags().GetString("datadir")
-		if dataDir == "" {
-			dataDir = "./blockchain_db" // Default data directory
-		}
-		store, err := storage.NewLevelDBStore(dataDir)
+		store := openStore(cmd)
+
+		bc, err := core.NewBlockchainWithOptions(store, cfg.Chain.InitialDifficulty, chainOptions(cmd, cfg))
 		if err != nil {
-			fmt.Println("Error membuka database:", err)
+			fmt.Println("Error inisialisasi blockchain:", err)
 			os.Exit(1)
 		}
 
-		bc, err := core.NewBlockchain(store, cfg.Chain.InitialDifficulty)
+		addrBook, err := p2p.NewAddrBook(addrBookPath(cmd))
 		if err != nil {
-			fmt.Println("Error inisialisasi blockchain:", err)
+			fmt.Println("Error membaca address book:", err)
+			os.Exit(1)
+		}
+		banList, err := p2p.NewBanList(banListPath(cmd))
+		if err != nil {
+			fmt.Println("Error membaca daftar ban:", err)
 			os.Exit(1)
 		}
 
+		// Tulis cache UTXO dan address book ke disk sebelum keluar
+		go func() {
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+			<-sigCh
+			fmt.Println("Shutting down, flushing UTXO cache...")
+			if err := addrBook.Save(); err != nil {
+				fmt.Println("Error menyimpan address book:", err)
+			}
+			if err := bc.Close(); err != nil {
+				fmt.Println("Error flushing UTXO cache:", err)
+			}
+			store.Close()
+			os.Exit(0)
+		}()
+
+		if bc.NeedsHistory() {
+			// Node dimulai dari snapshot UTXO; validasi history berjalan di background
+			go func() {
+				if err := bc.ValidateSnapshotHistory(time.Second); err != nil {
+					// Jangan melayani peer atau mining di atas UTXO set yang tidak valid
+					fmt.Println("Error validasi history snapshot, node dihentikan:", err)
+					if errors.Is(err, core.ErrSnapshotInvalid) {
+						fmt.Println("Jalankan reindex untuk membangun ulang chainstate dari block yang tersimpan.")
+					}
+					if err := bc.Close(); err != nil {
+						fmt.Println("Error flushing UTXO cache:", err)
+					}
+					store.Close()
+					os.Exit(1)
+				}
+				fmt.Println("Validasi history snapshot UTXO selesai.")
+			}()
+		}
+
 		mp := mempool.NewMempool(bc, cfg.Chain.MempoolSize)
 
-		apiServer := api.NewAPIServer(cfg.API.ListenAddress, bc, mp)
+		magic, err := p2p.NetworkMagic(cfg.P2P.Network)
+		if err != nil {
+			fmt.Println("Error membaca config P2P:", err)
+			os.Exit(1)
+		}
+		server := p2p.NewServerWithOptions(listenAddr, bc, mp, p2p.Options{
+			Magic:           magic,
+			AddrBook:        addrBook,
+			MaxOutbound:     cfg.P2P.MaxOutbound,
+			MaxInbound:      cfg.P2P.MaxInbound,
+			MaxInboundPerIP: cfg.P2P.MaxInboundPerIP,
+			BanList:         banList,
+		})
+
+		apiServer := api.NewAPIServer(cfg.API.ListenAddress, bc, mp, server)
+		apiServer.SetAdminToken(cfg.API.AdminToken)
 		go func() {
 			if err := apiServer.Start(); err != nil {
 				fmt.Println("Error starting API server:", err)
 			}
 		}()
 
-		server := p2p.NewServer(listenAddr, bc, mp)
-
 		go func() {
 			if err := server.Start(); err != nil {
 				fmt.Println("Error memulai server P2P:", err)
@@ -130,13 +164,11 @@ var startNodeCmd = &cobra.Command{
 			}
 		}()
 
-		// Connect ke peers
+		// Peer dari config dijaga terus sebagai peer addnode; peer lain
+		// ditemukan lewat address book
 		for _, peerAddr := range peers {
-			go func(addr string) {
-				if err := server.Connect(addr); err != nil {
-					fmt.Printf("Error terhubung ke peer %s: %v\n", addr, err)
-				}
-			}(peerAddr)
+			addrBook.Add(peerAddr, time.Now())
+			server.AddNode(peerAddr)
 		}
 
 		if shouldMine, _ := cmd.Flags().GetBool("mine"); shouldMine {
@@ -291,6 +323,9 @@ func init() {
 	startNodeCmd.Flags().Bool("mine", false, "Aktifkan mode mining")
 	startNodeCmd.Flags().String("coinbase", "", "Alamat untuk menerima reward mining (default: dari wallet.key)")
 	startNodeCmd.Flags().String("datadir", "", "Direktori untuk menyimpan data blockchain (default: ./blockchain_db)")
+	startNodeCmd.Flags().Bool("spentindex", false, "Aktifkan index outpoint -> transaksi yang menghabiskannya (override config)")
+	startNodeCmd.Flags().Uint32("prune", 0, "Hapus body block yang lebih dari N block di belakang tip, 0 = nonaktif (override config)")
+	startNodeCmd.Flags().Int("utxocache", 0, "Jumlah maksimum entri cache UTXO, 0 = nonaktif (override config)")
 
 	sendTxCmd.Flags().String("to", "", "Alamat penerima")
 	sendTxCmd.Flags().Uint64("amount", 0, "Jumlah yang akan dikirim")
@@ -299,6 +334,109 @@ func init() {
 	sendTxCmd.MarkFlagRequired("amount")
 }
 
+// loadConfig membaca file konfigurasi dari flag --config, atau memakai default jika file tidak ada.
+func loadConfig(cmd *cobra.Command) *config.Config {
+	configPath, _ := cmd.Flags().GetString("config")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("Config file '%s' not found, using defaults.\n", configPath)
+			return config.Default()
+		}
+		fmt.Println("Error loading config file:", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// dataDir mengembalikan direktori data dari flag --datadir.
+func dataDir(cmd *cobra.Command) string {
+	dir, _ := cmd.Flags().GetString("datadir")
+	if dir == "" {
+		dir = "./blockchain_db" // Default data directory
+	}
+	return dir
+}
+
+// blocksDir mengembalikan direktori file block di dalam direktori data.
+func blocksDir(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "blocks")
+}
+
+// addrBookPath mengembalikan path file address book di dalam direktori data.
+func addrBookPath(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "peers.json")
+}
+
+// banListPath mengembalikan path file daftar ban di dalam direktori data.
+func banListPath(cmd *cobra.Command) string {
+	return filepath.Join(dataDir(cmd), "banlist.json")
+}
+
+// chainOptions menyusun core.Options dari config, dengan flag storage yang
+// didefinisikan oleh command sebagai override.
+func chainOptions(cmd *cobra.Command, cfg *config.Config) core.Options {
+	spentIndex := cfg.Storage.SpentIndex
+	if cmd.Flags().Changed("spentindex") {
+		spentIndex, _ = cmd.Flags().GetBool("spentindex")
+	}
+
+	pruneDepth := cfg.Storage.PruneDepth
+	if cmd.Flags().Changed("prune") {
+		pruneDepth, _ = cmd.Flags().GetUint32("prune")
+	}
+
+	utxoCacheSize := cfg.Storage.UTXOCacheSize
+	if cmd.Flags().Changed("utxocache") {
+		utxoCacheSize, _ = cmd.Flags().GetInt("utxocache")
+	}
+
+	checkpoints, assumeValid, err := chainParams(cfg.Chain)
+	if err != nil {
+		fmt.Println("Error membaca parameter chain di config:", err)
+		os.Exit(1)
+	}
+
+	return core.Options{
+		SpentIndex:        spentIndex,
+		PruneDepth:        pruneDepth,
+		UTXOCacheSize:     utxoCacheSize,
+		UTXOFlushInterval: time.Duration(cfg.Storage.UTXOFlushInterval) * time.Second,
+		BlocksDir:         blocksDir(cmd),
+		Checkpoints:       checkpoints,
+		AssumeValid:       assumeValid,
+	}
+}
+
+// chainParams mengubah checkpoint dan hash assume-valid di config menjadi parameter core.
+func chainParams(chain config.ChainConfig) ([]core.Checkpoint, crypto.Hash, error) {
+	checkpoints := make([]core.Checkpoint, 0, len(chain.Checkpoints))
+	for _, c := range chain.Checkpoints {
+		hash, err := parseHash(c.BlockHash)
+		if err != nil {
+			return nil, crypto.Hash{}, fmt.Errorf("checkpoint at height %d: %w", c.Height, err)
+		}
+		checkpoints = append(checkpoints, core.Checkpoint{Height: c.Height, Hash: hash})
+	}
+	var assumeValid crypto.Hash
+	if chain.AssumeValid != "" {
+		var err error
+		if assumeValid, err = parseHash(chain.AssumeValid); err != nil {
+			return nil, crypto.Hash{}, fmt.Errorf("assumeValid: %w", err)
+		}
+	}
+	return checkpoints, assumeValid, nil
+}
+
+// openStore membuka database di direktori dari flag --datadir.
+func openStore(cmd *cobra.Command) *storage.LevelDBStore {
+	store, err := storage.NewLevelDBStore(dataDir(cmd))
+	if err != nil {
+		fmt.Println("Error membuka database:", err)
+		os.Exit(1)
+	}
+	return store
+}
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
@@ -7,13 +7,30 @@ import (
 
 // P2PConfig holds configuration for P2P networking.
 type P2PConfig struct {
-	ListenAddress string   `json:"listenAddress"`
-	InitialPeers  []string `json:"initialPeers"`
+	ListenAddress string `json:"listenAddress"`
+	// InitialPeers are kept connected for the node's lifetime and are
+	// reconnected with exponential backoff when the connection drops.
+	InitialPeers []string `json:"initialPeers"`
+	// Network selects the network magic: "mainnet" (default), "testnet" or
+	// "regtest". Nodes on different networks refuse each other's messages.
+	Network string `json:"network"`
+	// MaxOutbound is the number of outbound connections the node keeps open
+	// using addresses learned from peers. 0 uses the default of 8.
+	MaxOutbound int `json:"maxOutbound"`
+	// MaxInbound is the maximum number of inbound connections. When it is
+	// reached, the least useful inbound peer is evicted. 0 uses the default of 64.
+	MaxInbound int `json:"maxInbound"`
+	// MaxInboundPerIP limits inbound connections from a single IP address.
+	// 0 uses the default of 8.
+	MaxInboundPerIP int `json:"maxInboundPerIP"`
 }
 
 // APIConfig holds configuration for the HTTP API.
 type APIConfig struct {
 	ListenAddress string `json:"listenAddress"`
+	// AdminToken allows the /admin endpoints to be called from other hosts
+	// with "Authorization: Bearer <token>". Empty restricts them to localhost.
+	AdminToken string `json:"adminToken"`
 }
 
 // ChainConfig holds configuration for the blockchain.
@@ -21,13 +38,66 @@ type ChainConfig struct {
 	InitialDifficulty uint32 `json:"initialDifficulty"`
 	MaxBlockSize      int    `json:"maxBlockSize"`
 	MempoolSize       int    `json:"mempoolSize"`
+	// UTXOSnapshots lists the UTXO snapshots a node may be bootstrapped from.
+	UTXOSnapshots []UTXOSnapshotConfig `json:"utxoSnapshots"`
+	// Checkpoints pins main chain block hashes. Blocks conflicting with a
+	// checkpoint, or forking below one the chain has passed, are rejected.
+	Checkpoints []CheckpointConfig `json:"checkpoints"`
+	// AssumeValid is the hash of a block whose ancestors skip signature
+	// verification during sync. Empty verifies every signature.
+	AssumeValid string `json:"assumeValid"`
+}
+
+// CheckpointConfig pins the block hash at a given height.
+type CheckpointConfig struct {
+	Height    uint32 `json:"height"`
+	BlockHash string `json:"blockHash"`
+}
+
+// UTXOSnapshotConfig pins the commitment of a UTXO snapshot at a given height.
+type UTXOSnapshotConfig struct {
+	Height       uint32 `json:"height"`
+	BlockHash    string `json:"blockHash"`
+	SnapshotHash string `json:"snapshotHash"`
+}
+
+// StorageConfig holds configuration for local storage and optional indexes.
+type StorageConfig struct {
+	SpentIndex bool   `json:"spentIndex"`
+	PruneDepth uint32 `json:"pruneDepth"` // 0 disables pruning
+	// UTXOCacheSize is the maximum number of entries in the UTXO cache, 0 disables it.
+	UTXOCacheSize int `json:"utxoCacheSize"`
+	// UTXOFlushInterval is the maximum number of seconds between UTXO cache flushes.
+	UTXOFlushInterval int `json:"utxoFlushInterval"`
 }
 
 // Config is the main configuration structure.
 type Config struct {
-	P2P   P2PConfig   `json:"p2p"`
-	API   APIConfig   `json:"api"`
-	Chain ChainConfig `json:"chain"`
+	P2P     P2PConfig     `json:"p2p"`
+	API     APIConfig     `json:"api"`
+	Chain   ChainConfig   `json:"chain"`
+	Storage StorageConfig `json:"storage"`
+}
+
+// Default returns the configuration used when no config file is found.
+func Default() *Config {
+	return &Config{
+		P2P: P2PConfig{
+			ListenAddress: ":3000",
+		},
+		API: APIConfig{
+			ListenAddress: ":4000",
+		},
+		Chain: ChainConfig{
+			InitialDifficulty: 10,
+			MaxBlockSize:      1048576,
+			MempoolSize:       5000,
+		},
+		Storage: StorageConfig{
+			UTXOCacheSize:     100000,
+			UTXOFlushInterval: 300,
+		},
+	}
 }
 
 // Load loads the configuration from the given file path.
@@ -0,0 +1,305 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+
+	"swatantra/crypto"
+	"swatantra/storage"
+)
+
+var (
+	addrUTXOKeyPrefix    = []byte("a") // 'a' untuk address -> unspent outpoint
+	addrHistoryKeyPrefix = []byte("t") // 't' untuk address -> riwayat transaksi
+
+	// addrIndexBuiltKey menandai bahwa UTXO di address index sudah lengkap.
+	// Database yang dibuat sebelum address index ada tidak memiliki key ini.
+	addrIndexBuiltKey = []byte("addrindexbuilt")
+)
+
+// AddressTx adalah satu entri riwayat transaksi untuk sebuah alamat.
+// Received adalah total output tx ini ke alamat tersebut, Sent adalah total
+// UTXO milik alamat tersebut yang dihabiskan oleh tx ini.
+type AddressTx struct {
+	TxHash    crypto.Hash
+	BlockHash crypto.Hash
+	Height    uint32
+	Received  uint64
+	Sent      uint64
+}
+
+// Encode mengubah AddressTx menjadi slice of bytes.
+func (e *AddressTx) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(e); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode mengubah slice of bytes menjadi AddressTx.
+func (e *AddressTx) Decode(b []byte) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(e)
+}
+
+// AddressIndex memetakan alamat ke outpoint yang belum dihabiskan dan ke
+// riwayat transaksi yang mendanai atau menghabiskan dari alamat tersebut.
+// Index diperbarui setiap kali block di-connect atau di-disconnect.
+type AddressIndex struct {
+	store storage.Store
+}
+
+// NewAddressIndex membuat instance baru dari AddressIndex.
+func NewAddressIndex(s storage.Store) *AddressIndex {
+	return &AddressIndex{store: s}
+}
+
+func getAddrUTXOPrefix(addr crypto.Address) []byte {
+	return append(append([]byte{}, addrUTXOKeyPrefix...), addr[:]...)
+}
+
+func getAddrUTXOKey(addr crypto.Address, hash crypto.Hash, index uint32) []byte {
+	key := getAddrUTXOPrefix(addr)
+	key = append(key, hash[:]...)
+	return binary.BigEndian.AppendUint32(key, index)
+}
+
+func getAddrHistoryPrefix(addr crypto.Address) []byte {
+	return append(append([]byte{}, addrHistoryKeyPrefix...), addr[:]...)
+}
+
+// Height di-encode big-endian sehingga iterasi prefix berurutan dari block terlama.
+func getAddrHistoryKey(addr crypto.Address, height uint32, txHash crypto.Hash) []byte {
+	key := binary.BigEndian.AppendUint32(getAddrHistoryPrefix(addr), height)
+	return append(key, txHash[:]...)
+}
+
+// addressDelta mengumpulkan perubahan per alamat dalam satu transaksi.
+type addressDelta struct {
+	received uint64
+	sent     uint64
+}
+
+// txAddressDeltas menghitung alamat yang disentuh oleh tx beserta jumlah yang
+// diterima dan dihabiskan. spent berisi output yang dihabiskan oleh input tx.
+func txAddressDeltas(tx *Transaction, spent map[outpoint]*TxOutput) map[crypto.Address]*addressDelta {
+	deltas := make(map[crypto.Address]*addressDelta)
+	get := func(addr crypto.Address) *addressDelta {
+		d, ok := deltas[addr]
+		if !ok {
+			d = &addressDelta{}
+			deltas[addr] = d
+		}
+		return d
+	}
+	if !tx.IsCoinbase() {
+		for _, input := range tx.Inputs {
+			if out, ok := spent[outpoint{input.PrevTxHash, input.PrevOutIndex}]; ok {
+				get(out.Address).sent += out.Value
+			}
+		}
+	}
+	for _, output := range tx.Outputs {
+		get(output.Address).received += output.Value
+	}
+	return deltas
+}
+
+// outpoint mengidentifikasi satu output transaksi.
+type outpoint struct {
+	hash  crypto.Hash
+	index uint32
+}
+
+func spentOutputs(undo *BlockUndo) map[outpoint]*TxOutput {
+	spent := make(map[outpoint]*TxOutput, len(undo.SpentUTXOs))
+	for _, s := range undo.SpentUTXOs {
+		spent[outpoint{s.TxHash, s.Index}] = s.Output
+	}
+	return spent
+}
+
+// ConnectBlock menambahkan efek block ke index. undo berisi output yang
+// dihabiskan oleh block, sama seperti yang disimpan untuk rollback.
+func (ai *AddressIndex) ConnectBlock(b *Block, undo *BlockUndo) error {
+	blockHash, _ := b.Hash()
+	spent := spentOutputs(undo)
+
+	for _, s := range undo.SpentUTXOs {
+		if err := ai.store.Delete(getAddrUTXOKey(s.Output.Address, s.TxHash, s.Index)); err != nil {
+			return err
+		}
+	}
+
+	for _, tx := range b.Transactions {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return err
+		}
+		for i, output := range tx.Outputs {
+			encoded, err := output.Encode()
+			if err != nil {
+				return err
+			}
+			if err := ai.store.Put(getAddrUTXOKey(output.Address, txHash, uint32(i)), encoded); err != nil {
+				return err
+			}
+		}
+		for addr, d := range txAddressDeltas(tx, spent) {
+			entry := &AddressTx{
+				TxHash:    txHash,
+				BlockHash: blockHash,
+				Height:    b.Header.Height,
+				Received:  d.received,
+				Sent:      d.sent,
+			}
+			encoded, err := entry.Encode()
+			if err != nil {
+				return err
+			}
+			if err := ai.store.Put(getAddrHistoryKey(addr, b.Header.Height, txHash), encoded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock membatalkan efek block dari index.
+func (ai *AddressIndex) DisconnectBlock(b *Block, undo *BlockUndo) error {
+	spent := spentOutputs(undo)
+
+	for _, tx := range b.Transactions {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return err
+		}
+		for i, output := range tx.Outputs {
+			if err := ai.store.Delete(getAddrUTXOKey(output.Address, txHash, uint32(i))); err != nil {
+				return err
+			}
+		}
+		for addr := range txAddressDeltas(tx, spent) {
+			if err := ai.store.Delete(getAddrHistoryKey(addr, b.Header.Height, txHash)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, s := range undo.SpentUTXOs {
+		encoded, err := s.Output.Encode()
+		if err != nil {
+			return err
+		}
+		if err := ai.store.Put(getAddrUTXOKey(s.Output.Address, s.TxHash, s.Index), encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UTXOs mengembalikan UTXO milik alamat, dimulai dari offset dan paling banyak
+// limit entri (limit <= 0 berarti tanpa batas), beserta jumlah totalnya.
+func (ai *AddressIndex) UTXOs(addr crypto.Address, offset, limit int) ([]*SpentUTXO, int, error) {
+	prefix := getAddrUTXOPrefix(addr)
+	it := ai.store.NewIterator(prefix)
+	defer it.Close()
+
+	utxos := []*SpentUTXO{}
+	total := 0
+	for it.Next() {
+		if total >= offset && (limit <= 0 || len(utxos) < limit) {
+			key := it.Key()
+			output := &TxOutput{}
+			if err := output.Decode(it.Value()); err != nil {
+				return nil, 0, err
+			}
+			var txHash crypto.Hash
+			copy(txHash[:], key[len(prefix):len(prefix)+len(txHash)])
+			utxos = append(utxos, &SpentUTXO{
+				TxHash: txHash,
+				Index:  binary.BigEndian.Uint32(key[len(prefix)+len(txHash):]),
+				Output: output,
+			})
+		}
+		total++
+	}
+	return utxos, total, nil
+}
+
+// Balance mengembalikan total nilai dan jumlah UTXO milik alamat.
+func (ai *AddressIndex) Balance(addr crypto.Address) (uint64, int, error) {
+	it := ai.store.NewIterator(getAddrUTXOPrefix(addr))
+	defer it.Close()
+
+	var balance uint64
+	count := 0
+	for it.Next() {
+		output := &TxOutput{}
+		if err := output.Decode(it.Value()); err != nil {
+			return 0, 0, err
+		}
+		balance += output.Value
+		count++
+	}
+	return balance, count, nil
+}
+
+// History mengembalikan riwayat transaksi alamat, terurut dari height terendah,
+// dimulai dari offset dan paling banyak limit entri, beserta jumlah totalnya.
+func (ai *AddressIndex) History(addr crypto.Address, offset, limit int) ([]*AddressTx, int, error) {
+	it := ai.store.NewIterator(getAddrHistoryPrefix(addr))
+	defer it.Close()
+
+	entries := []*AddressTx{}
+	total := 0
+	for it.Next() {
+		if total >= offset && (limit <= 0 || len(entries) < limit) {
+			entry := &AddressTx{}
+			if err := entry.Decode(it.Value()); err != nil {
+				return nil, 0, err
+			}
+			entries = append(entries, entry)
+		}
+		total++
+	}
+	return entries, total, nil
+}
+
+// ensureAddressIndex mengisi UTXO address index dari UTXO set untuk database
+// yang dibuat sebelum index ini ada. UTXO set di store harus sudah sesuai
+// dengan head. Riwayat transaksi block lama tidak bisa dibangun dari UTXO set;
+// riwayat tersebut hanya tersedia setelah reindex.
+func (bc *Blockchain) ensureAddressIndex() error {
+	if ok, err := bc.addrIndex.store.Has(addrIndexBuiltKey); err != nil || ok {
+		return err
+	}
+	fmt.Println("Building address index from the UTXO set...")
+	keyLen := len(getUTXOKey(crypto.Hash{}, 0))
+	it := bc.store.NewIterator(utxoKeyPrefix)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != keyLen {
+			continue
+		}
+		output := &TxOutput{}
+		if err := output.Decode(it.Value()); err != nil {
+			it.Close()
+			return err
+		}
+		var txHash crypto.Hash
+		copy(txHash[:], key[len(utxoKeyPrefix):])
+		index := binary.BigEndian.Uint32(key[len(utxoKeyPrefix)+len(txHash):])
+		if err := bc.addrIndex.store.Put(getAddrUTXOKey(output.Address, txHash, index), it.Value()); err != nil {
+			it.Close()
+			return err
+		}
+	}
+	it.Close()
+	if err := bc.addrIndex.store.Put(addrIndexBuiltKey, []byte{1}); err != nil {
+		return err
+	}
+	return bc.FlushUTXOs()
+}
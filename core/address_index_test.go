@@ -0,0 +1,123 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"swatantra/crypto"
+)
+
+func TestAddressIndexConnectDisconnect(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+
+	alicePriv, _ := crypto.GeneratePrivateKey()
+	alice := alicePriv.Public().Address()
+	miner := privKey.Public().Address()
+
+	// Genesis mengirim seluruh supply ke alamat nol.
+	balance, count, err := bc.GetAddressBalance(crypto.Address{})
+	if err != nil {
+		t.Fatalf("GetAddressBalance failed: %v", err)
+	}
+	if balance != 1000 || count != 1 {
+		t.Fatalf("Genesis balance: got %d in %d utxos, expected 1000 in 1", balance, count)
+	}
+
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{
+		{Value: 600, Address: alice},
+		{Value: 400, Address: crypto.Address{}},
+	})
+	block := mineTestBlock(t, bc, bc.Head(), miner, []*Transaction{tx})
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+
+	balance, _, _ = bc.GetAddressBalance(alice)
+	if balance != 600 {
+		t.Errorf("Alice balance: got %d, expected 600", balance)
+	}
+	balance, _, _ = bc.GetAddressBalance(crypto.Address{})
+	if balance != 400 {
+		t.Errorf("Genesis address balance: got %d, expected 400", balance)
+	}
+
+	history, total, err := bc.GetAddressHistory(crypto.Address{}, 0, 0)
+	if err != nil {
+		t.Fatalf("GetAddressHistory failed: %v", err)
+	}
+	if total != 2 || len(history) != 2 {
+		t.Fatalf("Genesis address history: got %d entries, expected 2", total)
+	}
+	txHash, _ := tx.Hash()
+	if history[1].TxHash != txHash || history[1].Height != 1 || history[1].Sent != 1000 || history[1].Received != 400 {
+		t.Errorf("Unexpected history entry: %+v", history[1])
+	}
+
+	page, total, _ := bc.GetAddressHistory(crypto.Address{}, 1, 1)
+	if total != 2 || len(page) != 1 || page[0].TxHash != txHash {
+		t.Errorf("Pagination returned %d of %d entries", len(page), total)
+	}
+
+	// Disconnect harus mengembalikan index ke kondisi sebelum block.
+	if err := bc.rollbackUTXOSet(block); err != nil {
+		t.Fatalf("rollbackUTXOSet failed: %v", err)
+	}
+	balance, count, _ = bc.GetAddressBalance(crypto.Address{})
+	if balance != 1000 || count != 1 {
+		t.Errorf("After disconnect: got %d in %d utxos, expected 1000 in 1", balance, count)
+	}
+	if _, total, _ := bc.GetAddressHistory(alice, 0, 0); total != 0 {
+		t.Errorf("After disconnect Alice still has %d history entries", total)
+	}
+}
+
+func TestAddressIndexBuiltForOldDatabase(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := NewBlockchain(store, 10); err != nil {
+		t.Fatalf("NewBlockchain failed: %v", err)
+	}
+	// Database dari sebelum address index ada: tanpa entri index dan penandanya.
+	if err := wipeKeys(store, []derivedKey{{addrUTXOKeyPrefix, len(getAddrUTXOKey(crypto.Address{}, crypto.Hash{}, 0))}}, addrIndexBuiltKey); err != nil {
+		t.Fatalf("wipeKeys failed: %v", err)
+	}
+
+	bc, err := NewBlockchain(store, 10)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	utxos, err := bc.FindUTXOs(crypto.Address{})
+	if err != nil {
+		t.Fatalf("FindUTXOs failed: %v", err)
+	}
+	if len(utxos) != 1 || utxos[0].Output.Value != 1000 {
+		t.Errorf("Expected the genesis output after rebuilding the index, got %d utxos", len(utxos))
+	}
+}
+
+func TestAddressIndexFlushedWithUTXOCache(t *testing.T) {
+	store := newTestStore(t)
+	bc, err := NewBlockchainWithOptions(store, 10, Options{UTXOCacheSize: 1000, UTXOFlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewBlockchainWithOptions failed: %v", err)
+	}
+	alice := crypto.Address{7}
+	block := mineTestBlock(t, bc, bc.Head(), alice, nil)
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	coinbaseHash, _ := block.Transactions[0].Hash()
+	key := getAddrUTXOKey(alice, coinbaseHash, 0)
+
+	if balance, _, _ := bc.GetAddressBalance(alice); balance != 50 {
+		t.Errorf("Unflushed index should be visible, got balance %d", balance)
+	}
+	if ok, _ := store.Has(key); ok {
+		t.Fatal("Address index must not reach the store before the UTXO flush")
+	}
+	if err := bc.FlushUTXOs(); err != nil {
+		t.Fatalf("FlushUTXOs failed: %v", err)
+	}
+	if ok, _ := store.Has(key); !ok {
+		t.Error("Address index not written with the UTXO flush")
+	}
+}
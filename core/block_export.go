@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"swatantra/crypto"
+)
+
+// BlockExportVersion adalah versi format file ekspor block.
+const BlockExportVersion = 1
+
+// maxExportRecordSize membatasi panjang satu record agar file rusak tidak
+// memicu alokasi besar.
+const maxExportRecordSize = 64 << 20
+
+var blockExportMagic = [4]byte{'S', 'W', 'B', 'K'}
+
+// ImportProgress melaporkan kemajuan ImportBlocks setelah setiap block dibaca.
+type ImportProgress struct {
+	Read     int    // Jumlah block yang sudah dibaca dari stream
+	Imported int    // Block yang ditambahkan ke chain
+	Skipped  int    // Block yang sudah ada, misalnya dari impor sebelumnya
+	Height   uint32 // Height block terakhir yang dibaca
+}
+
+// ExportBlocks menulis block main chain dari height from hingga to (inklusif)
+// ke w. Format: magic "SWBK", versi (uint32), hash genesis, lalu setiap block
+// sebagai panjang (uint32 big-endian) diikuti block yang di-encode gob.
+// Mengembalikan jumlah block yang ditulis.
+func (bc *Blockchain) ExportBlocks(from, to uint32, w io.Writer) (int, error) {
+	if from > to {
+		return 0, fmt.Errorf("invalid range: from %d is above to %d", from, to)
+	}
+	if to > bc.head.Height {
+		return 0, fmt.Errorf("height %d is above the chain head %d", to, bc.head.Height)
+	}
+	genesisHash, err := bc.GetHashByHeight(0)
+	if err != nil {
+		return 0, err
+	}
+
+	header := append([]byte{}, blockExportMagic[:]...)
+	header = binary.BigEndian.AppendUint32(header, BlockExportVersion)
+	header = append(header, genesisHash[:]...)
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for height := from; height <= to; height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return written, fmt.Errorf("could not load block at height %d: %w", height, err)
+		}
+		encoded, err := block.Encode()
+		if err != nil {
+			return written, err
+		}
+		if _, err := w.Write(binary.BigEndian.AppendUint32(nil, uint32(len(encoded)))); err != nil {
+			return written, err
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// ImportBlocks membaca stream hasil ExportBlocks dan menambahkan setiap block
+// lewat AddBlock dengan validasi penuh. Block yang sudah tersimpan dilewati,
+// sehingga impor yang terhenti bisa diulang dengan file yang sama.
+func (bc *Blockchain) ImportBlocks(r io.Reader, progress func(ImportProgress)) (ImportProgress, error) {
+	var p ImportProgress
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(blockExportMagic)+4+len(crypto.Hash{}))
+	if _, err := io.ReadFull(br, header); err != nil {
+		return p, fmt.Errorf("could not read export header: %w", err)
+	}
+	if [4]byte(header[0:4]) != blockExportMagic {
+		return p, errors.New("not a block export file")
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != BlockExportVersion {
+		return p, fmt.Errorf("unsupported block export version %d", version)
+	}
+	genesisHash, err := bc.GetHashByHeight(0)
+	if err != nil {
+		return p, err
+	}
+	if crypto.Hash(header[8:]) != genesisHash {
+		return p, fmt.Errorf("export file belongs to a chain with genesis %s", crypto.Hash(header[8:]).ToHex())
+	}
+
+	lenBuf := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(br, lenBuf); err != nil {
+			if err == io.EOF {
+				return p, nil
+			}
+			return p, fmt.Errorf("truncated export file after %d blocks: %w", p.Read, err)
+		}
+		length := binary.BigEndian.Uint32(lenBuf)
+		if length > maxExportRecordSize {
+			return p, fmt.Errorf("block record %d is too large (%d bytes)", p.Read, length)
+		}
+		encoded := make([]byte, length)
+		if _, err := io.ReadFull(br, encoded); err != nil {
+			return p, fmt.Errorf("truncated export file after %d blocks: %w", p.Read, err)
+		}
+		block := new(Block)
+		if err := block.Decode(encoded); err != nil {
+			return p, fmt.Errorf("could not decode block record %d: %w", p.Read, err)
+		}
+		p.Read++
+		p.Height = block.Header.Height
+
+		hash, err := block.Hash()
+		if err != nil {
+			return p, err
+		}
+		known := bc.IsMainChain(hash)
+		if !known {
+			if known, err = bc.blockStore.Has(hash); err != nil {
+				return p, err
+			}
+		}
+		if known {
+			p.Skipped++
+		} else {
+			if err := bc.AddBlock(block); err != nil {
+				return p, fmt.Errorf("block %s at height %d rejected: %w", hash.ToHex(), block.Header.Height, err)
+			}
+			p.Imported++
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+}
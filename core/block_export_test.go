@@ -0,0 +1,58 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportImportBlocks(t *testing.T) {
+	src, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	tx := spendGenesis(t, src, privKey, []*TxOutput{{Value: 1000, Address: miner}})
+	for _, txs := range [][]*Transaction{{tx}, nil, nil, nil} {
+		if err := src.AddBlock(mineTestBlock(t, src, src.Head(), miner, txs)); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	n, err := src.ExportBlocks(0, src.Head().Height, buf)
+	if err != nil || n != int(src.Head().Height)+1 {
+		t.Fatalf("ExportBlocks wrote %d blocks: %v", n, err)
+	}
+	data := buf.Bytes()
+
+	dst, err := NewBlockchain(newTestStore(t), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stream terpotong di tengah block terakhir: block sebelumnya tetap masuk.
+	p, err := dst.ImportBlocks(bytes.NewReader(data[:len(data)-10]), nil)
+	if err == nil {
+		t.Fatal("Expected an error for a truncated stream")
+	}
+	imported := p.Imported
+	if imported != n-2 || dst.Head().Height != uint32(imported) {
+		t.Fatalf("Expected %d blocks before the truncated record, got %+v", n-2, p)
+	}
+
+	// Impor ulang file lengkap melanjutkan dari block terakhir yang masuk.
+	p, err = dst.ImportBlocks(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("ImportBlocks failed: %v", err)
+	}
+	if p.Read != n || p.Imported+imported != n-1 || p.Skipped != 1+imported {
+		t.Errorf("Unexpected progress after resume: %+v (first run imported %d)", p, imported)
+	}
+	if dst.Head().Hash() != src.Head().Hash() {
+		t.Fatal("Imported chain has a different head")
+	}
+
+	other, err := NewBlockchain(newTestStore(t), 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.ImportBlocks(bytes.NewReader(data), nil); err == nil {
+		t.Error("Expected import into a chain with another genesis to fail")
+	}
+}
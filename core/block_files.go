@@ -0,0 +1,445 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"swatantra/crypto"
+	"swatantra/storage"
+)
+
+// DefaultBlockFileSize adalah ukuran maksimum satu file block sebelum file baru dibuat.
+const DefaultBlockFileSize = 128 << 20
+
+const (
+	blockRecordHeaderSize = 12 // magic, panjang payload, crc32
+	blockIndexEntrySize   = 16 // file, offset, panjang, crc32
+)
+
+var (
+	// ErrBlockCorrupted dikembalikan ketika checksum data block di file tidak cocok.
+	ErrBlockCorrupted = errors.New("block data checksum mismatch")
+
+	blockRecordMagic = [4]byte{'S', 'W', 'B', 'F'}
+
+	blockFileIndexPrefix = []byte("f") // 'f' untuk hash block -> lokasi di file block
+	blockFileInfoPrefix  = []byte("F") // 'F' untuk nomor file -> ringkasan isi file
+	blockFileCurrentKey  = []byte("blockfilecurrent")
+)
+
+func getBlockFileIndexKey(hash crypto.Hash) []byte {
+	return append(append([]byte{}, blockFileIndexPrefix...), hash[:]...)
+}
+
+func getBlockFileInfoKey(file uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, blockFileInfoPrefix...), file)
+}
+
+func blockFileName(file uint32) string {
+	return fmt.Sprintf("blk%05d.dat", file)
+}
+
+// blockLocation adalah posisi payload block di dalam file block.
+type blockLocation struct {
+	file   uint32
+	offset uint32
+	length uint32
+	crc    uint32
+}
+
+func (l *blockLocation) marshal() []byte {
+	b := make([]byte, 0, blockIndexEntrySize)
+	b = binary.BigEndian.AppendUint32(b, l.file)
+	b = binary.BigEndian.AppendUint32(b, l.offset)
+	b = binary.BigEndian.AppendUint32(b, l.length)
+	return binary.BigEndian.AppendUint32(b, l.crc)
+}
+
+func (l *blockLocation) unmarshal(b []byte) error {
+	if len(b) != blockIndexEntrySize {
+		return fmt.Errorf("corrupted block file index entry")
+	}
+	l.file = binary.BigEndian.Uint32(b[0:4])
+	l.offset = binary.BigEndian.Uint32(b[4:8])
+	l.length = binary.BigEndian.Uint32(b[8:12])
+	l.crc = binary.BigEndian.Uint32(b[12:16])
+	return nil
+}
+
+// blockFileInfo meringkas isi satu file block, dipakai untuk pruning.
+type blockFileInfo struct {
+	blocks    uint32
+	minHeight uint32
+	maxHeight uint32
+}
+
+const blockFileInfoSize = 12
+
+func (fi *blockFileInfo) marshal() []byte {
+	b := make([]byte, 0, blockFileInfoSize)
+	b = binary.BigEndian.AppendUint32(b, fi.blocks)
+	b = binary.BigEndian.AppendUint32(b, fi.minHeight)
+	return binary.BigEndian.AppendUint32(b, fi.maxHeight)
+}
+
+func (fi *blockFileInfo) unmarshal(b []byte) bool {
+	if len(b) != blockFileInfoSize {
+		return false
+	}
+	fi.blocks = binary.BigEndian.Uint32(b[0:4])
+	fi.minHeight = binary.BigEndian.Uint32(b[4:8])
+	fi.maxHeight = binary.BigEndian.Uint32(b[8:12])
+	return true
+}
+
+// add mencatat block baru di file.
+func (fi *blockFileInfo) add(height uint32) {
+	if fi.blocks == 0 || height < fi.minHeight {
+		fi.minHeight = height
+	}
+	fi.maxHeight = max(fi.maxHeight, height)
+	fi.blocks++
+}
+
+// BlockFiles menyimpan block secara append-only di file blkNNNNN.dat yang
+// bergilir. Setiap record berisi magic, panjang payload, crc32 dan block yang
+// di-encode. Lokasi setiap block dicatat di store sehingga bisa dibaca tanpa
+// memindai file.
+type BlockFiles struct {
+	dir         string
+	store       storage.Store
+	maxFileSize uint32
+
+	lock    sync.Mutex
+	current uint32
+	size    uint32
+	file    *os.File // File yang sedang ditulisi, dibuka saat Put pertama
+	info    blockFileInfo
+}
+
+// OpenBlockFiles membuka direktori file block dan melanjutkan penulisan di file terakhir.
+func OpenBlockFiles(dir string, s storage.Store) (*BlockFiles, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	bf := &BlockFiles{dir: dir, store: s, maxFileSize: DefaultBlockFileSize}
+	if data, err := s.Get(blockFileCurrentKey); err == nil && len(data) == 4 {
+		bf.current = binary.BigEndian.Uint32(data)
+	}
+	if err := bf.loadCurrent(); err != nil {
+		return nil, err
+	}
+	return bf, nil
+}
+
+// loadCurrent membaca ukuran dan ringkasan file yang sedang ditulisi.
+func (bf *BlockFiles) loadCurrent() error {
+	bf.size = 0
+	bf.info = blockFileInfo{}
+	if fi, err := os.Stat(bf.path(bf.current)); err == nil {
+		bf.size = uint32(fi.Size())
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if data, err := bf.store.Get(getBlockFileInfoKey(bf.current)); err == nil {
+		bf.info.unmarshal(data)
+	}
+	return nil
+}
+
+func (bf *BlockFiles) path(file uint32) string {
+	return filepath.Join(bf.dir, blockFileName(file))
+}
+
+// Put menambahkan block yang sudah di-encode ke file block dan mencatat lokasinya.
+func (bf *BlockFiles) Put(hash crypto.Hash, height uint32, encoded []byte) error {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	recordSize := uint32(blockRecordHeaderSize + len(encoded))
+	if bf.size > 0 && bf.size+recordSize > bf.maxFileSize {
+		if err := bf.rotate(); err != nil {
+			return err
+		}
+	}
+	if bf.file == nil {
+		f, err := os.OpenFile(bf.path(bf.current), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		bf.file = f
+	}
+
+	loc := blockLocation{
+		file:   bf.current,
+		offset: bf.size + blockRecordHeaderSize,
+		length: uint32(len(encoded)),
+		crc:    crc32.ChecksumIEEE(encoded),
+	}
+	record := make([]byte, 0, recordSize)
+	record = append(record, blockRecordMagic[:]...)
+	record = binary.BigEndian.AppendUint32(record, loc.length)
+	record = binary.BigEndian.AppendUint32(record, loc.crc)
+	record = append(record, encoded...)
+	if _, err := bf.file.Write(record); err != nil {
+		return err
+	}
+	bf.size += recordSize
+
+	bf.info.add(height)
+	batch := bf.store.NewBatch()
+	batch.Put(getBlockFileIndexKey(hash), loc.marshal())
+	batch.Put(getBlockFileInfoKey(bf.current), bf.info.marshal())
+	return batch.Write()
+}
+
+// rotate menutup file saat ini dan mulai menulis ke file berikutnya.
+func (bf *BlockFiles) rotate() error {
+	if bf.file != nil {
+		if err := bf.file.Close(); err != nil {
+			return err
+		}
+		bf.file = nil
+	}
+	bf.current++
+	bf.size = 0
+	bf.info = blockFileInfo{}
+	return bf.store.Put(blockFileCurrentKey, binary.BigEndian.AppendUint32(nil, bf.current))
+}
+
+// location mengembalikan lokasi block, atau nil jika block tidak ada di file block.
+func (bf *BlockFiles) location(hash crypto.Hash) (*blockLocation, error) {
+	key := getBlockFileIndexKey(hash)
+	ok, err := bf.store.Has(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := bf.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	loc := &blockLocation{}
+	if err := loc.unmarshal(data); err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+// Has memeriksa apakah block tercatat di file block.
+func (bf *BlockFiles) Has(hash crypto.Hash) (bool, error) {
+	loc, err := bf.location(hash)
+	return loc != nil, err
+}
+
+// Get membaca block yang sudah di-encode dan memverifikasi checksum-nya.
+// Mengembalikan nil jika block tidak tercatat di file block.
+func (bf *BlockFiles) Get(hash crypto.Hash) ([]byte, error) {
+	loc, err := bf.location(hash)
+	if err != nil || loc == nil {
+		return nil, err
+	}
+	f, err := os.Open(bf.path(loc.file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	encoded := make([]byte, loc.length)
+	if _, err := f.ReadAt(encoded, int64(loc.offset)); err != nil {
+		return nil, fmt.Errorf("could not read block %s from %s: %w", hash.ToHex(), blockFileName(loc.file), err)
+	}
+	if crc32.ChecksumIEEE(encoded) != loc.crc {
+		return nil, fmt.Errorf("block %s in %s: %w", hash.ToHex(), blockFileName(loc.file), ErrBlockCorrupted)
+	}
+	return encoded, nil
+}
+
+// Delete menghapus lokasi block dari index. Data di file dihapus oleh PruneFiles.
+func (bf *BlockFiles) Delete(hash crypto.Hash) error {
+	return bf.store.Delete(getBlockFileIndexKey(hash))
+}
+
+// PruneFiles menghapus file block yang semua block-nya berada di height
+// maxHeight atau di bawahnya. File yang sedang ditulisi dan file yang berisi
+// genesis tidak pernah dihapus.
+// Block di file tersebut harus sudah dihapus dari index oleh pemanggil.
+func (bf *BlockFiles) PruneFiles(maxHeight uint32) error {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	it := bf.store.NewIterator(blockFileInfoPrefix)
+	var files []uint32
+	for it.Next() {
+		key := it.Key()
+		var info blockFileInfo
+		if len(key) != len(blockFileInfoPrefix)+4 || !info.unmarshal(it.Value()) {
+			continue
+		}
+		file := binary.BigEndian.Uint32(key[len(blockFileInfoPrefix):])
+		if file != bf.current && info.minHeight > 0 && info.maxHeight <= maxHeight {
+			files = append(files, file)
+		}
+	}
+	it.Close()
+
+	for _, file := range files {
+		if err := os.Remove(bf.path(file)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := bf.store.Delete(getBlockFileInfoKey(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close menutup file yang sedang ditulisi.
+func (bf *BlockFiles) Close() error {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+	if bf.file == nil {
+		return nil
+	}
+	err := bf.file.Close()
+	bf.file = nil
+	return err
+}
+
+// Repair membangun ulang index lokasi block dan ringkasan file dengan memindai
+// semua file block. Record yang rusak dilewati; record terpotong di akhir file
+// terakhir dibuang. Mengembalikan jumlah block yang diindex.
+func (bf *BlockFiles) Repair() (int, error) {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+	if bf.file != nil {
+		if err := bf.file.Close(); err != nil {
+			return 0, err
+		}
+		bf.file = nil
+	}
+
+	// Body block lama disimpan dengan key hash mentah yang bisa berawalan sama,
+	// jadi hanya key dengan panjang yang tepat yang dihapus.
+	if err := deletePrefix(bf.store, blockFileIndexPrefix, len(getBlockFileIndexKey(crypto.Hash{}))); err != nil {
+		return 0, err
+	}
+	if err := deletePrefix(bf.store, blockFileInfoPrefix, len(getBlockFileInfoKey(0))); err != nil {
+		return 0, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(bf.dir, "blk*.dat"))
+	if err != nil {
+		return 0, err
+	}
+	var files []uint32
+	for _, m := range matches {
+		var file uint32
+		if _, err := fmt.Sscanf(filepath.Base(m), "blk%05d.dat", &file); err == nil {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
+
+	indexed := 0
+	bf.current = 0
+	for i, file := range files {
+		n, validSize, err := bf.scanFile(file)
+		if err != nil {
+			return indexed, err
+		}
+		indexed += n
+		if i == len(files)-1 {
+			// Record terakhir yang terpotong (misalnya karena crash) dibuang
+			if err := os.Truncate(bf.path(file), int64(validSize)); err != nil {
+				return indexed, err
+			}
+			bf.current = file
+		}
+	}
+	if err := bf.store.Put(blockFileCurrentKey, binary.BigEndian.AppendUint32(nil, bf.current)); err != nil {
+		return indexed, err
+	}
+	return indexed, bf.loadCurrent()
+}
+
+// scanFile mengindex semua record valid di satu file block dan mengembalikan
+// jumlah block serta offset akhir record valid terakhir.
+func (bf *BlockFiles) scanFile(file uint32) (int, uint32, error) {
+	f, err := os.Open(bf.path(file))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	info := blockFileInfo{}
+	var offset uint32
+	header := make([]byte, blockRecordHeaderSize)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			break
+		}
+		if [4]byte(header[0:4]) != blockRecordMagic {
+			fmt.Printf("Repair: bad record magic in %s at offset %d, stopping\n", blockFileName(file), offset)
+			break
+		}
+		loc := blockLocation{
+			file:   file,
+			offset: offset + blockRecordHeaderSize,
+			length: binary.BigEndian.Uint32(header[4:8]),
+			crc:    binary.BigEndian.Uint32(header[8:12]),
+		}
+		encoded := make([]byte, loc.length)
+		if _, err := io.ReadFull(f, encoded); err != nil {
+			break
+		}
+		offset = loc.offset + loc.length
+
+		if crc32.ChecksumIEEE(encoded) != loc.crc {
+			fmt.Printf("Repair: checksum mismatch in %s at offset %d, skipping\n", blockFileName(file), loc.offset)
+			continue
+		}
+		b := new(Block)
+		if err := b.Decode(encoded); err != nil {
+			fmt.Printf("Repair: undecodable block in %s at offset %d, skipping\n", blockFileName(file), loc.offset)
+			continue
+		}
+		hash, err := b.Hash()
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := bf.store.Put(getBlockFileIndexKey(hash), loc.marshal()); err != nil {
+			return 0, 0, err
+		}
+		info.add(b.Header.Height)
+	}
+	if info.blocks > 0 {
+		if err := bf.store.Put(getBlockFileInfoKey(file), info.marshal()); err != nil {
+			return 0, 0, err
+		}
+	}
+	return int(info.blocks), offset, nil
+}
+
+// deletePrefix menghapus semua key dengan prefix dan panjang tertentu.
+func deletePrefix(s storage.Store, prefix []byte, keyLen int) error {
+	it := s.NewIterator(prefix)
+	var keys [][]byte
+	for it.Next() {
+		if len(it.Key()) == keyLen {
+			keys = append(keys, append([]byte{}, it.Key()...))
+		}
+	}
+	it.Close()
+	batch := s.NewBatch()
+	for _, key := range keys {
+		batch.Delete(key)
+	}
+	return batch.Write()
+}
@@ -1,14 +1,23 @@
 package core
 
 import (
-	"fmt" // Added
+	"fmt"
 	"swatantra/crypto"
 	"swatantra/storage"
 )
 
+var headerKeyPrefix = []byte("h") // 'h' untuk header block
+
+func getHeaderKey(hash crypto.Hash) []byte {
+	return append(append([]byte{}, headerKeyPrefix...), hash[:]...)
+}
+
 // BlockStore bertanggung jawab untuk menyimpan dan mengambil block.
+// Jika files diatur, body block ditulis ke file block; body yang disimpan
+// langsung di store oleh versi lama tetap bisa dibaca.
 type BlockStore struct {
 	store storage.Store
+	files *BlockFiles // nil jika body block disimpan sebagai value di store
 }
 
 // NewBlockStore membuat instance baru dari BlockStore.
@@ -18,6 +27,14 @@ func NewBlockStore(s storage.Store) *BlockStore {
 	}
 }
 
+// NewBlockStoreWithFiles membuat BlockStore yang menyimpan body block di file block.
+func NewBlockStoreWithFiles(s storage.Store, files *BlockFiles) *BlockStore {
+	return &BlockStore{
+		store: s,
+		files: files,
+	}
+}
+
 // Put menyimpan block ke dalam database.
 // Key-nya adalah hash dari block.
 func (bs *BlockStore) Put(b *Block) error {
@@ -30,13 +47,82 @@ func (bs *BlockStore) Put(b *Block) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("BlockStore: Putting block %s (height %d) to store.\n", hash.ToHex(), b.Header.Height);
-	return bs.store.Put(hash[:], encoded)
+	if bs.files != nil {
+		if ok, err := bs.files.Has(hash); err != nil || ok {
+			return err
+		}
+		if err := bs.files.Put(hash, b.Header.Height, encoded); err != nil {
+			return err
+		}
+	} else if err := bs.store.Put(hash[:], encoded); err != nil {
+		return err
+	}
+	return bs.PutHeader(b.Header)
+}
+
+// PutHeader menyimpan header secara terpisah dari body, sehingga header tetap
+// tersedia setelah body block di-prune.
+func (bs *BlockStore) PutHeader(h *Header) error {
+	hash := h.Hash()
+	encoded, err := h.Encode()
+	if err != nil {
+		return err
+	}
+	return bs.store.Put(getHeaderKey(hash), encoded)
+}
+
+// Has memeriksa apakah body block tersedia di store.
+func (bs *BlockStore) Has(hash crypto.Hash) (bool, error) {
+	if bs.files != nil {
+		if ok, err := bs.files.Has(hash); err != nil || ok {
+			return ok, err
+		}
+	}
+	return bs.store.Has(hash[:])
+}
+
+// Prune menghapus body block dan hanya menyisakan header-nya.
+func (bs *BlockStore) Prune(hash crypto.Hash) error {
+	if bs.files != nil {
+		if err := bs.files.Delete(hash); err != nil {
+			return err
+		}
+	}
+	return bs.store.Delete(hash[:])
+}
+
+// PruneFiles menghapus file block yang hanya berisi block di height maxHeight atau di bawahnya.
+func (bs *BlockStore) PruneFiles(maxHeight uint32) error {
+	if bs.files == nil {
+		return nil
+	}
+	return bs.files.PruneFiles(maxHeight)
+}
+
+// Close menutup file block yang sedang ditulisi.
+func (bs *BlockStore) Close() error {
+	if bs.files == nil {
+		return nil
+	}
+	return bs.files.Close()
 }
 
 // Get mengambil block dari database berdasarkan hash-nya.
 func (bs *BlockStore) Get(hash crypto.Hash) (*Block, error) {
 	fmt.Printf("BlockStore: Getting block %s from store.\n", hash.ToHex())
+	if bs.files != nil {
+		encoded, err := bs.files.Get(hash)
+		if err != nil {
+			return nil, err
+		}
+		if encoded != nil {
+			b := new(Block)
+			if err := b.Decode(encoded); err != nil {
+				return nil, err
+			}
+			return b, nil
+		}
+	}
 	encoded, err := bs.store.Get(hash[:])
 	if err != nil {
 		fmt.Printf("BlockStore: Block %s not found in store: %v\n", hash.ToHex(), err)
@@ -53,6 +139,15 @@ func (bs *BlockStore) Get(hash crypto.Hash) (*Block, error) {
 
 // GetHeader mengambil header dari database berdasarkan hash-nya.
 func (bs *BlockStore) GetHeader(hash crypto.Hash) (*Header, error) {
+	if encoded, err := bs.store.Get(getHeaderKey(hash)); err == nil {
+		h := new(Header)
+		if err := h.Decode(encoded); err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+
+	// Database lama belum menyimpan header terpisah
 	b, err := bs.Get(hash)
 	if err != nil {
 		return nil, err
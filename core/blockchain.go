@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
+	"sync"
 	"time"
 
 	"swatantra/crypto"
@@ -13,14 +15,38 @@ import (
 
 var (
 	ErrBlockNotFound = errors.New("block not found")
+	// ErrMissingInputs dikembalikan saat input transaksi tidak ada di UTXO set.
+	ErrMissingInputs = errors.New("input not found in UTXO set")
 )
 
 // Blockchain adalah komponen utama yang mengelola state, termasuk block dan UTXO set.
 type Blockchain struct {
 	store      storage.Store
+	indexStore storage.Store // Store untuk height, address dan spent index
 	blockStore *BlockStore
+	addrIndex  *AddressIndex
+	spentIndex *SpentIndex // nil jika spent index tidak diaktifkan
+
+	utxoCache         *UTXOCache // nil jika UTXO dibaca dan ditulis langsung ke store
+	utxoFlushInterval time.Duration
+	utxoBestHeight    uint32 // Height block terakhir yang UTXO-nya sudah ada di store
+
+	pruneDepth   uint32 // 0 berarti pruning tidak aktif
+	prunedHeight uint32
+
+	snapshot     *snapshotState // nil jika node tidak dimulai dari snapshot UTXO
+	snapshotLock sync.Mutex
+	orphans      *OrphanPool // Block yang parent-nya belum diketahui
+
+	checkpoints      []Checkpoint  // Terurut berdasarkan height
+	assumeValid      crypto.Hash   // Zero jika assume-valid tidak diatur
+	assumeValidChain []crypto.Hash // Hash ancestor block assume-valid per height, nil jika belum diketahui
+
+	sigCache   *SigCache
+	sigWorkers int
 	headers    map[crypto.Hash]*Header // Menyimpan semua header untuk melacak fork
 	head       *Header                 // Header dari block terakhir di main chain
+	bestHeader *Header                 // Header dengan work terbesar, body-nya mungkin belum ada
 }
 
 var headKey = []byte("head")
@@ -30,13 +56,112 @@ func (bc *Blockchain) Head() *Header {
 	return bc.head
 }
 
+// Options berisi pengaturan opsional untuk Blockchain.
+type Options struct {
+	// SpentIndex mengaktifkan index outpoint -> transaksi yang menghabiskannya.
+	// Hanya block yang di-connect selama index aktif yang tercatat.
+	SpentIndex bool
+	// PruneDepth mengaktifkan pruning: body dan data undo block yang lebih dari
+	// PruneDepth block di belakang tip dihapus. 0 berarti pruning tidak aktif.
+	PruneDepth uint32
+	// UTXOCacheSize adalah jumlah maksimum entri di cache UTXO write-back.
+	// 0 berarti UTXO dibaca dan ditulis langsung ke store.
+	UTXOCacheSize int
+	// UTXOFlushInterval adalah interval maksimum antar flush cache UTXO.
+	// 0 berarti DefaultUTXOFlushInterval.
+	UTXOFlushInterval time.Duration
+	// BlocksDir adalah direktori file block (blkNNNNN.dat). Kosong berarti body
+	// block disimpan langsung di store.
+	BlocksDir string
+	// Checkpoints mematok hash block di height tertentu. Block yang bertentangan,
+	// atau yang bercabang di bawah checkpoint yang sudah dilewati, ditolak.
+	Checkpoints []Checkpoint
+	// AssumeValid adalah hash block yang ancestor-nya tidak perlu diverifikasi
+	// tanda tangannya. Zero berarti semua tanda tangan diverifikasi.
+	AssumeValid crypto.Hash
+	// SigWorkers adalah jumlah goroutine verifikasi tanda tangan block.
+	// 0 berarti jumlah CPU.
+	SigWorkers int
+	// SigCacheSize adalah jumlah maksimum tanda tangan valid yang diingat.
+	// 0 berarti DefaultSigCacheSize.
+	SigCacheSize int
+
+	reindexing bool // Diatur oleh Reindex agar database yang sedang di-reindex bisa dibuka
+}
+
 // NewBlockchain membuat instance baru dari Blockchain.
 func NewBlockchain(s storage.Store, initialDifficulty uint32) (*Blockchain, error) {
+	return NewBlockchainWithOptions(s, initialDifficulty, Options{})
+}
+
+// NewBlockchainWithOptions membuat instance baru dari Blockchain dengan pengaturan opsional.
+func NewBlockchainWithOptions(s storage.Store, initialDifficulty uint32, opts Options) (*Blockchain, error) {
+	if opts.PruneDepth > 0 && opts.PruneDepth < MinPruneDepth {
+		return nil, fmt.Errorf("prune depth %d is below the minimum of %d blocks", opts.PruneDepth, MinPruneDepth)
+	}
+
+	if opts.SigCacheSize == 0 {
+		opts.SigCacheSize = DefaultSigCacheSize
+	}
+	if opts.SigWorkers == 0 {
+		opts.SigWorkers = runtime.NumCPU()
+	}
+	checkpoints, err := sortedCheckpoints(opts.Checkpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.reindexing {
+		if ok, err := s.Has(reindexingKey); err != nil || ok {
+			if err == nil {
+				err = ErrReindexInterrupted
+			}
+			return nil, err
+		}
+	}
+
 	bs := NewBlockStore(s)
+	if opts.BlocksDir != "" {
+		files, err := OpenBlockFiles(opts.BlocksDir, s)
+		if err != nil {
+			return nil, err
+		}
+		bs = NewBlockStoreWithFiles(s, files)
+	}
 	bc := &Blockchain{
 		store:      s,
+		indexStore: s,
 		blockStore: bs,
+		orphans:    NewOrphanPool(DefaultMaxOrphanBlocks, DefaultMaxOrphanBytes),
 		headers:    make(map[crypto.Hash]*Header),
+		pruneDepth: opts.PruneDepth,
+
+		checkpoints: checkpoints,
+		assumeValid: opts.AssumeValid,
+		sigCache:    NewSigCache(opts.SigCacheSize),
+		sigWorkers:  opts.SigWorkers,
+	}
+	if err := bc.loadPrunedHeight(); err != nil {
+		return nil, err
+	}
+	if err := bc.loadSnapshotState(); err != nil {
+		return nil, err
+	}
+	if state := bc.snapshotState(); state != nil && state.Invalid {
+		return nil, ErrSnapshotInvalid
+	}
+	if opts.UTXOCacheSize > 0 {
+		bc.utxoCache = NewUTXOCache(s, opts.UTXOCacheSize)
+		// Index ditulis bersama UTXO set saat flush, termasuk perubahan dari reorg
+		bc.indexStore = bc.utxoCache.IndexStore()
+		bc.utxoFlushInterval = opts.UTXOFlushInterval
+		if bc.utxoFlushInterval == 0 {
+			bc.utxoFlushInterval = DefaultUTXOFlushInterval
+		}
+	}
+	bc.addrIndex = NewAddressIndex(bc.indexStore)
+	if opts.SpentIndex {
+		bc.spentIndex = NewSpentIndex(bc.indexStore)
 	}
 
 	headHashBytes, err := s.Get(headKey)
@@ -58,6 +183,9 @@ func NewBlockchain(s storage.Store, initialDifficulty uint32) (*Blockchain, erro
 		if err := s.Put(headKey, blockHash[:]); err != nil {
 			return nil, err
 		}
+		if err := bc.FlushUTXOs(); err != nil {
+			return nil, err
+		}
 	} else {
 		// Load head dari DB
 		var headHash crypto.Hash
@@ -67,7 +195,17 @@ func NewBlockchain(s storage.Store, initialDifficulty uint32) (*Blockchain, erro
 			return nil, err
 		}
 		bc.head = headHeader
-		// TODO: Load all headers into bc.headers
+		bc.headers[headHash] = headHeader
+		// Header lain dimuat dari store saat dibutuhkan (lihat getHeader).
+		if err := bc.ensureHeightIndex(); err != nil {
+			return nil, err
+		}
+		if err := bc.replayUTXOs(); err != nil {
+			return nil, err
+		}
+	}
+	if err := bc.ensureAddressIndex(); err != nil {
+		return nil, err
 	}
 
 	return bc, nil
@@ -125,18 +263,72 @@ func (bc *Blockchain) CalculateNextDifficulty(parentHeader *Header, newTimestamp
 	return newDifficulty, newEMABlockTime
 }
 
-// AddBlock menambahkan block baru ke blockchain, menangani fork.
+// AddBlock menambahkan block baru ke blockchain, menangani fork. Lihat
+// ProcessBlock untuk penanganan block yang parent-nya belum diketahui.
 func (bc *Blockchain) AddBlock(b *Block) error {
+	_, err := bc.ProcessBlock(b)
+	return err
+}
+
+// ProcessBlock menambahkan block seperti AddBlock, lalu men-connect block orphan
+// yang menunggu block ini secara berulang. Mengembalikan block orphan yang
+// berhasil di-connect. Jika parent block belum diketahui, block disimpan di
+// orphan pool dan error yang dikembalikan membungkus ErrOrphanBlock.
+func (bc *Blockchain) ProcessBlock(b *Block) ([]*Block, error) {
+	if err := bc.addBlock(b); err != nil {
+		return nil, err
+	}
+	blockHash, err := b.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return bc.connectOrphans(blockHash), nil
+}
+
+func (bc *Blockchain) addBlock(b *Block) error {
 	blockHash, _ := b.Hash()
-	// Cek apakah block sudah ada
-	if _, ok := bc.headers[blockHash]; ok {
+	// Block di bawah base snapshot UTXO hanya melengkapi history
+	if base := bc.SnapshotBase(); base != nil && bc.NeedsHistory() && b.Header.Height <= base.Height {
+		return bc.addHistoryBlock(b)
+	}
+	// Block yang pernah ditandai invalid ditolak tanpa validasi ulang
+	if err := bc.checkInvalid(blockHash); err != nil {
+		return err
+	}
+	// Cek apakah block sudah ada. Header saja (dari sinkronisasi headers-first)
+	// belum cukup; body-nya masih harus diproses.
+	if _, ok := bc.headers[blockHash]; ok && bc.HasBlock(blockHash) {
 		return nil // Anggap block sudah diproses
 	}
+	if b.Header.Height > 0 {
+		if err := bc.checkInvalidParent(blockHash, b.Header.PrevHash); err != nil {
+			return err
+		}
+		if !bc.HasBlock(b.Header.PrevHash) {
+			return bc.addOrphan(b)
+		}
+	}
 
 	// Validasi block SEBELUM menambahkannya ke mana pun
-	if err := bc.ValidateBlock(b); err != nil {
+	if err := bc.checkBlockHeader(b); err != nil {
+		if errors.Is(err, ErrFutureBlock) {
+			return err
+		}
+		return bc.rejectBlock(blockHash, err)
+	}
+	// Body yang tidak cocok dengan merkle root bisa saja dimutasi oleh peer,
+	// sehingga hash block tidak ditandai invalid
+	if err := checkMerkleRoot(b); err != nil {
 		return err
 	}
+	// Transaksi hanya bisa divalidasi terhadap UTXO set branch-nya sendiri. Block
+	// yang memperpanjang head divalidasi sekarang; block side branch disimpan
+	// dengan validasi header saja dan transaksinya divalidasi di reorganizeChain.
+	if b.Header.PrevHash == bc.head.Hash() {
+		if err := bc.checkBlockTransactions(b); err != nil {
+			return bc.rejectBlock(blockHash, err)
+		}
+	}
 
 	// Ambil header parent untuk menghitung cumulative work.
 	// ValidateBlock seharusnya sudah memastikan header ini ada di bc.headers atau di store.
@@ -151,6 +343,7 @@ func (bc *Blockchain) AddBlock(b *Block) error {
 		return err
 	}
 	bc.headers[blockHash] = b.Header
+	bc.updateBestHeader(b.Header)
 
 	// Cek apakah ini adalah perpanjangan rantai biasa (bukan fork)
 	currentHeadHash := bc.head.Hash()
@@ -160,14 +353,26 @@ func (bc *Blockchain) AddBlock(b *Block) error {
 		}
 		// Perbarui head
 		bc.head = b.Header
-		return bc.store.Put(headKey, blockHash[:])
+		if err := bc.store.Put(headKey, blockHash[:]); err != nil {
+			return err
+		}
+		if err := bc.maybeFlushUTXOs(); err != nil {
+			return err
+		}
+		return bc.pruneBlocks()
 	}
 
 	// Jika bukan perpanjangan biasa, ini adalah fork.
 	// Cek apakah fork ini memiliki cumulative work yang lebih besar.
 	if b.Header.CumulativeWork.Cmp(bc.head.CumulativeWork) > 0 {
 		// Hanya panggil reorg jika kita berada di fork yang lebih baik
-		return bc.reorganizeChain(b)
+		if err := bc.reorganizeChain(b); err != nil {
+			return err
+		}
+		if err := bc.maybeFlushUTXOs(); err != nil {
+			return err
+		}
+		return bc.pruneBlocks()
 	}
 
 	// Jika kita menerima block dari fork yang lebih lemah, abaikan (tapi tetap simpan).
@@ -192,6 +397,10 @@ func (bc *Blockchain) reorganizeChain(newHeadBlock *Block) error {
 		return fmt.Errorf("could not find common ancestor: %v", err)
 	}
 	fmt.Printf("Common ancestor found: %s\n", ancestorHash.ToHex())
+	if ancestor, err := bc.getHeader(ancestorHash); err == nil && ancestor.Height < bc.minReorgHeight() {
+		// Block yang harus di-rollback sudah tidak punya data undo
+		return fmt.Errorf("cannot reorganize below height %d: %w", bc.minReorgHeight(), ErrBlockPruned)
+	}
 
 	// 2. Buat daftar block untuk di-rollback dan di-apply
 	blocksToRollback, err := bc.getChainPath(oldHeadHash, ancestorHash)
@@ -216,7 +425,9 @@ func (bc *Blockchain) reorganizeChain(newHeadBlock *Block) error {
 		}
 	}
 
-	// 4. Apply blocks (dalam urutan terbalik karena getChainPath mengembalikan dari head)
+	// 4. Apply blocks (dalam urutan terbalik karena getChainPath mengembalikan dari head).
+	// Transaksi block side branch baru divalidasi di sini, terhadap UTXO set branch-nya.
+	var applied []*Block
 	for i := len(blocksToApply) - 1; i >= 0; i-- {
 		blockHash := blocksToApply[i]
 		block, err := bc.blockStore.Get(blockHash)
@@ -224,13 +435,38 @@ func (bc *Blockchain) reorganizeChain(newHeadBlock *Block) error {
 			return err
 		}
 		fmt.Printf("Applying block %s (height %d)\n", blockHash.ToHex(), block.Header.Height)
+		if err := bc.checkBlockTransactions(block); err != nil {
+			fmt.Printf("Block %s on the new branch is invalid: %v\n", blockHash.ToHex(), err)
+			if markErr := bc.markInvalid(blockHash, err.Error()); markErr != nil {
+				return markErr
+			}
+			for _, descendant := range blocksToApply[:i] {
+				if markErr := bc.markInvalid(descendant, "descends from invalid block "+blockHash.ToHex()); markErr != nil {
+					return markErr
+				}
+			}
+			if restoreErr := bc.restoreChain(applied, blocksToRollback); restoreErr != nil {
+				return fmt.Errorf("could not restore the original chain after invalid block %s: %v", blockHash.ToHex(), restoreErr)
+			}
+			return fmt.Errorf("reorganization aborted, block %s is invalid: %w", blockHash.ToHex(), err)
+		}
 		if err := bc.updateUTXOSet(block); err != nil {
 			return err
 		}
+		applied = append(applied, block)
 	}
 
-	// 5. Update head
+	// 5. Update head. Dengan cache, head baru ditulis bersama UTXO set-nya:
+	// replayUTXOs hanya bisa menerapkan ulang block ke depan dari UTXO yang
+	// sudah di-flush, bukan men-disconnect block yang data undo-nya sudah dihapus
 	bc.head = newHeadBlock.Header
+	if bc.utxoCache != nil {
+		if err := bc.FlushUTXOs(); err != nil {
+			return err
+		}
+		fmt.Println("Reorganization complete.")
+		return nil
+	}
 	if err := bc.store.Put(headKey, newHeadHash[:]); err != nil { // newHeadHash is Block.Hash()
 		return err
 	}
@@ -239,6 +475,28 @@ func (bc *Blockchain) reorganizeChain(newHeadBlock *Block) error {
 	return nil
 }
 
+// restoreChain membatalkan block branch baru yang sudah diterapkan (dalam urutan
+// terbalik) lalu menerapkan ulang block chain lama, sehingga UTXO set dan index
+// kembali ke tip semula. Head belum diubah selama reorganisasi.
+func (bc *Blockchain) restoreChain(applied []*Block, rolledBack []crypto.Hash) error {
+	fmt.Println("Restoring the original chain...")
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := bc.rollbackUTXOSet(applied[i]); err != nil {
+			return err
+		}
+	}
+	for i := len(rolledBack) - 1; i >= 0; i-- {
+		block, err := bc.blockStore.Get(rolledBack[i])
+		if err != nil {
+			return err
+		}
+		if err := bc.updateUTXOSet(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // findCommonAncestor menemukan nenek moyang bersama dari dua block.
 func (bc *Blockchain) findCommonAncestor(hashA, hashB crypto.Hash) (crypto.Hash, error) {
 	if hashA.IsZero() || hashB.IsZero() {
@@ -277,8 +535,8 @@ func (bc *Blockchain) getChainPath(startHash, endHash crypto.Hash) ([]crypto.Has
 	currentHash := startHash
 	for currentHash != endHash && !currentHash.IsZero() {
 		path = append(path, currentHash)
-		header, ok := bc.headers[currentHash]
-		if !ok {
+		header, err := bc.getHeader(currentHash)
+		if err != nil {
 			return nil, fmt.Errorf("header not found for hash %s", currentHash.ToHex())
 		}
 		if header.Height == 0 {
@@ -289,6 +547,19 @@ func (bc *Blockchain) getChainPath(startHash, endHash crypto.Hash) ([]crypto.Has
 	return path, nil
 }
 
+// getHeader mengambil header dari memori, atau dari blockStore jika belum dimuat.
+func (bc *Blockchain) getHeader(hash crypto.Hash) (*Header, error) {
+	if header, ok := bc.headers[hash]; ok {
+		return header, nil
+	}
+	header, err := bc.blockStore.GetHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+	bc.headers[hash] = header
+	return header, nil
+}
+
 // rollbackUTXOSet membatalkan perubahan UTXO dari sebuah block.
 func (bc *Blockchain) rollbackUTXOSet(b *Block) error {
 	// 1. Ambil data undo
@@ -303,13 +574,23 @@ func (bc *Blockchain) rollbackUTXOSet(b *Block) error {
 	if err := undoBlock.Decode(undoData); err != nil {
 		return err
 	}
+	if err := bc.addrIndex.DisconnectBlock(b, &undoBlock); err != nil {
+		return err
+	}
+	if bc.spentIndex != nil {
+		if err := bc.spentIndex.DisconnectBlock(b); err != nil {
+			return err
+		}
+	}
+	if err := bc.deleteHeightIndex(b.Header.Height); err != nil {
+		return err
+	}
 
 	// 2. Hapus output yang dibuat oleh block ini
 	for _, tx := range b.Transactions {
 		txHash, _ := tx.Hash()
 		for i := range tx.Outputs {
-			key := getUTXOKey(txHash, uint32(i))
-			if err := bc.store.Delete(key); err != nil {
+			if _, err := bc.spendUTXO(txHash, uint32(i)); err != nil {
 				return err
 			}
 		}
@@ -317,12 +598,7 @@ func (bc *Blockchain) rollbackUTXOSet(b *Block) error {
 
 	// 3. Kembalikan output yang dihabiskan oleh block ini
 	for _, spentUTXO := range undoBlock.SpentUTXOs {
-		key := getUTXOKey(spentUTXO.TxHash, spentUTXO.Index)
-		encoded, err := spentUTXO.Output.Encode()
-		if err != nil {
-			return err
-		}
-		if err := bc.store.Put(key, encoded); err != nil {
+		if err := bc.addUTXO(spentUTXO.TxHash, spentUTXO.Index, spentUTXO.Output); err != nil {
 			return err
 		}
 	}
@@ -339,7 +615,7 @@ func (bc *Blockchain) updateUTXOSet(b *Block) error {
 	for _, tx := range b.Transactions {
 		if !tx.IsCoinbase() {
 			for _, input := range tx.Inputs {
-				spentOutput, err := bc.GetUTXO(input.PrevTxHash, input.PrevOutIndex)
+				spentOutput, err := bc.spendUTXO(input.PrevTxHash, input.PrevOutIndex)
 				if err != nil {
 					// Ini seharusnya tidak terjadi jika block sudah divalidasi
 					return fmt.Errorf("could not find UTXO for input %s:%d", input.PrevTxHash.ToHex(), input.PrevOutIndex)
@@ -350,11 +626,6 @@ func (bc *Blockchain) updateUTXOSet(b *Block) error {
 						Output: spentOutput,
 					}
 				undoBlock.SpentUTXOs = append(undoBlock.SpentUTXOs, spentUTXO)
-
-				key := getUTXOKey(input.PrevTxHash, input.PrevOutIndex)
-				if err := bc.store.Delete(key); err != nil {
-					return err
-				}
 			}
 		}
 	}
@@ -366,17 +637,24 @@ func (bc *Blockchain) updateUTXOSet(b *Block) error {
 			return err
 		}
 		for i, output := range tx.Outputs {
-			key := getUTXOKey(txHash, uint32(i))
-			encoded, err := output.Encode()
-			if err != nil {
-				return err
-			}
-			if err := bc.store.Put(key, encoded); err != nil {
+			if err := bc.addUTXO(txHash, uint32(i), output); err != nil {
 				return err
 			}
 		}
 	}
 
+	if err := bc.addrIndex.ConnectBlock(b, undoBlock); err != nil {
+		return err
+	}
+	if bc.spentIndex != nil {
+		if err := bc.spentIndex.ConnectBlock(b); err != nil {
+			return err
+		}
+	}
+	if err := bc.putHeightIndex(b.Header); err != nil {
+		return err
+	}
+
 	// Simpan data undo
 	blockHash, _ := b.Hash()
 	undoKey := getUndoKey(blockHash)
@@ -387,42 +665,79 @@ func (bc *Blockchain) updateUTXOSet(b *Block) error {
 	return bc.store.Put(undoKey, undoData)
 }
 
-
+// ValidateBlock memvalidasi header, merkle root dan transaksi block terhadap UTXO set saat ini.
 func (bc *Blockchain) ValidateBlock(b *Block) error {
-	if b.Header.Height > 0 {
-		prevHeader, ok := bc.headers[b.Header.PrevHash]
+	if err := bc.checkBlockHeader(b); err != nil {
+		return err
+	}
+	if err := checkMerkleRoot(b); err != nil {
+		return err
+	}
+	return bc.checkBlockTransactions(b)
+}
+
+// checkBlockHeader memeriksa aturan yang hanya bergantung pada header block
+// dan parent-nya. Lihat checkHeader.
+func (bc *Blockchain) checkBlockHeader(b *Block) error {
+	return bc.checkHeader(b.Header)
+}
+
+// checkHeader memeriksa aturan yang hanya bergantung pada header dan
+// parent-nya: checkpoint, height, timestamp, difficulty, EMABlockTime dan
+// proof of work. Dipakai untuk block maupun header tanpa body.
+func (bc *Blockchain) checkHeader(h *Header) error {
+	if h.Height > 0 {
+		if err := bc.checkCheckpoints(h); err != nil {
+			return err
+		}
+		prevHeader, ok := bc.headers[h.PrevHash]
 		if !ok {
-			fmt.Printf("ValidateBlock: Parent header %s not in memory. Trying blockStore.\n", b.Header.PrevHash.ToHex())
+			fmt.Printf("ValidateBlock: Parent header %s not in memory. Trying blockStore.\n", h.PrevHash.ToHex())
 			// Try to get parent from blockStore if not in memory
 			var err error
-			prevHeader, err = bc.blockStore.GetHeader(b.Header.PrevHash)
+			prevHeader, err = bc.blockStore.GetHeader(h.PrevHash)
 			if err != nil {
-				return fmt.Errorf("parent block %s not found for validation: %v", b.Header.PrevHash.ToHex(), err)
+				return fmt.Errorf("parent block %s not found for validation: %v", h.PrevHash.ToHex(), err)
 			}
-			fmt.Printf("ValidateBlock: Parent header %s found in blockStore.\n", b.Header.PrevHash.ToHex())
+			fmt.Printf("ValidateBlock: Parent header %s found in blockStore.\n", h.PrevHash.ToHex())
 			// Add to in-memory headers for future quick access
-			bc.headers[b.Header.PrevHash] = prevHeader
-		}
-		if b.Header.Height != prevHeader.Height+1 {
-			return errors.New("invalid height")
-		}
-		
-		// Validasi difficulty
-		expectedDifficulty, expectedEMABlockTime := bc.CalculateNextDifficulty(prevHeader, b.Header.Timestamp)
-		if b.Header.Difficulty != expectedDifficulty {
-			return fmt.Errorf("invalid difficulty: got %d, expected %d", b.Header.Difficulty, expectedDifficulty)
-		}
-		if b.Header.EMABlockTime != expectedEMABlockTime {
-			return fmt.Errorf("invalid EMABlockTime: got %d, expected %d", b.Header.EMABlockTime, expectedEMABlockTime)
+			bc.headers[h.PrevHash] = prevHeader
 		}
+		return bc.checkHeaderWithParent(h, prevHeader, bc.getHeader)
+	}
 
-	} else { // This is the genesis block
-		if !b.Header.PrevHash.IsZero() {
-			return errors.New("genesis block must have zero prevhash")
-		}
+	// This is the genesis block
+	if !h.PrevHash.IsZero() {
+		return errors.New("genesis block must have zero prevhash")
+	}
+	return checkProofOfWork(h)
+}
+
+// checkHeaderWithParent memeriksa height, timestamp, difficulty, EMABlockTime
+// dan proof of work header terhadap parent-nya. getHeader memuat ancestor
+// untuk median-time-past.
+func (bc *Blockchain) checkHeaderWithParent(h, prevHeader *Header, getHeader func(crypto.Hash) (*Header, error)) error {
+	if h.Height != prevHeader.Height+1 {
+		return errors.New("invalid height")
+	}
+	if err := checkHeaderTime(h, prevHeader, getHeader); err != nil {
+		return err
 	}
 
-	pow := NewProofOfWork(b)
+	// Validasi difficulty
+	expectedDifficulty, expectedEMABlockTime := bc.CalculateNextDifficulty(prevHeader, h.Timestamp)
+	if h.Difficulty != expectedDifficulty {
+		return fmt.Errorf("invalid difficulty: got %d, expected %d", h.Difficulty, expectedDifficulty)
+	}
+	if h.EMABlockTime != expectedEMABlockTime {
+		return fmt.Errorf("invalid EMABlockTime: got %d, expected %d", h.EMABlockTime, expectedEMABlockTime)
+	}
+	return checkProofOfWork(h)
+}
+
+// checkProofOfWork memastikan hash header memenuhi difficulty-nya.
+func checkProofOfWork(h *Header) error {
+	pow := NewProofOfWork(&Block{Header: h})
 	valid, err := pow.Validate()
 	if err != nil {
 		return err
@@ -430,7 +745,11 @@ func (bc *Blockchain) ValidateBlock(b *Block) error {
 	if !valid {
 		return errors.New("invalid proof of work")
 	}
+	return nil
+}
 
+// checkMerkleRoot memastikan transaksi block sesuai dengan merkle root di header.
+func checkMerkleRoot(b *Block) error {
 	mTree, err := NewMerkleTree(b.Transactions)
 	if err != nil {
 		return err
@@ -438,65 +757,110 @@ func (bc *Blockchain) ValidateBlock(b *Block) error {
 	if mTree.RootNode.Data != b.Header.MerkleRoot {
 		return errors.New("invalid merkle root")
 	}
+	return nil
+}
 
+// checkBlockTransactions memvalidasi transaksi block terhadap UTXO set saat ini.
+// Outpoint yang dihabiskan dua kali di block yang sama juga ditolak, agar
+// kegagalan terdeteksi sebelum UTXO set diubah. Setelah pemeriksaan murah ini,
+// tanda tangan semua input diverifikasi secara paralel, kecuali untuk ancestor
+// block assume-valid.
+func (bc *Blockchain) checkBlockTransactions(b *Block) error {
+	if err := checkBlockInputs(b, bc.HasUTXO); err != nil {
+		return err
+	}
+	if bc.skipSignatures(b) {
+		return nil
+	}
+	return bc.verifyBlockSignatures(b)
+}
+
+// checkBlockInputs memastikan setiap input block ada di UTXO set yang
+// diperiksa hasUTXO dan tidak dihabiskan dua kali di block yang sama. Output
+// yang dibuat di block yang sama belum ada di UTXO set, sehingga tidak bisa
+// dihabiskan.
+func checkBlockInputs(b *Block, hasUTXO func(crypto.Hash, uint32) (bool, error)) error {
+	spent := make(map[outpoint]bool)
 	for _, tx := range b.Transactions {
-		if !tx.IsCoinbase() {
-			valid, err := bc.ValidateTransaction(tx)
-			if err != nil {
-				return err
-			}
-			if !valid {
-				return errors.New("invalid transaction in block")
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			op := outpoint{input.PrevTxHash, input.PrevOutIndex}
+			if spent[op] {
+				return fmt.Errorf("output %s:%d is spent twice in block", input.PrevTxHash.ToHex(), input.PrevOutIndex)
 			}
+			spent[op] = true
+		}
+		if err := checkTxInputs(tx, hasUTXO); err != nil {
+			return err
 		}
 	}
-
 	return nil
 }
 
 func (bc *Blockchain) HasUTXO(hash crypto.Hash, index uint32) (bool, error) {
+	if bc.utxoCache != nil {
+		return bc.utxoCache.Has(hash, index)
+	}
 	key := getUTXOKey(hash, index)
 	return bc.store.Has(key)
 }
 
 // GetBlockByHash mengambil block dari database berdasarkan hash-nya.
 func (bc *Blockchain) GetBlockByHash(hash crypto.Hash) (*Block, error) {
-	return bc.blockStore.Get(hash)
+	return bc.getBlock(hash)
 }
 
-// GetBlocksFrom mengembalikan daftar block dari hash yang diberikan hingga head.
-func (bc *Blockchain) GetBlocksFrom(fromHash crypto.Hash) ([]*Block, error) {
-	blocks := []*Block{}
-	currentHash := bc.head.Hash()
+// getBlock mengambil block dari block store. Body yang tidak ada hanya
+// dilaporkan sebagai ErrBlockPruned jika height-nya sudah di-prune; header
+// di atasnya berarti body block belum diunduh.
+func (bc *Blockchain) getBlock(hash crypto.Hash) (*Block, error) {
+	block, err := bc.blockStore.Get(hash)
+	if err == nil {
+		return block, nil
+	}
+	if ok, hasErr := bc.blockStore.Has(hash); hasErr != nil || ok {
+		return nil, err
+	}
+	if header, headerErr := bc.blockStore.GetHeader(hash); headerErr == nil && header.Height > 0 && header.Height <= bc.PrunedHeight() {
+		return nil, ErrBlockPruned
+	}
+	return nil, err
+}
 
-	// Iterate backwards from the head until fromHash is found
-	for {
-		block, err := bc.blockStore.Get(currentHash)
-		if err != nil {
-			return nil, err
-		}
+// KnowsBlock melaporkan apakah block tidak perlu diunduh lagi: body-nya
+// tersimpan, sedang menunggu di orphan pool, atau sudah ditandai invalid.
+func (bc *Blockchain) KnowsBlock(hash crypto.Hash) bool {
+	if bc.IsOrphan(hash) || bc.HasBlock(hash) {
+		return true
+	}
+	_, invalid, _ := bc.InvalidReason(hash)
+	return invalid
+}
 
-		blocks = append(blocks, block)
+// GetBlocksFrom mengembalikan paling banyak max block main chain mulai dari
+// hash yang diberikan menuju head.
+func (bc *Blockchain) GetBlocksFrom(fromHash crypto.Hash, max int) ([]*Block, error) {
+	if !bc.IsMainChain(fromHash) {
+		return nil, errors.New("fromHash not found in chain")
+	}
+	fromHeader, err := bc.getHeader(fromHash)
+	if err != nil {
+		return nil, err
+	}
+	if fromHeader.Height > 0 && fromHeader.Height <= bc.PrunedHeight() {
+		return nil, ErrBlockPruned
+	}
 
-		blockHash, err := block.Hash()
+	var blocks []*Block
+	for height := fromHeader.Height; height <= bc.head.Height && len(blocks) < max; height++ {
+		block, err := bc.GetBlockByHeight(height)
 		if err != nil {
 			return nil, err
 		}
-		if blockHash == fromHash {
-			break // Found the starting block
-		}
-
-		if block.Header.Height == 0 {
-			return nil, errors.New("fromHash not found in chain") // Reached genesis without finding fromHash
-		}
-		currentHash = block.Header.PrevHash
-	}
-
-	// Reverse the list to get blocks in forward order
-	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
-		blocks[i], blocks[j] = blocks[j], blocks[i]
+		blocks = append(blocks, block)
 	}
-
 	return blocks, nil
 }
 
@@ -504,17 +868,28 @@ func (bc *Blockchain) ValidateTransaction(tx *Transaction) (bool, error) {
 	if tx.IsCoinbase() {
 		return true, nil
 	}
+	if err := checkTxInputs(tx, bc.HasUTXO); err != nil {
+		return false, err
+	}
+	if err := bc.verifyTxSignatures(tx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkTxInputs memastikan semua input transaksi ada di UTXO set yang
+// diperiksa hasUTXO, tanpa memverifikasi tanda tangan.
+func checkTxInputs(tx *Transaction, hasUTXO func(crypto.Hash, uint32) (bool, error)) error {
 	for _, input := range tx.Inputs {
-		ok, err := bc.HasUTXO(input.PrevTxHash, input.PrevOutIndex)
+		ok, err := hasUTXO(input.PrevTxHash, input.PrevOutIndex)
 		if err != nil {
-			return false, err
+			return err
 		}
 		if !ok {
-			return false, errors.New("input not found in UTXO set")
+			return ErrMissingInputs
 		}
 	}
-
-	return tx.Verify()
+	return nil
 }
 
 var (
@@ -536,51 +911,74 @@ func getUndoKey(hash crypto.Hash) []byte {
 
 // GetUTXO finds and returns a specific output from the UTXO set.
 func (bc *Blockchain) GetUTXO(hash crypto.Hash, index uint32) (*TxOutput, error) {
-	key := getUTXOKey(hash, index)
-	data, err := bc.store.Get(key)
-	if err != nil {
-		return nil, err
+	if bc.utxoCache != nil {
+		return bc.utxoCache.Get(hash, index)
 	}
+	return getStoreUTXO(bc.store, hash, index)
+}
+
+// Status outpoint yang dikembalikan oleh GetOutpoint.
+const (
+	OutpointUnspent = "unspent"
+	OutpointSpent   = "spent"
+	OutpointUnknown = "unknown"
+)
 
-	output := &TxOutput{}
-	if err := output.Decode(data); err != nil {
+// OutpointInfo menjelaskan status sebuah outpoint di main chain.
+type OutpointInfo struct {
+	Status  string
+	Output  *TxOutput  // Diisi jika status unspent
+	SpentBy *SpentInfo // Diisi jika status spent
+}
+
+// GetOutpoint mengembalikan status outpoint. Tanpa spent index, outpoint yang
+// sudah dihabiskan tidak bisa dibedakan dari yang tidak pernah ada (unknown).
+func (bc *Blockchain) GetOutpoint(hash crypto.Hash, index uint32) (*OutpointInfo, error) {
+	ok, err := bc.HasUTXO(hash, index)
+	if err != nil {
 		return nil, err
 	}
+	if ok {
+		output, err := bc.GetUTXO(hash, index)
+		if err != nil {
+			return nil, err
+		}
+		return &OutpointInfo{Status: OutpointUnspent, Output: output}, nil
+	}
+	if bc.spentIndex != nil {
+		info, err := bc.spentIndex.Get(hash, index)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			return &OutpointInfo{Status: OutpointSpent, SpentBy: info}, nil
+		}
+	}
+	return &OutpointInfo{Status: OutpointUnknown}, nil
+}
 
-	return output, nil
+// HasSpentIndex melaporkan apakah spent index aktif.
+func (bc *Blockchain) HasSpentIndex() bool {
+	return bc.spentIndex != nil
 }
 
 // FindUTXOs finds all unspent transaction outputs for a given address.
-// NOTE: This is an inefficient implementation that iterates the whole DB.
-// A real wallet should maintain its own UTXO index.
 func (bc *Blockchain) FindUTXOs(address crypto.Address) ([]*SpentUTXO, error) {
-	var utxos []*SpentUTXO
-	it := bc.store.NewIterator(utxoKeyPrefix)
-	defer it.Close()
+	utxos, _, err := bc.addrIndex.UTXOs(address, 0, 0)
+	return utxos, err
+}
 
-	for it.Next() {
-		key := it.Key()
-		val := it.Value()
+// GetAddressUTXOs mengembalikan satu halaman UTXO milik alamat beserta jumlah totalnya.
+func (bc *Blockchain) GetAddressUTXOs(address crypto.Address, offset, limit int) ([]*SpentUTXO, int, error) {
+	return bc.addrIndex.UTXOs(address, offset, limit)
+}
 
-		output := &TxOutput{}
-		if err := output.Decode(val); err != nil {
-			// Corrupted data, maybe log it
-			continue
-		}
+// GetAddressBalance mengembalikan saldo dan jumlah UTXO milik alamat.
+func (bc *Blockchain) GetAddressBalance(address crypto.Address) (uint64, int, error) {
+	return bc.addrIndex.Balance(address)
+}
 
-		if output.Address == address {
-			// We need to parse the tx hash and index from the key
-			txHash := crypto.Hash{}
-			// key = u<tx_hash><index>
-			copy(txHash[:], key[len(utxoKeyPrefix):len(utxoKeyPrefix)+32])
-			index := binary.BigEndian.Uint32(key[len(utxoKeyPrefix)+32:])
-			
-			utxos = append(utxos, &SpentUTXO{
-				TxHash: txHash,
-				Index:  index,
-				Output: output,
-			})
-		}
-	}
-	return utxos, nil
-}
\ No newline at end of file
+// GetAddressHistory mengembalikan satu halaman riwayat transaksi alamat beserta jumlah totalnya.
+func (bc *Blockchain) GetAddressHistory(address crypto.Address, offset, limit int) ([]*AddressTx, int, error) {
+	return bc.addrIndex.History(address, offset, limit)
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -13,31 +14,19 @@ import (
 
 // Helper function to create a simple blockchain for testing
 func newTestBlockchain(t *testing.T) (*Blockchain, crypto.PrivateKey) {
-	// Create a temporary directory for LevelDB
-	tmpDir, err := ioutil.TempDir("", "test_blockchain_db")
-	if err != nil {
-		t.Fatalf("Failed to create temp directory: %v", err)
-	}
-
-	// Create a new LevelDB store
-	store, err := storage.NewLevelDBStore(tmpDir)
-	if err != nil {
-		t.Fatalf("Failed to create LevelDB store: %v", err)
-	}
+	return newTestBlockchainWithOptions(t, Options{})
+}
 
-	// Clean up the temporary directory and close the store after tests
-	t.Cleanup(func() {
-		store.Close()
-		os.RemoveAll(tmpDir)
-	})
+// newTestBlockchainWithOptions sama seperti newTestBlockchain dengan Options tertentu.
+func newTestBlockchainWithOptions(t *testing.T, opts Options) (*Blockchain, crypto.PrivateKey) {
+	store := newTestStore(t)
 
 	privKey, _ := crypto.GeneratePrivateKey()
 	
 	// Create genesis block parameters
 	initialDifficulty := uint32(10)
 
-	// NewBlockchain only takes store and initialDifficulty
-	bc, err := NewBlockchain(store, initialDifficulty)
+	bc, err := NewBlockchainWithOptions(store, initialDifficulty, opts)
 	if err != nil {
 		t.Fatalf("Failed to create test blockchain: %v", err)
 	}
@@ -321,4 +310,170 @@ func TestValidateBlock(t *testing.T) {
 	if err := bc.ValidateBlock(invalidEMABlockTimeBlock); err == nil {
 		t.Error("Test 6 (Invalid EMABlockTime): ValidateBlock succeeded for invalid EMABlockTime")
 	}
-}
\ No newline at end of file
+}
+
+func TestForkBlockValidatedAgainstOwnBranch(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	genesis := bc.Head()
+
+	// Main chain menghabiskan output genesis dengan txA, fork dengan txB.
+	txA := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 1000, Address: miner}})
+	txB := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 1000, Address: crypto.Address{1}}})
+
+	block1 := mineTestBlock(t, bc, genesis, miner, []*Transaction{txA})
+	if err := bc.AddBlock(block1); err != nil {
+		t.Fatalf("AddBlock block1 failed: %v", err)
+	}
+
+	// txB tidak valid terhadap UTXO set main chain, tapi valid di branch-nya sendiri.
+	fork1 := mineTestBlock(t, bc, genesis, crypto.Address{1}, []*Transaction{txB})
+	if err := bc.AddBlock(fork1); err != nil {
+		t.Fatalf("AddBlock of a valid side branch block failed: %v", err)
+	}
+	fork2 := mineTestBlock(t, bc, fork1.Header, crypto.Address{1}, nil)
+	if err := bc.AddBlock(fork2); err != nil {
+		t.Fatalf("AddBlock fork2 failed: %v", err)
+	}
+	if bc.Head().Hash() != fork2.Header.Hash() {
+		t.Fatalf("Expected reorg to the fork, head is at height %d", bc.Head().Height)
+	}
+	txBHash, _ := txB.Hash()
+	if ok, _ := bc.HasUTXO(txBHash, 0); !ok {
+		t.Error("Output of the fork transaction should be unspent after the reorg")
+	}
+}
+
+func TestReorgToInvalidBranchRestoresTip(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	genesis := bc.Head()
+
+	block1 := mineTestBlock(t, bc, genesis, miner, nil)
+	if err := bc.AddBlock(block1); err != nil {
+		t.Fatalf("AddBlock block1 failed: %v", err)
+	}
+	block2 := mineTestBlock(t, bc, block1.Header, miner, nil)
+	if err := bc.AddBlock(block2); err != nil {
+		t.Fatalf("AddBlock block2 failed: %v", err)
+	}
+	tip := bc.Head().Hash()
+
+	// fork2 menghabiskan output yang tidak ada; baru terdeteksi saat reorg.
+	badTx := NewTransaction([]*TxInput{{PrevTxHash: crypto.Hash{9}, PrevOutIndex: 0}}, []*TxOutput{{Value: 1, Address: miner}})
+	if err := badTx.Sign(privKey); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	fork1 := mineTestBlock(t, bc, block1.Header, crypto.Address{1}, nil)
+	fork2 := mineTestBlock(t, bc, fork1.Header, crypto.Address{1}, []*Transaction{badTx})
+	fork3 := mineTestBlock(t, bc, fork2.Header, crypto.Address{1}, nil)
+	if err := bc.AddBlock(fork1); err != nil {
+		t.Fatalf("AddBlock of side branch block failed: %v", err)
+	}
+	if err := bc.AddBlock(fork2); err == nil {
+		t.Fatal("Expected the reorg to the invalid branch to fail")
+	}
+	if err := bc.AddBlock(fork3); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Descendant of the invalid fork block: expected ErrInvalidBlock, got %v", err)
+	}
+
+	if bc.Head().Hash() != tip {
+		t.Fatalf("Head should stay at the original tip, got height %d", bc.Head().Height)
+	}
+	if hash, err := bc.GetHashByHeight(2); err != nil || hash != tip {
+		t.Errorf("Height index at 2 should point to the original tip: %v", err)
+	}
+	coinbaseHash, _ := block2.Transactions[0].Hash()
+	if ok, _ := bc.HasUTXO(coinbaseHash, 0); !ok {
+		t.Error("Coinbase of the original tip should be unspent after the restore")
+	}
+	forkCoinbase, _ := fork1.Transactions[0].Hash()
+	if ok, _ := bc.HasUTXO(forkCoinbase, 0); ok {
+		t.Error("Coinbase of the rolled back fork block should not be in the UTXO set")
+	}
+	for _, b := range []*Block{fork2, fork3} {
+		if _, invalid, _ := bc.InvalidReason(b.Header.Hash()); !invalid {
+			t.Errorf("Block at height %d should be marked invalid", b.Header.Height)
+		}
+	}
+	if _, invalid, _ := bc.InvalidReason(fork1.Header.Hash()); invalid {
+		t.Error("Valid fork block should not be marked invalid")
+	}
+}
+
+// newTestStore membuat LevelDB store sementara yang dihapus setelah test selesai.
+func newTestStore(t testing.TB) *storage.LevelDBStore {
+	t.Helper()
+	// Create a temporary directory for LevelDB
+	tmpDir, err := ioutil.TempDir("", "test_blockchain_db")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	// Create a new LevelDB store
+	store, err := storage.NewLevelDBStore(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create LevelDB store: %v", err)
+	}
+
+	// Clean up the temporary directory and close the store after tests
+	t.Cleanup(func() {
+		store.Close()
+		os.RemoveAll(tmpDir)
+	})
+	return store
+}
+
+// mineTestBlock membuat dan me-mining block di atas parent dengan coinbase ke coinbaseAddr.
+func mineTestBlock(t testing.TB, bc *Blockchain, parent *Header, coinbaseAddr crypto.Address, txs []*Transaction) *Block {
+	t.Helper()
+	coinbaseTx := NewTransaction(
+		[]*TxInput{{PrevTxHash: crypto.Hash{}, PrevOutIndex: parent.Height + 1}},
+		[]*TxOutput{{Value: 50, Address: coinbaseAddr}},
+	)
+	header := &Header{
+		Version:   1,
+		PrevHash:  parent.Hash(),
+		Height:    parent.Height + 1,
+		Timestamp: parent.Timestamp + int64(TargetBlockTime),
+	}
+	header.Difficulty, header.EMABlockTime = bc.CalculateNextDifficulty(parent, header.Timestamp)
+	block := NewBlock(header, append([]*Transaction{coinbaseTx}, txs...))
+	mTree, err := NewMerkleTree(block.Transactions)
+	if err != nil {
+		t.Fatalf("Failed to create Merkle tree: %v", err)
+	}
+	block.Header.MerkleRoot = mTree.RootNode.Data
+	nonce, _, err := NewProofOfWork(block).Run()
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+	block.Header.Nonce = nonce
+	return block
+}
+
+// spendGenesis membuat tx yang menghabiskan output coinbase genesis.
+func spendGenesis(t *testing.T, bc *Blockchain, privKey crypto.PrivateKey, outputs []*TxOutput) *Transaction {
+	t.Helper()
+	hash := bc.Head().Hash()
+	for {
+		header, err := bc.blockStore.GetHeader(hash)
+		if err != nil {
+			t.Fatalf("Failed to walk back to genesis: %v", err)
+		}
+		if header.Height == 0 {
+			break
+		}
+		hash = header.PrevHash
+	}
+	genesisBlock, err := bc.GetBlockByHash(hash)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	coinbaseTxHash, _ := genesisBlock.Transactions[0].Hash()
+	tx := NewTransaction([]*TxInput{{PrevTxHash: coinbaseTxHash, PrevOutIndex: 0}}, outputs)
+	if err := tx.Sign(privKey); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	return tx
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"swatantra/crypto"
+)
+
+func TestCheckpointsRejectConflictingBranches(t *testing.T) {
+	src, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	genesis := src.Head()
+	var blocks []*Block
+	for i := 0; i < 2; i++ {
+		block := mineTestBlock(t, src, src.Head(), miner, nil)
+		if err := src.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	bc, _ := newTestBlockchainWithOptions(t, Options{Checkpoints: []Checkpoint{{Height: 2, Hash: blocks[1].Header.Hash()}}})
+
+	// Sebelum checkpoint dilewati, fork di bawahnya masih diterima.
+	fork1 := mineTestBlock(t, bc, genesis, crypto.Address{1}, nil)
+	if err := bc.AddBlock(fork1); err != nil {
+		t.Fatalf("AddBlock of fork before the checkpoint is reached failed: %v", err)
+	}
+	// Block lain di height checkpoint ditolak.
+	fork2 := mineTestBlock(t, bc, fork1.Header, crypto.Address{1}, nil)
+	if err := bc.AddBlock(fork2); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("Block conflicting with the checkpoint: expected ErrCheckpointMismatch, got %v", err)
+	}
+
+	for _, b := range blocks {
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("AddBlock of checkpointed chain failed: %v", err)
+		}
+	}
+	if bc.Head().Hash() != blocks[1].Header.Hash() {
+		t.Fatalf("Head should be at the checkpoint, got height %d", bc.Head().Height)
+	}
+
+	// Setelah checkpoint dilewati, fork di bawahnya ditolak.
+	lateFork := mineTestBlock(t, bc, genesis, crypto.Address{2}, nil)
+	if err := bc.AddBlock(lateFork); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Fork below a passed checkpoint: expected ErrCheckpointMismatch, got %v", err)
+	}
+	// Block di atas checkpoint tetap diterima.
+	if err := bc.AddBlock(mineTestBlock(t, bc, bc.Head(), miner, nil)); err != nil {
+		t.Errorf("AddBlock above the checkpoint failed: %v", err)
+	}
+
+	dup := []Checkpoint{{Height: 1, Hash: crypto.Hash{1}}, {Height: 1, Hash: crypto.Hash{2}}}
+	if _, err := NewBlockchainWithOptions(newTestStore(t), 10, Options{Checkpoints: dup}); err == nil {
+		t.Error("Expected an error for duplicate checkpoints")
+	}
+}
+
+func TestAssumeValidSkipsSignatures(t *testing.T) {
+	src, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+
+	// Tanda tangan dirusak; hanya block assume-valid yang membuatnya diterima.
+	tx := spendGenesis(t, src, privKey, []*TxOutput{{Value: 1000, Address: miner}})
+	tx.Inputs[0].Signature[0] ^= 0xff
+	block1 := mineTestBlock(t, src, src.Head(), miner, []*Transaction{tx})
+	block2 := mineTestBlock(t, src, block1.Header, miner, nil)
+	if err := src.AddBlock(block1); err == nil {
+		t.Fatal("Block with a bad signature should be rejected without assume-valid")
+	}
+
+	// Tanpa header block assume-valid, tanda tangan tetap diverifikasi.
+	bc, _ := newTestBlockchainWithOptions(t, Options{AssumeValid: block2.Header.Hash()})
+	if err := bc.AddBlock(block1); err == nil {
+		t.Fatal("Block with a bad signature should be rejected while the assume-valid header is unknown")
+	}
+
+	bc, _ = newTestBlockchainWithOptions(t, Options{AssumeValid: block2.Header.Hash()})
+	for _, h := range []*Header{block1.Header, block2.Header} {
+		if err := bc.blockStore.PutHeader(h); err != nil {
+			t.Fatalf("PutHeader failed: %v", err)
+		}
+	}
+	for _, b := range []*Block{block1, block2} {
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("AddBlock of assumed-valid ancestor failed: %v", err)
+		}
+	}
+
+	// UTXO set tetap diperiksa untuk ancestor block assume-valid.
+	missing := NewTransaction([]*TxInput{{PrevTxHash: crypto.Hash{9}, PrevOutIndex: 0}}, []*TxOutput{{Value: 1, Address: miner}})
+	bad1 := mineTestBlock(t, src, src.Head(), miner, []*Transaction{missing})
+	bc, _ = newTestBlockchainWithOptions(t, Options{AssumeValid: bad1.Header.Hash()})
+	if err := bc.blockStore.PutHeader(bad1.Header); err != nil {
+		t.Fatalf("PutHeader failed: %v", err)
+	}
+	if err := bc.AddBlock(bad1); err == nil {
+		t.Error("Block spending a missing output should be rejected even when assumed valid")
+	}
+}
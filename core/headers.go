@@ -0,0 +1,195 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"swatantra/crypto"
+)
+
+const (
+	// MaxFutureBlockTime adalah seberapa jauh timestamp header boleh melewati waktu lokal.
+	MaxFutureBlockTime = 2 * time.Hour
+	// medianTimeSpan adalah jumlah ancestor yang dipakai untuk median-time-past.
+	medianTimeSpan = 11
+)
+
+var (
+	// ErrFutureBlock dikembalikan untuk header dengan timestamp terlalu jauh di
+	// masa depan. Block seperti ini tidak ditandai invalid karena bisa menjadi
+	// valid seiring waktu.
+	ErrFutureBlock = errors.New("block timestamp too far in the future")
+	// ErrUnconnectedHeader dikembalikan oleh ProcessHeaders untuk header yang
+	// parent-nya belum diketahui.
+	ErrUnconnectedHeader = errors.New("header does not connect to a known header")
+)
+
+// medianTimePast mengembalikan median timestamp dari header dan hingga
+// medianTimeSpan-1 ancestor-nya, yang dimuat dengan getHeader.
+func medianTimePast(h *Header, getHeader func(crypto.Hash) (*Header, error)) (int64, error) {
+	timestamps := make([]int64, 0, medianTimeSpan)
+	for len(timestamps) < medianTimeSpan {
+		timestamps = append(timestamps, h.Timestamp)
+		if h.Height == 0 {
+			break
+		}
+		var err error
+		if h, err = getHeader(h.PrevHash); err != nil {
+			return 0, err
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2], nil
+}
+
+// checkHeaderTime memastikan timestamp header lebih besar dari median-time-past
+// parent-nya dan tidak lebih dari MaxFutureBlockTime di depan waktu lokal.
+func checkHeaderTime(h, parent *Header, getHeader func(crypto.Hash) (*Header, error)) error {
+	mtp, err := medianTimePast(parent, getHeader)
+	if err != nil {
+		return err
+	}
+	if h.Timestamp <= mtp {
+		return fmt.Errorf("timestamp %d is not after median time past %d", h.Timestamp, mtp)
+	}
+	if limit := time.Now().Add(MaxFutureBlockTime).UnixNano(); h.Timestamp > limit {
+		return fmt.Errorf("%w: timestamp %d, limit %d", ErrFutureBlock, h.Timestamp, limit)
+	}
+	return nil
+}
+
+// HasBlock memeriksa apakah block sudah diproses: body-nya tersimpan atau
+// block ada di main chain (body block main chain bisa saja sudah di-prune).
+func (bc *Blockchain) HasBlock(hash crypto.Hash) bool {
+	if bc.IsMainChain(hash) {
+		return true
+	}
+	ok, err := bc.blockStore.Has(hash)
+	return err == nil && ok
+}
+
+// GetHeader mengembalikan header yang tersimpan beserta cumulative work-nya,
+// baik body-nya sudah dimiliki atau belum.
+func (bc *Blockchain) GetHeader(hash crypto.Hash) (*Header, error) {
+	return bc.getHeader(hash)
+}
+
+// ProcessHeaders memvalidasi header dari peer tanpa body-nya (proof of work,
+// difficulty, timestamp dan checkpoint), lalu menyimpannya beserta cumulative
+// work-nya. Header harus terurut dan masing-masing menyambung ke header yang
+// sudah diketahui. Mengembalikan jumlah header baru yang disimpan.
+func (bc *Blockchain) ProcessHeaders(headers []*Header) (int, error) {
+	added := 0
+	for _, h := range headers {
+		hash := h.Hash()
+		if err := bc.checkInvalid(hash); err != nil {
+			return added, err
+		}
+		if known, err := bc.getHeader(hash); err == nil {
+			// Header yang sudah tersimpan tetap dipertimbangkan, misalnya setelah restart
+			if known.CumulativeWork != nil {
+				bc.updateBestHeader(known)
+			}
+			continue
+		}
+		if h.Height == 0 {
+			return added, fmt.Errorf("%w: genesis %s differs from ours", ErrUnconnectedHeader, hash.ToHex())
+		}
+		if err := bc.checkInvalidParent(hash, h.PrevHash); err != nil {
+			return added, err
+		}
+		parent, err := bc.getHeader(h.PrevHash)
+		if err != nil {
+			return added, fmt.Errorf("%w: parent %s of header %s", ErrUnconnectedHeader, h.PrevHash.ToHex(), hash.ToHex())
+		}
+		if err := bc.checkHeader(h); err != nil {
+			if errors.Is(err, ErrFutureBlock) {
+				return added, err
+			}
+			return added, bc.rejectBlock(hash, err)
+		}
+
+		header := *h
+		header.CumulativeWork = new(big.Int).Add(parent.CumulativeWork, NewProofOfWork(&Block{Header: &header}).Work())
+		if err := bc.blockStore.PutHeader(&header); err != nil {
+			return added, err
+		}
+		bc.headers[hash] = &header
+		bc.updateBestHeader(&header)
+		added++
+	}
+	return added, nil
+}
+
+// updateBestHeader menjadikan header sebagai best header jika work-nya lebih besar.
+func (bc *Blockchain) updateBestHeader(h *Header) {
+	if h.CumulativeWork.Cmp(bc.BestHeader().CumulativeWork) > 0 {
+		bc.bestHeader = h
+	}
+}
+
+// BestHeader mengembalikan header valid dengan cumulative work terbesar yang
+// diketahui, baik body-nya sudah tersimpan atau belum. Jika tidak ada yang
+// lebih baik dari head, head dikembalikan.
+func (bc *Blockchain) BestHeader() *Header {
+	best := bc.bestHeader
+	if best == nil || best.CumulativeWork.Cmp(bc.head.CumulativeWork) <= 0 {
+		return bc.head
+	}
+	if _, invalid, _ := bc.InvalidReason(best.Hash()); invalid {
+		bc.bestHeader = nil
+		return bc.head
+	}
+	return best
+}
+
+// HeaderLocator mengembalikan block locator dari best header, sehingga peer
+// melanjutkan pengiriman header dari ujung header chain kita.
+func (bc *Blockchain) HeaderLocator() []crypto.Hash {
+	return bc.locatorFrom(bc.BestHeader())
+}
+
+// GetHeadersFrom mengembalikan hingga max header main chain setelah titik
+// percabangan locator dengan main chain, berhenti di stop jika diisi.
+func (bc *Blockchain) GetHeadersFrom(locator []crypto.Hash, stop crypto.Hash, max int) ([]*Header, error) {
+	forkPoint, err := bc.FindForkPoint(locator)
+	if err != nil {
+		return nil, err
+	}
+	var headers []*Header
+	for height := forkPoint.Height + 1; height <= bc.head.Height && len(headers) < max; height++ {
+		hash, err := bc.GetHashByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		header, err := bc.getHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+		if hash == stop {
+			break
+		}
+	}
+	return headers, nil
+}
+
+// MissingBlocks mengembalikan header block di chain best header yang body-nya
+// belum dimiliki, terurut dari height terendah.
+func (bc *Blockchain) MissingBlocks() ([]*Header, error) {
+	var missing []*Header
+	for h := bc.BestHeader(); !bc.HasBlock(h.Hash()); {
+		missing = append(missing, h)
+		var err error
+		if h, err = bc.getHeader(h.PrevHash); err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(missing)-1; i < j; i, j = i+1, j-1 {
+		missing[i], missing[j] = missing[j], missing[i]
+	}
+	return missing, nil
+}
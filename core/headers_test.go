@@ -0,0 +1,107 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"swatantra/crypto"
+)
+
+func TestHeadersFirstSync(t *testing.T) {
+	src, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	var blocks []*Block
+	for i := 0; i < 5; i++ {
+		block := mineTestBlock(t, src, src.Head(), miner, nil)
+		if err := src.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	bc, _ := newTestBlockchain(t)
+	headers, err := src.GetHeadersFrom(bc.HeaderLocator(), crypto.Hash{}, 2000)
+	if err != nil || len(headers) != len(blocks) {
+		t.Fatalf("GetHeadersFrom: got %d headers (%v), expected %d", len(headers), err, len(blocks))
+	}
+	if added, err := bc.ProcessHeaders(headers); err != nil || added != len(blocks) {
+		t.Fatalf("ProcessHeaders: added %d (%v), expected %d", added, err, len(blocks))
+	}
+	if bc.BestHeader().Hash() != src.Head().Hash() {
+		t.Fatalf("Best header should be the source tip, got height %d", bc.BestHeader().Height)
+	}
+	if bc.Head().Height != 0 {
+		t.Fatalf("Head should not move before bodies arrive, got height %d", bc.Head().Height)
+	}
+	if locator := bc.HeaderLocator(); locator[0] != src.Head().Hash() {
+		t.Error("Header locator should start at the best header")
+	}
+	missing, err := bc.MissingBlocks()
+	if err != nil || len(missing) != len(blocks) || missing[0].Hash() != blocks[0].Header.Hash() {
+		t.Fatalf("MissingBlocks: got %d headers (%v), expected all %d blocks in order", len(missing), err, len(blocks))
+	}
+	// Block yang baru diketahui header-nya belum diunduh, bukan di-prune.
+	if _, err := bc.GetBlockByHash(blocks[0].Header.Hash()); err == nil || errors.Is(err, ErrBlockPruned) {
+		t.Errorf("Block with only a header: expected not found, got %v", err)
+	}
+
+	// Body yang parent-nya baru diketahui header-nya menunggu di orphan pool.
+	if err := bc.AddBlock(blocks[1]); !errors.Is(err, ErrOrphanBlock) {
+		t.Fatalf("Block with a header-only parent: expected ErrOrphanBlock, got %v", err)
+	}
+	for _, b := range []*Block{blocks[0], blocks[2], blocks[3], blocks[4]} {
+		if err := bc.AddBlock(b); err != nil && !errors.Is(err, ErrOrphanBlock) {
+			t.Fatalf("AddBlock at height %d failed: %v", b.Header.Height, err)
+		}
+	}
+	if bc.Head().Hash() != src.Head().Hash() {
+		t.Fatalf("Head after downloading bodies: got height %d, expected %d", bc.Head().Height, src.Head().Height)
+	}
+	if missing, _ := bc.MissingBlocks(); len(missing) != 0 {
+		t.Errorf("No blocks should be missing, got %d", len(missing))
+	}
+}
+
+func TestProcessHeadersValidation(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	genesis := bc.Head()
+
+	block := mineTestBlock(t, bc, genesis, miner, nil)
+	child := mineTestBlock(t, bc, block.Header, miner, nil)
+	if _, err := bc.ProcessHeaders([]*Header{child.Header}); !errors.Is(err, ErrUnconnectedHeader) {
+		t.Errorf("Header with unknown parent: expected ErrUnconnectedHeader, got %v", err)
+	}
+
+	// Header dengan difficulty yang salah ditandai invalid.
+	bad := *block.Header
+	bad.Difficulty++
+	if _, err := bc.ProcessHeaders([]*Header{&bad}); err == nil {
+		t.Fatal("Expected an error for a header with the wrong difficulty")
+	}
+	if _, invalid, _ := bc.InvalidReason(bad.Hash()); !invalid {
+		t.Error("Header failing validation should be marked invalid")
+	}
+
+	// Timestamp tidak boleh di belakang median-time-past.
+	early := *block.Header
+	early.Timestamp = genesis.Timestamp
+	if _, err := bc.ProcessHeaders([]*Header{&early}); err == nil {
+		t.Error("Expected an error for a header at the median time past")
+	}
+
+	// Timestamp terlalu jauh di depan ditolak tanpa ditandai invalid.
+	future := *block.Header
+	future.Timestamp = time.Now().Add(MaxFutureBlockTime + time.Hour).UnixNano()
+	if _, err := bc.ProcessHeaders([]*Header{&future}); !errors.Is(err, ErrFutureBlock) {
+		t.Errorf("Header from the future: expected ErrFutureBlock, got %v", err)
+	}
+	if _, invalid, _ := bc.InvalidReason(future.Hash()); invalid {
+		t.Error("Header from the future must not be marked invalid")
+	}
+
+	if added, err := bc.ProcessHeaders([]*Header{block.Header, child.Header}); err != nil || added != 2 {
+		t.Errorf("ProcessHeaders of a valid chain: added %d (%v), expected 2", added, err)
+	}
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"swatantra/crypto"
+)
+
+var heightKeyPrefix = []byte("n") // 'n' untuk height -> hash block di main chain
+
+func getHeightKey(height uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, heightKeyPrefix...), height)
+}
+
+// putHeightIndex mencatat hash block sebagai block main chain di height-nya.
+func (bc *Blockchain) putHeightIndex(h *Header) error {
+	hash := h.Hash()
+	return bc.indexStore.Put(getHeightKey(h.Height), hash[:])
+}
+
+// deleteHeightIndex menghapus entri main chain di height tertentu.
+func (bc *Blockchain) deleteHeightIndex(height uint32) error {
+	return bc.indexStore.Delete(getHeightKey(height))
+}
+
+// GetHashByHeight mengembalikan hash block main chain di height tertentu.
+func (bc *Blockchain) GetHashByHeight(height uint32) (crypto.Hash, error) {
+	var hash crypto.Hash
+	if height > bc.head.Height {
+		return hash, ErrBlockNotFound
+	}
+	data, err := bc.indexStore.Get(getHeightKey(height))
+	if err != nil {
+		return hash, ErrBlockNotFound
+	}
+	copy(hash[:], data)
+	return hash, nil
+}
+
+// GetBlockByHeight mengambil block main chain di height tertentu.
+func (bc *Blockchain) GetBlockByHeight(height uint32) (*Block, error) {
+	hash, err := bc.GetHashByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	return bc.getBlock(hash)
+}
+
+// IsMainChain memeriksa apakah block dengan hash tertentu berada di main chain.
+func (bc *Blockchain) IsMainChain(hash crypto.Hash) bool {
+	header, err := bc.getHeader(hash)
+	if err != nil {
+		return false
+	}
+	mainHash, err := bc.GetHashByHeight(header.Height)
+	return err == nil && mainHash == hash
+}
+
+// BlockLocator mengembalikan daftar hash main chain dari head ke genesis dengan
+// jarak yang membesar secara eksponensial: 10 block terakhir, lalu jarak
+// berlipat dua hingga genesis. Peer memakai locator ini untuk menemukan titik
+// percabangan tanpa mengetahui chain kita.
+func (bc *Blockchain) BlockLocator() []crypto.Hash {
+	return bc.locatorFrom(bc.head)
+}
+
+// locatorFrom membuat block locator yang dimulai dari header, yang tidak harus
+// berada di main chain.
+func (bc *Blockchain) locatorFrom(header *Header) []crypto.Hash {
+	locator := []crypto.Hash{}
+	step := uint32(1)
+	for header.Height > 0 {
+		locator = append(locator, header.Hash())
+		if len(locator) >= 10 {
+			step *= 2
+		}
+		if step >= header.Height {
+			break
+		}
+		next, err := bc.ancestorAt(header, header.Height-step)
+		if err != nil {
+			break
+		}
+		header = next
+	}
+	if genesisHash, err := bc.GetHashByHeight(0); err == nil {
+		locator = append(locator, genesisHash)
+	}
+	return locator
+}
+
+// ancestorAt mengembalikan ancestor header di height tertentu. Ancestor di
+// main chain dicari lewat height index, selebihnya dengan menelusuri parent.
+func (bc *Blockchain) ancestorAt(header *Header, height uint32) (*Header, error) {
+	for header.Height > height {
+		if bc.IsMainChain(header.Hash()) {
+			hash, err := bc.GetHashByHeight(height)
+			if err != nil {
+				return nil, err
+			}
+			return bc.getHeader(hash)
+		}
+		var err error
+		if header, err = bc.getHeader(header.PrevHash); err != nil {
+			return nil, err
+		}
+	}
+	return header, nil
+}
+
+// FindForkPoint mengembalikan header main chain pertama yang muncul di locator.
+// Jika tidak ada yang cocok, genesis dikembalikan.
+func (bc *Blockchain) FindForkPoint(locator []crypto.Hash) (*Header, error) {
+	for _, hash := range locator {
+		if bc.IsMainChain(hash) {
+			return bc.getHeader(hash)
+		}
+	}
+	genesisHash, err := bc.GetHashByHeight(0)
+	if err != nil {
+		return nil, err
+	}
+	return bc.getHeader(genesisHash)
+}
+
+// ensureHeightIndex membangun height index dengan menelusuri mundur dari head
+// untuk database yang dibuat sebelum index ini ada, atau yang berhenti sebelum
+// index block terakhir di-flush bersama cache UTXO.
+func (bc *Blockchain) ensureHeightIndex() error {
+	headHash := bc.head.Hash()
+	if hash, err := bc.GetHashByHeight(bc.head.Height); err == nil && hash == headHash {
+		return nil
+	}
+	fmt.Println("Building height index from head...")
+	currentHash := headHash
+	for {
+		header, err := bc.getHeader(currentHash)
+		if err != nil {
+			return err
+		}
+		if hash, err := bc.GetHashByHeight(header.Height); err == nil && hash == currentHash {
+			return nil
+		}
+		if err := bc.putHeightIndex(header); err != nil {
+			return err
+		}
+		if header.Height == 0 {
+			return nil
+		}
+		currentHash = header.PrevHash
+	}
+}
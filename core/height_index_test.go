@@ -0,0 +1,85 @@
+package core
+
+import (
+	"testing"
+
+	"swatantra/crypto"
+)
+
+func TestHeightIndexAndLocator(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	genesis := bc.Head()
+
+	// Main chain: genesis <- a1 <- a2
+	a1 := mineTestBlock(t, bc, genesis, miner, nil)
+	if err := bc.AddBlock(a1); err != nil {
+		t.Fatalf("AddBlock a1 failed: %v", err)
+	}
+	a2 := mineTestBlock(t, bc, a1.Header, miner, nil)
+	if err := bc.AddBlock(a2); err != nil {
+		t.Fatalf("AddBlock a2 failed: %v", err)
+	}
+
+	block, err := bc.GetBlockByHeight(2)
+	if err != nil {
+		t.Fatalf("GetBlockByHeight failed: %v", err)
+	}
+	if hash, _ := block.Hash(); hash != a2.Header.Hash() {
+		t.Errorf("Block at height 2 is %s, expected a2", hash.ToHex())
+	}
+	if _, err := bc.GetBlockByHeight(3); err != ErrBlockNotFound {
+		t.Errorf("Expected ErrBlockNotFound above head, got %v", err)
+	}
+
+	blocks, err := bc.GetBlocksFrom(a1.Header.Hash(), 100)
+	if err != nil {
+		t.Fatalf("GetBlocksFrom failed: %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Errorf("GetBlocksFrom returned %d blocks, expected 2", len(blocks))
+	}
+	if blocks, _ := bc.GetBlocksFrom(a1.Header.Hash(), 1); len(blocks) != 1 {
+		t.Errorf("GetBlocksFrom with max 1 returned %d blocks, expected 1", len(blocks))
+	}
+
+	// Fork dengan work lebih besar: genesis <- b1 <- b2 <- b3
+	b1 := mineTestBlock(t, bc, genesis, crypto.Address{2}, nil)
+	b2 := mineTestBlock(t, bc, b1.Header, crypto.Address{2}, nil)
+	b3 := mineTestBlock(t, bc, b2.Header, crypto.Address{2}, nil)
+	for _, b := range []*Block{b1, b2, b3} {
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("AddBlock fork failed: %v", err)
+		}
+	}
+
+	for height, expected := range []*Block{nil, b1, b2, b3} {
+		if expected == nil {
+			continue
+		}
+		hash, err := bc.GetHashByHeight(uint32(height))
+		if err != nil || hash != expected.Header.Hash() {
+			t.Errorf("Height %d not reorged to the fork block", height)
+		}
+	}
+	if bc.IsMainChain(a1.Header.Hash()) {
+		t.Error("a1 should no longer be on the main chain")
+	}
+	if _, err := bc.GetBlocksFrom(a1.Header.Hash(), 100); err == nil {
+		t.Error("GetBlocksFrom should fail for a block that left the main chain")
+	}
+
+	// Locator dari chain lama harus menunjuk ke genesis sebagai titik percabangan.
+	forkPoint, err := bc.FindForkPoint([]crypto.Hash{a2.Header.Hash(), a1.Header.Hash(), genesis.Hash()})
+	if err != nil {
+		t.Fatalf("FindForkPoint failed: %v", err)
+	}
+	if forkPoint.Hash() != genesis.Hash() {
+		t.Errorf("Fork point is at height %d, expected genesis", forkPoint.Height)
+	}
+
+	locator := bc.BlockLocator()
+	if len(locator) != 4 || locator[0] != b3.Header.Hash() || locator[3] != genesis.Hash() {
+		t.Errorf("Unexpected locator: %d hashes", len(locator))
+	}
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"swatantra/crypto"
+)
+
+var (
+	// ErrInvalidBlock dikembalikan untuk block yang sudah ditandai invalid,
+	// baik karena gagal validasi, turunan block invalid, maupun di-invalidate manual.
+	ErrInvalidBlock = errors.New("block is marked invalid")
+
+	invalidKeyPrefix = []byte("x") // 'x' untuk hash block -> alasan block invalid
+)
+
+func getInvalidKey(hash crypto.Hash) []byte {
+	return append(append([]byte{}, invalidKeyPrefix...), hash[:]...)
+}
+
+// InvalidReason mengembalikan alasan block ditandai invalid, dan false jika
+// block tidak ditandai.
+func (bc *Blockchain) InvalidReason(hash crypto.Hash) (string, bool, error) {
+	key := getInvalidKey(hash)
+	ok, err := bc.store.Has(key)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	data, err := bc.store.Get(key)
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// markInvalid menandai block invalid secara persisten.
+func (bc *Blockchain) markInvalid(hash crypto.Hash, reason string) error {
+	return bc.store.Put(getInvalidKey(hash), []byte(reason))
+}
+
+// rejectBlock menandai block yang gagal aturan konsensus lalu mengembalikan
+// error validasinya.
+func (bc *Blockchain) rejectBlock(hash crypto.Hash, err error) error {
+	if markErr := bc.markInvalid(hash, err.Error()); markErr != nil {
+		return markErr
+	}
+	return err
+}
+
+// checkInvalid mengembalikan ErrInvalidBlock jika block sudah ditandai invalid.
+func (bc *Blockchain) checkInvalid(hash crypto.Hash) error {
+	reason, invalid, err := bc.InvalidReason(hash)
+	if err != nil || !invalid {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidBlock, reason)
+}
+
+// checkInvalidParent menandai block sebagai invalid jika parent-nya invalid,
+// sehingga tanda invalid menurun ke turunan tanpa validasi.
+func (bc *Blockchain) checkInvalidParent(hash, parent crypto.Hash) error {
+	_, invalid, err := bc.InvalidReason(parent)
+	if err != nil || !invalid {
+		return err
+	}
+	reason := "descends from invalid block " + parent.ToHex()
+	if err := bc.markInvalid(hash, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidBlock, reason)
+}
+
+// descendants mengembalikan semua header tersimpan yang merupakan turunan hash,
+// terurut berdasarkan height.
+func (bc *Blockchain) descendants(hash crypto.Hash) ([]*Header, error) {
+	headers, err := storedHeaders(bc.store)
+	if err != nil {
+		return nil, err
+	}
+	inBranch := map[crypto.Hash]bool{hash: true}
+	var result []*Header
+	for _, h := range headers {
+		if inBranch[h.PrevHash] {
+			inBranch[h.Hash()] = true
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
+
+// InvalidateBlock menandai block beserta seluruh turunannya sebagai invalid,
+// lalu berpindah ke chain valid dengan work terbesar. Jika block ada di main
+// chain, chain di-rollback setidaknya sampai parent-nya.
+func (bc *Blockchain) InvalidateBlock(hash crypto.Hash) error {
+	header, err := bc.getHeader(hash)
+	if err != nil {
+		return fmt.Errorf("block %s not found: %w", hash.ToHex(), err)
+	}
+	if header.Height == 0 {
+		return errors.New("cannot invalidate the genesis block")
+	}
+
+	if err := bc.markInvalid(hash, "invalidated manually"); err != nil {
+		return err
+	}
+	descendants, err := bc.descendants(hash)
+	if err != nil {
+		return err
+	}
+	for _, h := range descendants {
+		if err := bc.markInvalid(h.Hash(), "descends from invalid block "+hash.ToHex()); err != nil {
+			return err
+		}
+	}
+	return bc.activateBestChain()
+}
+
+// ReconsiderBlock menghapus tanda invalid dari block, ancestor dan turunannya,
+// lalu berpindah ke chain valid dengan work terbesar. Block yang pernah ditolak
+// sebelum disimpan cukup dihapus tandanya agar bisa divalidasi ulang saat tiba lagi.
+func (bc *Blockchain) ReconsiderBlock(hash crypto.Hash) error {
+	if err := bc.store.Delete(getInvalidKey(hash)); err != nil {
+		return err
+	}
+	header, err := bc.getHeader(hash)
+	if err != nil {
+		return nil
+	}
+
+	// Block main chain tidak pernah bertanda invalid, jadi penelusuran berhenti di sana
+	for h := header; h.Height > 0 && !bc.IsMainChain(h.PrevHash); {
+		if err := bc.store.Delete(getInvalidKey(h.PrevHash)); err != nil {
+			return err
+		}
+		if h, err = bc.getHeader(h.PrevHash); err != nil {
+			return err
+		}
+	}
+	descendants, err := bc.descendants(hash)
+	if err != nil {
+		return err
+	}
+	for _, h := range descendants {
+		if err := bc.store.Delete(getInvalidKey(h.Hash())); err != nil {
+			return err
+		}
+	}
+	return bc.activateBestChain()
+}
+
+// activateBestChain memilih header valid dengan cumulative work terbesar yang
+// body-nya tersedia dan melakukan reorganisasi ke sana jika berbeda dari head.
+// Pada work yang sama, head saat ini dipertahankan.
+func (bc *Blockchain) activateBestChain() error {
+	var best *Header
+	if _, invalid, err := bc.InvalidReason(bc.head.Hash()); err != nil {
+		return err
+	} else if !invalid {
+		best = bc.head
+	}
+
+	headers, err := storedHeaders(bc.store)
+	if err != nil {
+		return err
+	}
+	for _, h := range headers {
+		if h.CumulativeWork == nil || (best != nil && h.CumulativeWork.Cmp(best.CumulativeWork) <= 0) {
+			continue
+		}
+		hash := h.Hash()
+		if _, invalid, err := bc.InvalidReason(hash); err != nil {
+			return err
+		} else if invalid {
+			continue
+		}
+		if !bc.IsMainChain(hash) {
+			if ok, err := bc.blockStore.Has(hash); err != nil || !ok {
+				continue
+			}
+		}
+		best = h
+	}
+	if best == nil {
+		// Semua block selain genesis invalid
+		genesisHash, err := bc.GetHashByHeight(0)
+		if err != nil {
+			return err
+		}
+		if best, err = bc.getHeader(genesisHash); err != nil {
+			return err
+		}
+	}
+
+	if best.Hash() == bc.head.Hash() {
+		return nil
+	}
+	fmt.Printf("Activating best valid chain at %s (height %d)\n", best.Hash().ToHex(), best.Height)
+	if err := bc.reorganizeChain(&Block{Header: best}); err != nil {
+		return err
+	}
+	return bc.maybeFlushUTXOs()
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"swatantra/crypto"
+)
+
+func TestInvalidBlockRejectedOnRearrival(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+
+	bad := mineTestBlock(t, bc, bc.Head(), miner, nil)
+	bad.Header.Difficulty++
+	nonce, _, err := NewProofOfWork(bad).Run()
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+	bad.Header.Nonce = nonce
+	badHash, _ := bad.Hash()
+
+	err = bc.AddBlock(bad)
+	if err == nil || errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("First AddBlock: expected a validation error, got %v", err)
+	}
+	if _, invalid, _ := bc.InvalidReason(badHash); !invalid {
+		t.Fatal("Block failing validation should be marked invalid")
+	}
+	if err := bc.AddBlock(bad); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Re-arrival: expected ErrInvalidBlock, got %v", err)
+	}
+
+	// Turunan block invalid ditolak tanpa masuk orphan pool dan ikut ditandai.
+	child := mineTestBlock(t, bc, bad.Header, miner, nil)
+	childHash, _ := child.Hash()
+	if err := bc.AddBlock(child); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Descendant: expected ErrInvalidBlock, got %v", err)
+	}
+	if _, invalid, _ := bc.InvalidReason(childHash); !invalid {
+		t.Error("Descendant of an invalid block should be marked invalid")
+	}
+	if count, _ := bc.OrphanCount(); count != 0 {
+		t.Errorf("Descendant of an invalid block should not be kept as orphan, %d orphans", count)
+	}
+
+	// Body yang dimutasi tidak menandai hash block.
+	good := mineTestBlock(t, bc, bc.Head(), miner, nil)
+	goodHash, _ := good.Hash()
+	coinbase := good.Transactions[0]
+	mutatedCoinbase := NewTransaction(coinbase.Inputs, []*TxOutput{{Value: coinbase.Outputs[0].Value + 1, Address: miner}})
+	mutated := NewBlock(good.Header, []*Transaction{mutatedCoinbase})
+	if err := bc.AddBlock(mutated); err == nil {
+		t.Fatal("Expected an error for a mutated block body")
+	}
+	if _, invalid, _ := bc.InvalidReason(goodHash); invalid {
+		t.Error("Mutated body must not mark the block hash invalid")
+	}
+	if err := bc.AddBlock(good); err != nil {
+		t.Errorf("Original block rejected after a mutated copy: %v", err)
+	}
+}
+
+func TestInvalidateAndReconsiderBlock(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	otherKey, err := crypto.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var main []*Block
+	for i := 0; i < 3; i++ {
+		block := mineTestBlock(t, bc, bc.Head(), miner, nil)
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock at height %d failed: %v", block.Header.Height, err)
+		}
+		main = append(main, block)
+	}
+	fork := mineTestBlock(t, bc, main[0].Header, otherKey.Public().Address(), nil)
+	if err := bc.AddBlock(fork); err != nil {
+		t.Fatalf("AddBlock of fork block failed: %v", err)
+	}
+	forkHash, _ := fork.Hash()
+	block2Hash, _ := main[1].Hash()
+	block3Hash, _ := main[2].Hash()
+
+	if err := bc.InvalidateBlock(block2Hash); err != nil {
+		t.Fatalf("InvalidateBlock failed: %v", err)
+	}
+	if bc.Head().Hash() != forkHash {
+		t.Fatalf("Head after invalidate: got height %d, expected the fork block", bc.Head().Height)
+	}
+	if _, invalid, _ := bc.InvalidReason(block3Hash); !invalid {
+		t.Error("Descendant of an invalidated block should be marked invalid")
+	}
+	if err := bc.AddBlock(main[2]); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Re-adding an invalidated descendant: expected ErrInvalidBlock, got %v", err)
+	}
+
+	if err := bc.ReconsiderBlock(block3Hash); err != nil {
+		t.Fatalf("ReconsiderBlock failed: %v", err)
+	}
+	if bc.Head().Hash() != block3Hash {
+		t.Fatalf("Head after reconsider: got height %d, expected block 3", bc.Head().Height)
+	}
+	for _, hash := range []crypto.Hash{block2Hash, block3Hash} {
+		if _, invalid, _ := bc.InvalidReason(hash); invalid {
+			t.Errorf("Block %s should no longer be marked invalid", hash.ToHex())
+		}
+	}
+	if hash, err := bc.GetHashByHeight(2); err != nil || hash != block2Hash {
+		t.Errorf("Height index at 2 should point to block 2 again: %v", err)
+	}
+
+	genesisHash, _ := bc.GetHashByHeight(0)
+	if err := bc.InvalidateBlock(genesisHash); err == nil {
+		t.Error("Expected an error when invalidating the genesis block")
+	}
+}
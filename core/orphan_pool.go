@@ -0,0 +1,232 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"swatantra/crypto"
+)
+
+const (
+	// DefaultMaxOrphanBlocks adalah jumlah maksimum block orphan yang disimpan.
+	DefaultMaxOrphanBlocks = 100
+	// DefaultMaxOrphanBytes adalah total ukuran maksimum (encoded) block orphan.
+	DefaultMaxOrphanBytes = 32 << 20
+)
+
+// ErrOrphanBlock dikembalikan AddBlock jika parent block belum diketahui.
+// Block disimpan di orphan pool dan di-connect otomatis setelah parent-nya masuk.
+var ErrOrphanBlock = errors.New("parent block not found, block kept as orphan")
+
+type orphanBlock struct {
+	block *Block
+	size  int
+	seq   uint64 // Urutan masuk, untuk eviction block tertua
+}
+
+// OrphanPool menyimpan block yang datang sebelum parent-nya, dikelompokkan
+// berdasarkan hash parent yang belum ada. Jumlah block dan total ukurannya
+// dibatasi; block tertua dibuang lebih dulu jika batas terlampaui.
+type OrphanPool struct {
+	maxBlocks int
+	maxBytes  int
+
+	lock     sync.Mutex
+	blocks   map[crypto.Hash]*orphanBlock
+	byParent map[crypto.Hash][]crypto.Hash
+	bytes    int
+	nextSeq  uint64
+}
+
+// NewOrphanPool membuat orphan pool dengan batas jumlah block dan total byte.
+func NewOrphanPool(maxBlocks, maxBytes int) *OrphanPool {
+	return &OrphanPool{
+		maxBlocks: maxBlocks,
+		maxBytes:  maxBytes,
+		blocks:    make(map[crypto.Hash]*orphanBlock),
+		byParent:  make(map[crypto.Hash][]crypto.Hash),
+	}
+}
+
+// Add menyimpan block orphan. Block yang lebih besar dari batas pool ditolak.
+// Mengembalikan false jika block sudah ada di pool atau ditolak.
+func (p *OrphanPool) Add(b *Block) (bool, error) {
+	hash, err := b.Hash()
+	if err != nil {
+		return false, err
+	}
+	encoded, err := b.Encode()
+	if err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.blocks[hash]; ok {
+		return false, nil
+	}
+	if len(encoded) > p.maxBytes {
+		return false, fmt.Errorf("orphan block %s is too large (%d bytes)", hash.ToHex(), len(encoded))
+	}
+
+	p.nextSeq++
+	p.blocks[hash] = &orphanBlock{block: b, size: len(encoded), seq: p.nextSeq}
+	p.byParent[b.Header.PrevHash] = append(p.byParent[b.Header.PrevHash], hash)
+	p.bytes += len(encoded)
+
+	for len(p.blocks) > p.maxBlocks || p.bytes > p.maxBytes {
+		p.evictOldest()
+	}
+	return true, nil
+}
+
+// evictOldest membuang block orphan yang paling lama berada di pool.
+func (p *OrphanPool) evictOldest() {
+	var (
+		oldest crypto.Hash
+		seq    uint64
+	)
+	for hash, o := range p.blocks {
+		if seq == 0 || o.seq < seq {
+			oldest, seq = hash, o.seq
+		}
+	}
+	p.remove(oldest)
+}
+
+// remove menghapus block dari pool. Pemanggil harus memegang lock.
+func (p *OrphanPool) remove(hash crypto.Hash) *Block {
+	o, ok := p.blocks[hash]
+	if !ok {
+		return nil
+	}
+	delete(p.blocks, hash)
+	p.bytes -= o.size
+
+	parent := o.block.Header.PrevHash
+	siblings := p.byParent[parent]
+	for i, h := range siblings {
+		if h == hash {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(p.byParent, parent)
+	} else {
+		p.byParent[parent] = siblings
+	}
+	return o.block
+}
+
+// TakeChildren menghapus dan mengembalikan semua block orphan dengan parent tertentu.
+func (p *OrphanPool) TakeChildren(parent crypto.Hash) []*Block {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	children := append([]crypto.Hash{}, p.byParent[parent]...)
+	blocks := make([]*Block, 0, len(children))
+	for _, hash := range children {
+		if b := p.remove(hash); b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// Has memeriksa apakah block ada di pool.
+func (p *OrphanPool) Has(hash crypto.Hash) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, ok := p.blocks[hash]
+	return ok
+}
+
+// Root mengikuti rantai orphan dari hash ke belakang dan mengembalikan hash
+// parent pertama yang tidak ada di pool, yaitu ancestor yang masih hilang.
+func (p *OrphanPool) Root(hash crypto.Hash) crypto.Hash {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for {
+		o, ok := p.blocks[hash]
+		if !ok {
+			return hash
+		}
+		hash = o.block.Header.PrevHash
+	}
+}
+
+// Len mengembalikan jumlah block dan total ukuran block di pool.
+func (p *OrphanPool) Len() (int, int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.blocks), p.bytes
+}
+
+// addOrphan menyimpan block yang parent-nya belum diketahui. Proof of work
+// diperiksa lebih dulu agar pool tidak mudah diisi block sampah.
+func (bc *Blockchain) addOrphan(b *Block) error {
+	valid, err := NewProofOfWork(b).Validate()
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid proof of work")
+	}
+	if _, err := bc.orphans.Add(b); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: missing parent %s", ErrOrphanBlock, b.Header.PrevHash.ToHex())
+}
+
+// connectOrphans menambahkan block orphan yang menunggu parent tertentu, lalu
+// secara berulang turunan mereka. Block orphan yang gagal divalidasi dibuang
+// bersama seluruh turunannya di pool.
+func (bc *Blockchain) connectOrphans(parent crypto.Hash) []*Block {
+	var connected []*Block
+	queue := []crypto.Hash{parent}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		for _, orphan := range bc.orphans.TakeChildren(hash) {
+			orphanHash, _ := orphan.Hash()
+			if err := bc.addBlock(orphan); err != nil {
+				fmt.Printf("Orphan block %s rejected: %v\n", orphanHash.ToHex(), err)
+				bc.dropOrphans(orphanHash)
+				continue
+			}
+			connected = append(connected, orphan)
+			queue = append(queue, orphanHash)
+		}
+	}
+	return connected
+}
+
+// dropOrphans membuang semua block orphan yang merupakan turunan dari parent.
+func (bc *Blockchain) dropOrphans(parent crypto.Hash) {
+	queue := []crypto.Hash{parent}
+	for len(queue) > 0 {
+		children := bc.orphans.TakeChildren(queue[0])
+		queue = queue[1:]
+		for _, child := range children {
+			hash, _ := child.Hash()
+			queue = append(queue, hash)
+		}
+	}
+}
+
+// OrphanRoot mengembalikan hash ancestor paling awal yang masih hilang dari
+// rantai orphan yang berakhir di hash.
+func (bc *Blockchain) OrphanRoot(hash crypto.Hash) crypto.Hash {
+	return bc.orphans.Root(hash)
+}
+
+// IsOrphan memeriksa apakah block sedang menunggu parent-nya di orphan pool.
+func (bc *Blockchain) IsOrphan(hash crypto.Hash) bool {
+	return bc.orphans.Has(hash)
+}
+
+// OrphanCount mengembalikan jumlah block dan total byte di orphan pool.
+func (bc *Blockchain) OrphanCount() (int, int) {
+	return bc.orphans.Len()
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOrphanBlocksConnectOutOfOrder(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+
+	// Block 1..4 ditambang tanpa ditambahkan, lalu dikirim dengan urutan acak.
+	blocks := make([]*Block, 0, 4)
+	parent := bc.Head()
+	for i := 0; i < 4; i++ {
+		block := mineTestBlock(t, bc, parent, miner, nil)
+		blocks = append(blocks, block)
+		parent = block.Header
+	}
+
+	for _, i := range []int{2, 3, 1} {
+		err := bc.AddBlock(blocks[i])
+		if !errors.Is(err, ErrOrphanBlock) {
+			t.Fatalf("AddBlock at height %d: expected ErrOrphanBlock, got %v", blocks[i].Header.Height, err)
+		}
+	}
+	if count, _ := bc.OrphanCount(); count != 3 {
+		t.Fatalf("OrphanCount: got %d, expected 3", count)
+	}
+	hash4, _ := blocks[3].Hash()
+	if root := bc.OrphanRoot(hash4); root != blocks[0].Header.Hash() {
+		t.Errorf("OrphanRoot: got %s, expected the hash of block 1", root.ToHex())
+	}
+	// Block orphan yang sama tidak disimpan dua kali.
+	if err := bc.AddBlock(blocks[2]); !errors.Is(err, ErrOrphanBlock) {
+		t.Fatalf("Re-adding an orphan: expected ErrOrphanBlock, got %v", err)
+	}
+	if count, _ := bc.OrphanCount(); count != 3 {
+		t.Fatalf("OrphanCount after re-adding: got %d, expected 3", count)
+	}
+
+	connected, err := bc.ProcessBlock(blocks[0])
+	if err != nil {
+		t.Fatalf("ProcessBlock of the missing parent failed: %v", err)
+	}
+	if len(connected) != 3 {
+		t.Fatalf("Connected orphans: got %d, expected 3", len(connected))
+	}
+	if bc.Head().Hash() != hash4 {
+		t.Errorf("Head: got height %d, expected height 4", bc.Head().Height)
+	}
+	if count, size := bc.OrphanCount(); count != 0 || size != 0 {
+		t.Errorf("Orphan pool not empty after connecting: %d blocks, %d bytes", count, size)
+	}
+}
+
+func TestOrphanBlockWithInvalidDescendant(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+
+	block1 := mineTestBlock(t, bc, bc.Head(), miner, nil)
+	block2 := mineTestBlock(t, bc, block1.Header, miner, nil)
+	// Height yang salah membuat block 2 ditolak saat parent-nya tiba.
+	block2.Header.Height = 5
+	nonce, _, err := NewProofOfWork(block2).Run()
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+	block2.Header.Nonce = nonce
+	block3 := mineTestBlock(t, bc, block2.Header, miner, nil)
+
+	for _, b := range []*Block{block2, block3} {
+		if err := bc.AddBlock(b); !errors.Is(err, ErrOrphanBlock) {
+			t.Fatalf("AddBlock: expected ErrOrphanBlock, got %v", err)
+		}
+	}
+	connected, err := bc.ProcessBlock(block1)
+	if err != nil {
+		t.Fatalf("ProcessBlock failed: %v", err)
+	}
+	if len(connected) != 0 {
+		t.Errorf("Connected orphans: got %d, expected 0", len(connected))
+	}
+	if bc.Head().Height != 1 {
+		t.Errorf("Head height: got %d, expected 1", bc.Head().Height)
+	}
+	if count, _ := bc.OrphanCount(); count != 0 {
+		t.Errorf("Descendants of a rejected orphan should be dropped, %d left", count)
+	}
+}
+
+func TestOrphanPoolEviction(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+
+	blocks := make([]*Block, 0, 3)
+	parent := bc.Head()
+	for i := 0; i < 3; i++ {
+		block := mineTestBlock(t, bc, parent, miner, nil)
+		blocks = append(blocks, block)
+		parent = block.Header
+	}
+	encoded, err := blocks[0].Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Batas jumlah: block tertua dibuang lebih dulu.
+	pool := NewOrphanPool(2, DefaultMaxOrphanBytes)
+	for _, b := range blocks {
+		if _, err := pool.Add(b); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if count, _ := pool.Len(); count != 2 {
+		t.Fatalf("Len: got %d, expected 2", count)
+	}
+	if pool.Has(blocks[0].Header.Hash()) {
+		t.Error("Oldest orphan should have been evicted")
+	}
+	if children := pool.TakeChildren(blocks[1].Header.Hash()); len(children) != 1 {
+		t.Errorf("TakeChildren: got %d blocks, expected 1", len(children))
+	}
+
+	// Batas ukuran: hanya satu block yang muat.
+	pool = NewOrphanPool(DefaultMaxOrphanBlocks, len(encoded)+len(encoded)/2)
+	for _, b := range blocks[:2] {
+		if _, err := pool.Add(b); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if count, size := pool.Len(); count != 1 || size > len(encoded)+len(encoded)/2 {
+		t.Errorf("Len: got %d blocks, %d bytes, expected 1 block within the byte limit", count, size)
+	}
+	if !pool.Has(blocks[1].Header.Hash()) {
+		t.Error("Newest orphan should be kept")
+	}
+	if _, err := NewOrphanPool(DefaultMaxOrphanBlocks, 10).Add(blocks[0]); err == nil {
+		t.Error("Expected an error for an orphan larger than the pool")
+	}
+}
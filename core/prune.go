@@ -0,0 +1,96 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MinPruneDepth adalah jumlah minimum block di belakang tip yang tetap disimpan
+// utuh saat pruning aktif. Reorg yang lebih dalam dari ini tidak bisa dilakukan
+// karena data undo block yang lebih tua sudah dihapus.
+const MinPruneDepth = 100
+
+var (
+	// ErrBlockPruned dikembalikan ketika body atau data undo block sudah dihapus oleh pruning.
+	ErrBlockPruned = errors.New("block data has been pruned")
+
+	prunedHeightKey = []byte("prunedheight")
+)
+
+// PrunedHeight mengembalikan height main chain tertinggi yang body-nya sudah
+// dihapus. Nilai 0 berarti belum ada block yang di-prune.
+// Node yang dimulai dari snapshot UTXO belum memiliki body di bawah base
+// snapshot sampai history-nya selesai divalidasi.
+func (bc *Blockchain) PrunedHeight() uint32 {
+	if base := bc.SnapshotBase(); base != nil && bc.NeedsHistory() && base.Height > bc.prunedHeight {
+		return base.Height
+	}
+	return bc.prunedHeight
+}
+
+// IsPruned melaporkan apakah node tidak menyimpan seluruh body block.
+func (bc *Blockchain) IsPruned() bool {
+	return bc.pruneDepth > 0 || bc.NeedsHistory()
+}
+
+// minReorgHeight mengembalikan height terendah yang masih bisa menjadi common
+// ancestor reorg. Block di bawahnya tidak memiliki data undo.
+func (bc *Blockchain) minReorgHeight() uint32 {
+	if base := bc.SnapshotBase(); base != nil && base.Height > bc.prunedHeight {
+		return base.Height
+	}
+	return bc.prunedHeight
+}
+
+func (bc *Blockchain) loadPrunedHeight() error {
+	data, err := bc.store.Get(prunedHeightKey)
+	if err != nil {
+		// Belum pernah ada block yang di-prune
+		return nil
+	}
+	if len(data) != 4 {
+		return fmt.Errorf("corrupted pruned height record")
+	}
+	bc.prunedHeight = binary.BigEndian.Uint32(data)
+	return nil
+}
+
+// pruneBlocks menghapus body dan data undo block main chain yang lebih dari
+// pruneDepth block di belakang head. Header, UTXO set dan index tetap disimpan.
+// Genesis tidak pernah di-prune.
+func (bc *Blockchain) pruneBlocks() error {
+	// Body di bawah base snapshot masih dibutuhkan untuk validasi history
+	if bc.pruneDepth == 0 || bc.head.Height <= bc.pruneDepth || bc.NeedsHistory() {
+		return nil
+	}
+	target := bc.head.Height - bc.pruneDepth
+	if target <= bc.prunedHeight {
+		return nil
+	}
+	// Block yang belum tercakup UTXO set di store masih dibutuhkan untuk replay
+	if bc.utxoBestHeight < target {
+		if err := bc.FlushUTXOs(); err != nil {
+			return err
+		}
+	}
+
+	for height := bc.prunedHeight + 1; height <= target; height++ {
+		hash, err := bc.GetHashByHeight(height)
+		if err != nil {
+			return err
+		}
+		if err := bc.blockStore.Prune(hash); err != nil {
+			return err
+		}
+		if err := bc.store.Delete(getUndoKey(hash)); err != nil {
+			return err
+		}
+	}
+
+	bc.prunedHeight = target
+	if err := bc.store.Put(prunedHeightKey, binary.BigEndian.AppendUint32(nil, target)); err != nil {
+		return err
+	}
+	return bc.blockStore.PruneFiles(target)
+}
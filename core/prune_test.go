@@ -0,0 +1,51 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPruneBlocks(t *testing.T) {
+	bc, privKey := newTestBlockchainWithOptions(t, Options{PruneDepth: MinPruneDepth})
+	miner := privKey.Public().Address()
+
+	for i := 0; i < MinPruneDepth+3; i++ {
+		block := mineTestBlock(t, bc, bc.Head(), miner, nil)
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock at height %d failed: %v", block.Header.Height, err)
+		}
+	}
+
+	if bc.PrunedHeight() != 3 {
+		t.Fatalf("PrunedHeight: got %d, expected 3", bc.PrunedHeight())
+	}
+	for _, height := range []uint32{1, 3} {
+		if _, err := bc.GetBlockByHeight(height); !errors.Is(err, ErrBlockPruned) {
+			t.Errorf("Block at height %d: expected ErrBlockPruned, got %v", height, err)
+		}
+	}
+	for _, height := range []uint32{0, 4, bc.Head().Height} {
+		if _, err := bc.GetBlockByHeight(height); err != nil {
+			t.Errorf("Block at height %d should still be available: %v", height, err)
+		}
+	}
+
+	// Header dan height index tetap tersedia untuk block yang di-prune.
+	hash, err := bc.GetHashByHeight(2)
+	if err != nil {
+		t.Fatalf("GetHashByHeight failed: %v", err)
+	}
+	header, err := bc.blockStore.GetHeader(hash)
+	if err != nil || header.Height != 2 {
+		t.Errorf("Header of pruned block not available: %v", err)
+	}
+	if _, err := bc.GetBlocksFrom(hash, 100); !errors.Is(err, ErrBlockPruned) {
+		t.Errorf("GetBlocksFrom pruned block: expected ErrBlockPruned, got %v", err)
+	}
+}
+
+func TestPruneDepthMinimum(t *testing.T) {
+	if _, err := NewBlockchainWithOptions(nil, 10, Options{PruneDepth: MinPruneDepth - 1}); err == nil {
+		t.Error("Expected an error for a prune depth below the minimum")
+	}
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"swatantra/crypto"
+	"swatantra/storage"
+)
+
+var (
+	// ErrReindexInterrupted dikembalikan saat membuka database yang reindex-nya
+	// berhenti di tengah jalan. State turunan belum lengkap sampai reindex diulang.
+	ErrReindexInterrupted = errors.New("a previous reindex was interrupted, run reindex again")
+
+	reindexingKey = []byte("reindexing")
+)
+
+// ReindexProgress melaporkan kemajuan reindex setelah setiap block diproses.
+type ReindexProgress struct {
+	Done   int
+	Total  int
+	Height uint32
+}
+
+// derivedKey adalah prefix dan panjang key state turunan yang dihapus saat
+// reindex. Panjangnya dicek karena body block lama disimpan dengan key hash
+// mentah yang bisa berawalan sama.
+type derivedKey struct {
+	prefix []byte
+	length int
+}
+
+func chainStateKeys() []derivedKey {
+	return []derivedKey{
+		{utxoKeyPrefix, len(getUTXOKey(crypto.Hash{}, 0))},
+		{undoKeyPrefix, len(getUndoKey(crypto.Hash{}))},
+	}
+}
+
+func derivedStateKeys() []derivedKey {
+	return append(chainStateKeys(),
+		derivedKey{addrUTXOKeyPrefix, len(getAddrUTXOKey(crypto.Address{}, crypto.Hash{}, 0))},
+		derivedKey{addrHistoryKeyPrefix, len(getAddrHistoryKey(crypto.Address{}, 0, crypto.Hash{}))},
+		derivedKey{spentKeyPrefix, len(getSpentKey(crypto.Hash{}, 0))},
+		derivedKey{heightKeyPrefix, len(getHeightKey(0))},
+		derivedKey{snapshotUTXOKeyPrefix, len(getSnapshotUTXOKey(crypto.Hash{}, 0))},
+	)
+}
+
+// checkReindexable memastikan semua body block yang dibutuhkan untuk replay masih ada.
+func checkReindexable(s storage.Store) error {
+	if ok, err := s.Has(prunedHeightKey); err != nil || ok {
+		if err == nil {
+			err = fmt.Errorf("cannot reindex a pruned node: %w", ErrBlockPruned)
+		}
+		return err
+	}
+	probe := &Blockchain{store: s}
+	if err := probe.loadSnapshotState(); err != nil {
+		return err
+	}
+	if probe.NeedsHistory() {
+		return errors.New("cannot reindex before the history below the UTXO snapshot has been validated")
+	}
+	return nil
+}
+
+func wipeKeys(s storage.Store, keys []derivedKey, singles ...[]byte) error {
+	for _, k := range keys {
+		if err := deletePrefix(s, k.prefix, k.length); err != nil {
+			return err
+		}
+	}
+	for _, key := range singles {
+		if err := s.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reindex menghapus seluruh state turunan (UTXO set, data undo dan semua index)
+// lalu memutar ulang semua block yang tersimpan dari genesis dengan validasi
+// penuh. Jika BlocksDir diatur, index lokasi block dibangun ulang terlebih dahulu
+// dari file block. Block yang gagal validasi, beserta turunannya, dilewati.
+func Reindex(s storage.Store, initialDifficulty uint32, opts Options, progress func(ReindexProgress)) (*Blockchain, error) {
+	if err := checkReindexable(s); err != nil {
+		return nil, err
+	}
+	if err := s.Put(reindexingKey, []byte{1}); err != nil {
+		return nil, err
+	}
+
+	if opts.BlocksDir != "" {
+		files, err := OpenBlockFiles(opts.BlocksDir, s)
+		if err != nil {
+			return nil, err
+		}
+		_, err = files.Repair()
+		files.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	headers, err := storedHeaders(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := wipeKeys(s, derivedStateKeys(), headKey, utxoBestKey, snapshotBaseKey); err != nil {
+		return nil, err
+	}
+
+	opts.reindexing = true
+	bc, err := NewBlockchainWithOptions(s, initialDifficulty, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected := make(map[crypto.Hash]bool)
+	for i, header := range headers {
+		hash := header.Hash()
+		if rejected[header.PrevHash] {
+			rejected[hash] = true
+			continue
+		}
+		// Header dari sinkronisasi headers-first yang body-nya belum diunduh
+		if ok, err := bc.blockStore.Has(hash); err != nil {
+			return nil, err
+		} else if !ok {
+			rejected[hash] = true
+			continue
+		}
+		block, err := bc.blockStore.Get(hash)
+		if err != nil {
+			return nil, fmt.Errorf("block %s at height %d cannot be read: %w", hash.ToHex(), header.Height, err)
+		}
+		if err := bc.AddBlock(block); err != nil {
+			fmt.Printf("Reindex: block %s at height %d rejected: %v\n", hash.ToHex(), header.Height, err)
+			rejected[hash] = true
+			continue
+		}
+		if progress != nil {
+			progress(ReindexProgress{Done: i + 1, Total: len(headers), Height: header.Height})
+		}
+	}
+	return bc, bc.finishReindex()
+}
+
+// ReindexChainState membangun ulang UTXO set dan data undo dengan memutar ulang
+// block main chain dari genesis. Header, lokasi block dan head tetap dipakai.
+// Setiap transaksi divalidasi terhadap UTXO set yang sedang dibangun.
+func ReindexChainState(s storage.Store, initialDifficulty uint32, opts Options, progress func(ReindexProgress)) (*Blockchain, error) {
+	if err := checkReindexable(s); err != nil {
+		return nil, err
+	}
+	if err := s.Put(reindexingKey, []byte{1}); err != nil {
+		return nil, err
+	}
+	if err := wipeKeys(s, chainStateKeys(), utxoBestKey); err != nil {
+		return nil, err
+	}
+
+	opts.reindexing = true
+	bc, err := NewBlockchainWithOptions(s, initialDifficulty, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	total := int(bc.head.Height) + 1
+	for height := uint32(0); height <= bc.head.Height; height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return nil, fmt.Errorf("main chain block at height %d cannot be read: %w", height, err)
+		}
+		for _, tx := range block.Transactions {
+			if valid, err := bc.ValidateTransaction(tx); err != nil || !valid {
+				txHash, _ := tx.Hash()
+				return nil, fmt.Errorf("invalid transaction %s at height %d: %v", txHash.ToHex(), height, err)
+			}
+		}
+		if err := bc.updateUTXOSet(block); err != nil {
+			return nil, fmt.Errorf("could not connect block at height %d: %w", height, err)
+		}
+		if progress != nil {
+			progress(ReindexProgress{Done: int(height) + 1, Total: total, Height: height})
+		}
+	}
+	return bc, bc.finishReindex()
+}
+
+// finishReindex menulis UTXO set yang masih di cache lalu menghapus penanda reindex.
+func (bc *Blockchain) finishReindex() error {
+	if err := bc.FlushUTXOs(); err != nil {
+		return err
+	}
+	return bc.store.Delete(reindexingKey)
+}
+
+// storedHeaders mengembalikan semua header block non-genesis yang tersimpan,
+// terurut berdasarkan height sehingga parent selalu diproses lebih dulu.
+func storedHeaders(s storage.Store) ([]*Header, error) {
+	it := s.NewIterator(headerKeyPrefix)
+	defer it.Close()
+
+	keyLen := len(getHeaderKey(crypto.Hash{}))
+	var headers []*Header
+	for it.Next() {
+		if len(it.Key()) != keyLen {
+			continue
+		}
+		h := new(Header)
+		if err := h.Decode(it.Value()); err != nil {
+			return nil, err
+		}
+		if h.Height > 0 {
+			headers = append(headers, h)
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool { return headers[i].Height < headers[j].Height })
+	return headers, nil
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"swatantra/crypto"
+)
+
+func TestReindexRebuildsDerivedState(t *testing.T) {
+	store := newTestStore(t)
+	opts := Options{BlocksDir: t.TempDir(), SpentIndex: true}
+	bc, err := NewBlockchainWithOptions(store, 10, opts)
+	if err != nil {
+		t.Fatalf("NewBlockchainWithOptions failed: %v", err)
+	}
+	privKey, _ := crypto.GeneratePrivateKey()
+	miner := privKey.Public().Address()
+	alice := crypto.Address{0xa1}
+
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 600, Address: alice}, {Value: 400, Address: miner}})
+	txHash, _ := tx.Hash()
+	fork := bc.Head()
+	for _, txs := range [][]*Transaction{{tx}, nil, nil} {
+		if err := bc.AddBlock(mineTestBlock(t, bc, bc.Head(), miner, txs)); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+	}
+	// Block cabang yang lebih lemah juga ikut diputar ulang.
+	if err := bc.AddBlock(mineTestBlock(t, bc, fork, alice, nil)); err != nil {
+		t.Fatalf("AddBlock fork failed: %v", err)
+	}
+	head := bc.Head().Hash()
+	bc.Close()
+
+	// Rusak UTXO set: hapus output tx dan data undo head.
+	store.Delete(getUTXOKey(txHash, 0))
+	store.Delete(getUndoKey(head))
+
+	var reports int
+	bc, err = ReindexChainState(store, 10, opts, func(p ReindexProgress) { reports++ })
+	if err != nil {
+		t.Fatalf("ReindexChainState failed: %v", err)
+	}
+	if reports != int(bc.Head().Height)+1 {
+		t.Errorf("Expected a progress report per block, got %d", reports)
+	}
+	if ok, _ := bc.HasUTXO(txHash, 0); !ok {
+		t.Error("UTXO not restored by ReindexChainState")
+	}
+	if _, err := bc.getUndo(mustGetBlock(t, bc, head)); err != nil {
+		t.Errorf("Undo data not restored: %v", err)
+	}
+	bc.Close()
+
+	bc, err = Reindex(store, 10, opts, nil)
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if bc.Head().Hash() != head {
+		t.Fatalf("Reindex ended on a different head")
+	}
+	if balance, _, _ := bc.GetAddressBalance(alice); balance != 600 {
+		t.Errorf("Address balance after reindex: got %d, expected 600", balance)
+	}
+	if info, _ := bc.GetOutpoint(tx.Inputs[0].PrevTxHash, 0); info.Status != OutpointSpent || info.SpentBy.TxHash != txHash {
+		t.Errorf("Spent index not rebuilt: %+v", info)
+	}
+	bc.Close()
+
+	// Reindex yang terhenti harus diulang sebelum node bisa dibuka.
+	store.Put(reindexingKey, []byte{1})
+	if _, err := NewBlockchainWithOptions(store, 10, opts); !errors.Is(err, ErrReindexInterrupted) {
+		t.Fatalf("Expected ErrReindexInterrupted, got %v", err)
+	}
+}
+
+func mustGetBlock(t *testing.T, bc *Blockchain, hash crypto.Hash) *Block {
+	t.Helper()
+	block, err := bc.GetBlockByHash(hash)
+	if err != nil {
+		t.Fatalf("GetBlockByHash failed: %v", err)
+	}
+	return block
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sync"
+
+	"swatantra/crypto"
+)
+
+// DefaultSigCacheSize adalah jumlah maksimum tanda tangan di cache jika tidak diatur.
+const DefaultSigCacheSize = 50000
+
+// SigCache menyimpan tanda tangan input yang sudah terbukti valid, misalnya
+// saat transaksi masuk mempool, sehingga tidak diverifikasi ulang saat
+// transaksi yang sama muncul di block. Jika penuh, entri acak dibuang.
+type SigCache struct {
+	maxEntries int
+
+	lock    sync.RWMutex
+	entries map[crypto.Hash]struct{}
+}
+
+// NewSigCache membuat cache tanda tangan dengan kapasitas maxEntries.
+func NewSigCache(maxEntries int) *SigCache {
+	return &SigCache{
+		maxEntries: maxEntries,
+		entries:    make(map[crypto.Hash]struct{}),
+	}
+}
+
+// sigCacheKey mengikat pesan, public key dan tanda tangan menjadi satu key.
+func sigCacheKey(msg crypto.Hash, pub crypto.PublicKey, sig []byte) crypto.Hash {
+	data := make([]byte, 0, len(msg)+len(pub)+len(sig))
+	data = append(append(append(data, msg[:]...), pub...), sig...)
+	return crypto.Keccak256(data)
+}
+
+// Has memeriksa apakah tanda tangan sudah pernah diverifikasi.
+func (c *SigCache) Has(key crypto.Hash) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+// Add mencatat tanda tangan yang valid.
+func (c *SigCache) Add(key crypto.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = struct{}{}
+}
+
+// Len mengembalikan jumlah tanda tangan di cache.
+func (c *SigCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.entries)
+}
+
+// sigCheck adalah satu tanda tangan input yang harus diverifikasi.
+type sigCheck struct {
+	txHash crypto.Hash
+	input  int
+	pub    crypto.PublicKey
+	sig    []byte
+	key    crypto.Hash // Key di SigCache
+}
+
+func (c *sigCheck) err() error {
+	return fmt.Errorf("invalid signature for input %d of transaction %s", c.input, c.txHash.ToHex())
+}
+
+// sigChecks mengumpulkan tanda tangan transaksi yang belum ada di cache.
+// Panjang public key dan tanda tangan diperiksa di sini karena murah, dan
+// karena ed25519.Verify panic untuk public key dengan panjang yang salah.
+func sigChecks(tx *Transaction, cache *SigCache) ([]sigCheck, error) {
+	if tx.IsCoinbase() {
+		return nil, nil
+	}
+	txHash, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	var checks []sigCheck
+	for i, input := range tx.Inputs {
+		c := sigCheck{txHash: txHash, input: i, pub: input.PublicKey, sig: input.Signature}
+		if len(c.pub) != ed25519.PublicKeySize || len(c.sig) != ed25519.SignatureSize {
+			return nil, c.err()
+		}
+		c.key = sigCacheKey(txHash, c.pub, c.sig)
+		if cache != nil && cache.Has(c.key) {
+			continue
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// verifySigChecks memverifikasi tanda tangan secara paralel dengan paling
+// banyak workers goroutine. Begitu satu tanda tangan gagal, pekerjaan yang
+// belum dibagikan dibatalkan dan error pertama dikembalikan. Tanda tangan
+// yang valid dicatat di cache.
+func verifySigChecks(checks []sigCheck, workers int, cache *SigCache) error {
+	verify := func(c *sigCheck) bool {
+		if !c.pub.Verify(c.txHash[:], c.sig) {
+			return false
+		}
+		if cache != nil {
+			cache.Add(c.key)
+		}
+		return true
+	}
+
+	if workers > len(checks) {
+		workers = len(checks)
+	}
+	if workers <= 1 {
+		for i := range checks {
+			if !verify(&checks[i]) {
+				return checks[i].err()
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg     sync.WaitGroup
+		once   sync.Once
+		failed error
+	)
+	jobs := make(chan *sigCheck)
+	done := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				if !verify(c) {
+					once.Do(func() {
+						failed = c.err()
+						close(done)
+					})
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range checks {
+		select {
+		case jobs <- &checks[i]:
+		case <-done:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return failed
+}
+
+// verifyTxSignatures memverifikasi tanda tangan satu transaksi lewat cache.
+func (bc *Blockchain) verifyTxSignatures(tx *Transaction) error {
+	checks, err := sigChecks(tx, bc.sigCache)
+	if err != nil {
+		return err
+	}
+	return verifySigChecks(checks, 1, bc.sigCache)
+}
+
+// verifyBlockSignatures memverifikasi semua tanda tangan input block secara
+// paralel. Tanda tangan yang sudah ada di cache dilewati.
+func (bc *Blockchain) verifyBlockSignatures(b *Block) error {
+	var checks []sigCheck
+	for _, tx := range b.Transactions {
+		txChecks, err := sigChecks(tx, bc.sigCache)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, txChecks...)
+	}
+	return verifySigChecks(checks, bc.sigWorkers, bc.sigCache)
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"swatantra/crypto"
+)
+
+// signedSpends membuat satu transaksi bertanda tangan untuk setiap output tx.
+func signedSpends(t testing.TB, tx *Transaction, privKey crypto.PrivateKey) []*Transaction {
+	t.Helper()
+	txHash, _ := tx.Hash()
+	spends := make([]*Transaction, 0, len(tx.Outputs))
+	for i, output := range tx.Outputs {
+		spend := NewTransaction([]*TxInput{{PrevTxHash: txHash, PrevOutIndex: uint32(i)}}, []*TxOutput{{Value: output.Value, Address: output.Address}})
+		if err := spend.Sign(privKey); err != nil {
+			t.Fatalf("Failed to sign transaction: %v", err)
+		}
+		spends = append(spends, spend)
+	}
+	return spends
+}
+
+func fanOutOutputs(privKey crypto.PrivateKey, n int) []*TxOutput {
+	outputs := make([]*TxOutput, n)
+	for i := range outputs {
+		outputs[i] = &TxOutput{Value: 1, Address: privKey.Public().Address()}
+	}
+	return outputs
+}
+
+func TestParallelSignatureVerification(t *testing.T) {
+	bc, privKey := newTestBlockchainWithOptions(t, Options{SigWorkers: 4})
+	miner := privKey.Public().Address()
+
+	fanOut := spendGenesis(t, bc, privKey, fanOutOutputs(privKey, 32))
+	if err := bc.AddBlock(mineTestBlock(t, bc, bc.Head(), miner, []*Transaction{fanOut})); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	spends := signedSpends(t, fanOut, privKey)
+
+	// Satu tanda tangan rusak di tengah block membatalkan verifikasi.
+	bad := signedSpends(t, fanOut, privKey)
+	bad[17].Inputs[0].Signature[0] ^= 0xff
+	err := bc.AddBlock(mineTestBlock(t, bc, bc.Head(), miner, bad))
+	if err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Fatalf("Expected an invalid signature error, got %v", err)
+	}
+
+	// Hash transaksi tidak mencakup tanda tangan, jadi block valid memakai
+	// coinbase lain agar hash-nya berbeda dari block yang ditandai invalid.
+	if err := bc.AddBlock(mineTestBlock(t, bc, bc.Head(), crypto.Address{1}, spends)); err != nil {
+		t.Fatalf("AddBlock of a block with valid signatures failed: %v", err)
+	}
+
+	// Public key dengan panjang yang salah ditolak tanpa panic.
+	short := NewTransaction([]*TxInput{{PrevTxHash: crypto.Hash{1}, PublicKey: crypto.PublicKey{1, 2, 3}, Signature: make([]byte, 64)}}, nil)
+	if _, err := sigChecks(short, nil); err == nil {
+		t.Error("Expected an error for a malformed public key")
+	}
+}
+
+func TestSigCacheSkipsVerifiedSignatures(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 1000, Address: privKey.Public().Address()}})
+
+	// Validasi mempool mencatat tanda tangan di cache.
+	if valid, err := bc.ValidateTransaction(tx); err != nil || !valid {
+		t.Fatalf("ValidateTransaction failed: %v", err)
+	}
+	if bc.sigCache.Len() != 1 {
+		t.Fatalf("SigCache size: got %d, expected 1", bc.sigCache.Len())
+	}
+	checks, err := sigChecks(tx, bc.sigCache)
+	if err != nil || len(checks) != 0 {
+		t.Errorf("Cached signature should not be checked again, got %d checks (%v)", len(checks), err)
+	}
+
+	// Batas ukuran cache dipatuhi.
+	cache := NewSigCache(2)
+	for i := byte(0); i < 5; i++ {
+		cache.Add(crypto.Hash{i})
+	}
+	if cache.Len() != 2 {
+		t.Errorf("SigCache size: got %d, expected 2", cache.Len())
+	}
+}
+
+func benchmarkSigChecks(b *testing.B, workers int) {
+	privKey, _ := crypto.GeneratePrivateKey()
+	tx := NewTransaction([]*TxInput{{PrevTxHash: crypto.Hash{1}}}, fanOutOutputs(privKey, 256))
+	var checks []sigCheck
+	for _, spend := range signedSpends(b, tx, privKey) {
+		txChecks, err := sigChecks(spend, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		checks = append(checks, txChecks...)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := verifySigChecks(checks, workers, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBlockSignaturesSequential(b *testing.B) {
+	benchmarkSigChecks(b, 1)
+}
+
+func BenchmarkBlockSignaturesParallel(b *testing.B) {
+	benchmarkSigChecks(b, runtime.NumCPU())
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+
+	"swatantra/crypto"
+	"swatantra/storage"
+)
+
+var spentKeyPrefix = []byte("s") // 's' untuk outpoint -> transaksi yang menghabiskannya
+
+// SpentInfo menjelaskan transaksi yang menghabiskan sebuah outpoint.
+type SpentInfo struct {
+	TxHash     crypto.Hash // Hash transaksi yang menghabiskan outpoint
+	InputIndex uint32      // Indeks input di transaksi tersebut
+	BlockHash  crypto.Hash
+	Height     uint32
+}
+
+// Encode mengubah SpentInfo menjadi slice of bytes.
+func (si *SpentInfo) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(si); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode mengubah slice of bytes menjadi SpentInfo.
+func (si *SpentInfo) Decode(b []byte) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(si)
+}
+
+// SpentIndex memetakan outpoint yang sudah dihabiskan ke transaksi yang
+// menghabiskannya. Index ini opsional dan hanya mencakup block yang
+// di-connect selama index aktif.
+type SpentIndex struct {
+	store storage.Store
+}
+
+// NewSpentIndex membuat instance baru dari SpentIndex.
+func NewSpentIndex(s storage.Store) *SpentIndex {
+	return &SpentIndex{store: s}
+}
+
+func getSpentKey(hash crypto.Hash, index uint32) []byte {
+	key := append(append([]byte{}, spentKeyPrefix...), hash[:]...)
+	return binary.BigEndian.AppendUint32(key, index)
+}
+
+// ConnectBlock mencatat semua outpoint yang dihabiskan oleh block.
+func (si *SpentIndex) ConnectBlock(b *Block) error {
+	blockHash, _ := b.Hash()
+	for _, tx := range b.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		txHash, err := tx.Hash()
+		if err != nil {
+			return err
+		}
+		for i, input := range tx.Inputs {
+			info := &SpentInfo{
+				TxHash:     txHash,
+				InputIndex: uint32(i),
+				BlockHash:  blockHash,
+				Height:     b.Header.Height,
+			}
+			encoded, err := info.Encode()
+			if err != nil {
+				return err
+			}
+			if err := si.store.Put(getSpentKey(input.PrevTxHash, input.PrevOutIndex), encoded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock menghapus catatan outpoint yang dihabiskan oleh block.
+func (si *SpentIndex) DisconnectBlock(b *Block) error {
+	for _, tx := range b.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			if err := si.store.Delete(getSpentKey(input.PrevTxHash, input.PrevOutIndex)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Get mengembalikan SpentInfo untuk outpoint, atau nil jika tidak tercatat.
+func (si *SpentIndex) Get(hash crypto.Hash, index uint32) (*SpentInfo, error) {
+	key := getSpentKey(hash, index)
+	ok, err := si.store.Has(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := si.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	info := &SpentInfo{}
+	if err := info.Decode(data); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
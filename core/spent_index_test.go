@@ -0,0 +1,108 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"swatantra/crypto"
+)
+
+func TestSpentIndexReorg(t *testing.T) {
+	bc, privKey := newTestBlockchainWithOptions(t, Options{SpentIndex: true})
+	miner := privKey.Public().Address()
+	genesis := bc.Head()
+
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 1000, Address: miner}})
+	txHash, _ := tx.Hash()
+	prevOut := tx.Inputs[0]
+
+	info, err := bc.GetOutpoint(prevOut.PrevTxHash, prevOut.PrevOutIndex)
+	if err != nil {
+		t.Fatalf("GetOutpoint failed: %v", err)
+	}
+	if info.Status != OutpointUnspent {
+		t.Fatalf("Genesis output status: got %s, expected %s", info.Status, OutpointUnspent)
+	}
+
+	block := mineTestBlock(t, bc, genesis, miner, []*Transaction{tx})
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+
+	info, _ = bc.GetOutpoint(prevOut.PrevTxHash, prevOut.PrevOutIndex)
+	if info.Status != OutpointSpent || info.SpentBy.TxHash != txHash || info.SpentBy.Height != 1 {
+		t.Fatalf("Unexpected outpoint info after spend: %+v", info)
+	}
+
+	// Fork yang lebih panjang tanpa tx tersebut harus membatalkan catatan spent.
+	fork1 := mineTestBlock(t, bc, genesis, crypto.Address{1}, nil)
+	if err := bc.AddBlock(fork1); err != nil {
+		t.Fatalf("AddBlock fork1 failed: %v", err)
+	}
+	fork2 := mineTestBlock(t, bc, fork1.Header, crypto.Address{1}, nil)
+	if err := bc.AddBlock(fork2); err != nil {
+		t.Fatalf("AddBlock fork2 failed: %v", err)
+	}
+	if bc.Head().Hash() != fork2.Header.Hash() {
+		t.Fatalf("Expected reorg to the longer fork")
+	}
+
+	info, _ = bc.GetOutpoint(prevOut.PrevTxHash, prevOut.PrevOutIndex)
+	if info.Status != OutpointUnspent {
+		t.Errorf("Outpoint status after reorg: got %s, expected %s", info.Status, OutpointUnspent)
+	}
+	info, _ = bc.GetOutpoint(txHash, 0)
+	if info.Status != OutpointUnknown {
+		t.Errorf("Reorged-out output status: got %s, expected %s", info.Status, OutpointUnknown)
+	}
+}
+
+func TestSpentAndHeightIndexFlushedWithUTXOCache(t *testing.T) {
+	bc, privKey := newTestBlockchainWithOptions(t, Options{SpentIndex: true, UTXOCacheSize: 1000, UTXOFlushInterval: time.Hour})
+	miner := privKey.Public().Address()
+	genesis := bc.Head()
+
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 1000, Address: miner}})
+	prevOut := tx.Inputs[0]
+	block := mineTestBlock(t, bc, genesis, miner, []*Transaction{tx})
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+
+	spentKey := getSpentKey(prevOut.PrevTxHash, prevOut.PrevOutIndex)
+	if info, _ := bc.GetOutpoint(prevOut.PrevTxHash, prevOut.PrevOutIndex); info.Status != OutpointSpent {
+		t.Errorf("Unflushed spent index should be visible, got %s", info.Status)
+	}
+	if hash, err := bc.GetHashByHeight(1); err != nil || hash != block.Header.Hash() {
+		t.Errorf("Unflushed height index should be visible: %v", err)
+	}
+	for _, key := range [][]byte{spentKey, getHeightKey(1)} {
+		if ok, _ := bc.store.Has(key); ok {
+			t.Fatalf("Index key %x must not reach the store before the UTXO flush", key)
+		}
+	}
+	if err := bc.FlushUTXOs(); err != nil {
+		t.Fatalf("FlushUTXOs failed: %v", err)
+	}
+	for _, key := range [][]byte{spentKey, getHeightKey(1)} {
+		if ok, _ := bc.store.Has(key); !ok {
+			t.Errorf("Index key %x not written with the UTXO flush", key)
+		}
+	}
+
+	// Reorg menulis penghapusan spent index bersama head dan UTXO set-nya.
+	fork1 := mineTestBlock(t, bc, genesis, crypto.Address{1}, nil)
+	fork2 := mineTestBlock(t, bc, fork1.Header, crypto.Address{1}, nil)
+	for _, b := range []*Block{fork1, fork2} {
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("AddBlock fork failed: %v", err)
+		}
+	}
+	if ok, _ := bc.store.Has(spentKey); ok {
+		t.Error("Spent index entry of the disconnected block should be deleted with the reorg flush")
+	}
+	data, err := bc.store.Get(getHeightKey(1))
+	if err != nil || crypto.Hash(data) != fork1.Header.Hash() {
+		t.Error("Height index in the store should point to the fork after the reorg flush")
+	}
+}
@@ -0,0 +1,498 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"swatantra/crypto"
+	"swatantra/storage"
+)
+
+// DefaultUTXOFlushInterval adalah interval flush cache UTXO jika tidak diatur.
+const DefaultUTXOFlushInterval = 5 * time.Minute
+
+var (
+	// ErrUTXONotFound dikembalikan ketika outpoint tidak ada di UTXO set.
+	ErrUTXONotFound = errors.New("utxo not found")
+
+	// errIndexKeyNotFound dikembalikan cacheIndexStore untuk key yang dihapus
+	// tetapi penghapusannya belum di-flush.
+	errIndexKeyNotFound = errors.New("key not found")
+
+	// utxoBestKey menyimpan hash block terakhir yang UTXO-nya sudah ditulis ke store.
+	utxoBestKey = []byte("utxobest")
+)
+
+// utxoCacheEntry adalah satu outpoint di cache.
+//   - fresh: outpoint belum ada di store, sehingga jika dihabiskan sebelum flush
+//     entri cukup dibuang tanpa menulis apa pun.
+//   - dirty: entri berbeda dari isi store dan harus ditulis saat flush.
+//   - spent: outpoint sudah dihabiskan (output nil) dan harus dihapus dari store.
+type utxoCacheEntry struct {
+	output *TxOutput
+	fresh  bool
+	dirty  bool
+	spent  bool
+}
+
+// UTXOCacheStats berisi statistik penggunaan cache UTXO.
+type UTXOCacheStats struct {
+	Hits       uint64
+	Misses     uint64
+	Entries    int
+	Dirty      int
+	MaxEntries int
+	Flushes    uint64
+	LastFlush  time.Time
+}
+
+// HitRate mengembalikan rasio hit terhadap seluruh lookup, 0 jika belum ada lookup.
+func (s UTXOCacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// UTXOCache adalah cache write-back di depan UTXO set di store. Perubahan dari
+// block yang di-connect dan di-disconnect hanya ditulis ke store saat Flush,
+// bersama hash block terakhir yang tercakup, dalam satu batch atomik. Index
+// yang harus sesuai dengan UTXO set ditulis lewat IndexStore agar ikut batch
+// yang sama.
+type UTXOCache struct {
+	store      storage.Store
+	maxEntries int
+
+	lock      sync.Mutex
+	entries   map[outpoint]*utxoCacheEntry
+	pending   map[string][]byte // Penulisan index yang tertahan; nil berarti dihapus
+	dirty     int
+	hits      uint64
+	misses    uint64
+	flushes   uint64
+	lastFlush time.Time
+}
+
+// NewUTXOCache membuat cache UTXO yang menampung paling banyak maxEntries entri
+// sebelum harus di-flush.
+func NewUTXOCache(s storage.Store, maxEntries int) *UTXOCache {
+	return &UTXOCache{
+		store:      s,
+		maxEntries: maxEntries,
+		entries:    make(map[outpoint]*utxoCacheEntry),
+		pending:    make(map[string][]byte),
+		lastFlush:  time.Now(),
+	}
+}
+
+// getStoreUTXO membaca output langsung dari UTXO set di store.
+func getStoreUTXO(s storage.Store, hash crypto.Hash, index uint32) (*TxOutput, error) {
+	key := getUTXOKey(hash, index)
+	ok, err := s.Has(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrUTXONotFound
+	}
+	data, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	output := &TxOutput{}
+	if err := output.Decode(data); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// fetch mengembalikan entri untuk outpoint, memuatnya dari store jika belum ada
+// di cache. Outpoint yang tidak ada di store tidak disimpan di cache.
+func (c *UTXOCache) fetch(op outpoint) (*utxoCacheEntry, error) {
+	if entry, ok := c.entries[op]; ok {
+		c.hits++
+		return entry, nil
+	}
+	c.misses++
+	output, err := getStoreUTXO(c.store, op.hash, op.index)
+	if err != nil {
+		return nil, err
+	}
+	entry := &utxoCacheEntry{output: output}
+	c.entries[op] = entry
+	return entry, nil
+}
+
+// Get mengembalikan output yang belum dihabiskan, atau ErrUTXONotFound.
+func (c *UTXOCache) Get(hash crypto.Hash, index uint32) (*TxOutput, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, err := c.fetch(outpoint{hash, index})
+	if err != nil {
+		return nil, err
+	}
+	if entry.spent {
+		return nil, ErrUTXONotFound
+	}
+	return entry.output, nil
+}
+
+// Has memeriksa apakah outpoint ada di UTXO set.
+func (c *UTXOCache) Has(hash crypto.Hash, index uint32) (bool, error) {
+	_, err := c.Get(hash, index)
+	if errors.Is(err, ErrUTXONotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Add menambahkan output ke UTXO set.
+func (c *UTXOCache) Add(hash crypto.Hash, index uint32, output *TxOutput) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	op := outpoint{hash, index}
+	entry, ok := c.entries[op]
+	if !ok {
+		// Outpoint yang tidak ada di cache juga tidak ada di store: output baru
+		// belum pernah ditulis, dan output yang dikembalikan oleh rollback
+		// sudah dihapus dari store saat flush sebelumnya.
+		c.entries[op] = &utxoCacheEntry{output: output, fresh: true, dirty: true}
+		c.dirty++
+		return
+	}
+	if !entry.dirty {
+		c.dirty++
+	}
+	entry.output = output
+	entry.spent = false
+	entry.dirty = true
+}
+
+// Spend menghapus outpoint dari UTXO set dan mengembalikan output-nya.
+func (c *UTXOCache) Spend(hash crypto.Hash, index uint32) (*TxOutput, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	op := outpoint{hash, index}
+	entry, err := c.fetch(op)
+	if err != nil {
+		return nil, err
+	}
+	if entry.spent {
+		return nil, ErrUTXONotFound
+	}
+	output := entry.output
+	if entry.fresh {
+		// Tidak pernah ditulis ke store, jadi tidak perlu dihapus
+		delete(c.entries, op)
+		c.dirty--
+		return output, nil
+	}
+	if !entry.dirty {
+		c.dirty++
+	}
+	entry.output = nil
+	entry.spent = true
+	entry.dirty = true
+	return output, nil
+}
+
+// NeedsFlush melaporkan apakah cache melebihi ukuran maksimum atau interval flush sudah lewat.
+func (c *UTXOCache) NeedsFlush(interval time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries) > c.maxEntries || (interval > 0 && time.Since(c.lastFlush) >= interval)
+}
+
+// Flush menambahkan semua entri dirty beserta best ke batch lalu menulisnya,
+// sehingga penulisan lain di batch ikut diterapkan secara atomik. Jika cache
+// masih melebihi ukuran maksimum, semua entri dibuang.
+func (c *UTXOCache) Flush(batch storage.Batch, best crypto.Hash) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for op, entry := range c.entries {
+		if !entry.dirty {
+			continue
+		}
+		key := getUTXOKey(op.hash, op.index)
+		if entry.spent {
+			batch.Delete(key)
+			continue
+		}
+		encoded, err := entry.output.Encode()
+		if err != nil {
+			return err
+		}
+		batch.Put(key, encoded)
+	}
+	for key, value := range c.pending {
+		if value == nil {
+			batch.Delete([]byte(key))
+		} else {
+			batch.Put([]byte(key), value)
+		}
+	}
+	batch.Put(utxoBestKey, best[:])
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	c.pending = make(map[string][]byte)
+
+	for op, entry := range c.entries {
+		if entry.spent {
+			delete(c.entries, op)
+			continue
+		}
+		entry.fresh = false
+		entry.dirty = false
+	}
+	if len(c.entries) > c.maxEntries {
+		c.entries = make(map[outpoint]*utxoCacheEntry)
+	}
+	c.dirty = 0
+	c.flushes++
+	c.lastFlush = time.Now()
+	return nil
+}
+
+// IndexStore mengembalikan storage.Store yang menahan Put dan Delete di cache
+// sampai Flush berikutnya, sehingga index yang ditulis lewat store ini tidak
+// pernah berbeda dengan UTXO set di store. Pembacaan melihat penulisan yang
+// masih tertahan. Batch dari store ini langsung ditulis ke store di bawahnya.
+func (c *UTXOCache) IndexStore() storage.Store {
+	return &cacheIndexStore{Store: c.store, cache: c}
+}
+
+// cacheIndexStore adalah storage.Store yang dikembalikan UTXOCache.IndexStore.
+type cacheIndexStore struct {
+	storage.Store
+	cache *UTXOCache
+}
+
+func (s *cacheIndexStore) Put(key, value []byte) error {
+	s.cache.lock.Lock()
+	defer s.cache.lock.Unlock()
+	s.cache.pending[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *cacheIndexStore) Delete(key []byte) error {
+	s.cache.lock.Lock()
+	defer s.cache.lock.Unlock()
+	s.cache.pending[string(key)] = nil
+	return nil
+}
+
+func (s *cacheIndexStore) Get(key []byte) ([]byte, error) {
+	s.cache.lock.Lock()
+	value, ok := s.cache.pending[string(key)]
+	s.cache.lock.Unlock()
+	if !ok {
+		return s.Store.Get(key)
+	}
+	if value == nil {
+		return nil, errIndexKeyNotFound
+	}
+	return value, nil
+}
+
+func (s *cacheIndexStore) Has(key []byte) (bool, error) {
+	s.cache.lock.Lock()
+	value, ok := s.cache.pending[string(key)]
+	s.cache.lock.Unlock()
+	if !ok {
+		return s.Store.Has(key)
+	}
+	return value != nil, nil
+}
+
+// NewIterator menggabungkan isi store dengan penulisan yang masih tertahan.
+func (s *cacheIndexStore) NewIterator(prefix []byte) storage.Iterator {
+	s.cache.lock.Lock()
+	pending := make(map[string][]byte)
+	for key, value := range s.cache.pending {
+		if bytes.HasPrefix([]byte(key), prefix) {
+			pending[key] = value
+		}
+	}
+	s.cache.lock.Unlock()
+	if len(pending) == 0 {
+		return s.Store.NewIterator(prefix)
+	}
+
+	merged := make(map[string][]byte)
+	it := s.Store.NewIterator(prefix)
+	for it.Next() {
+		merged[string(it.Key())] = append([]byte{}, it.Value()...)
+	}
+	it.Close()
+	for key, value := range pending {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	keys := make([]string, 0, len(merged))
+	for key := range merged {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return &sliceIterator{keys: keys, values: merged, pos: -1}
+}
+
+// sliceIterator mengiterasi key yang sudah terurut beserta nilainya.
+type sliceIterator struct {
+	keys   []string
+	values map[string][]byte
+	pos    int
+}
+
+func (it *sliceIterator) Next() bool {
+	it.pos++
+	return it.pos < len(it.keys)
+}
+
+func (it *sliceIterator) Key() []byte {
+	return []byte(it.keys[it.pos])
+}
+
+func (it *sliceIterator) Value() []byte {
+	return it.values[it.keys[it.pos]]
+}
+
+func (it *sliceIterator) Close() {}
+
+// Stats mengembalikan statistik cache saat ini.
+func (c *UTXOCache) Stats() UTXOCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return UTXOCacheStats{
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Entries:    len(c.entries),
+		Dirty:      c.dirty,
+		MaxEntries: c.maxEntries,
+		Flushes:    c.flushes,
+		LastFlush:  c.lastFlush,
+	}
+}
+
+// addUTXO menambahkan output ke UTXO set, lewat cache jika aktif.
+func (bc *Blockchain) addUTXO(hash crypto.Hash, index uint32, output *TxOutput) error {
+	if bc.utxoCache != nil {
+		bc.utxoCache.Add(hash, index, output)
+		return nil
+	}
+	encoded, err := output.Encode()
+	if err != nil {
+		return err
+	}
+	return bc.store.Put(getUTXOKey(hash, index), encoded)
+}
+
+// spendUTXO menghapus outpoint dari UTXO set dan mengembalikan output-nya.
+func (bc *Blockchain) spendUTXO(hash crypto.Hash, index uint32) (*TxOutput, error) {
+	if bc.utxoCache != nil {
+		return bc.utxoCache.Spend(hash, index)
+	}
+	output, err := getStoreUTXO(bc.store, hash, index)
+	if err != nil {
+		return nil, err
+	}
+	return output, bc.store.Delete(getUTXOKey(hash, index))
+}
+
+// FlushUTXOs menulis semua perubahan UTXO yang masih di cache ke store
+// bersama head, sehingga head yang berpindah branch saat reorg tidak pernah
+// tersimpan tanpa UTXO set-nya. Tanpa cache, UTXO set di store selalu sesuai
+// dengan head.
+func (bc *Blockchain) FlushUTXOs() error {
+	if bc.utxoCache == nil {
+		return nil
+	}
+	headHash := bc.head.Hash()
+	batch := bc.store.NewBatch()
+	batch.Put(headKey, headHash[:])
+	if err := bc.utxoCache.Flush(batch, headHash); err != nil {
+		return err
+	}
+	bc.utxoBestHeight = bc.head.Height
+	return nil
+}
+
+// maybeFlushUTXOs melakukan flush jika cache terlalu besar atau interval flush sudah lewat.
+func (bc *Blockchain) maybeFlushUTXOs() error {
+	if bc.utxoCache == nil || !bc.utxoCache.NeedsFlush(bc.utxoFlushInterval) {
+		return nil
+	}
+	return bc.FlushUTXOs()
+}
+
+// UTXOCacheStats mengembalikan statistik cache UTXO, atau nil jika cache tidak aktif.
+func (bc *Blockchain) UTXOCacheStats() *UTXOCacheStats {
+	if bc.utxoCache == nil {
+		return nil
+	}
+	stats := bc.utxoCache.Stats()
+	return &stats
+}
+
+// Close menulis perubahan UTXO yang tertunda dan menutup file block. Store tidak ditutup.
+func (bc *Blockchain) Close() error {
+	if err := bc.FlushUTXOs(); err != nil {
+		return err
+	}
+	return bc.blockStore.Close()
+}
+
+// replayUTXOs menerapkan ulang block dari block terakhir yang UTXO-nya sudah
+// di-flush hingga head. Ini terjadi jika node berhenti tanpa sempat melakukan
+// flush; index dan data undo yang ditulis ulang bersifat idempoten.
+func (bc *Blockchain) replayUTXOs() error {
+	headHash := bc.head.Hash()
+	bc.utxoBestHeight = bc.head.Height
+
+	ok, err := bc.store.Has(utxoBestKey)
+	if err != nil {
+		return err
+	}
+	if ok {
+		data, err := bc.store.Get(utxoBestKey)
+		if err != nil {
+			return err
+		}
+		var bestHash crypto.Hash
+		copy(bestHash[:], data)
+		if bestHash != headHash {
+			if !bc.IsMainChain(bestHash) {
+				return fmt.Errorf("UTXO set at %s is not on the main chain, the chainstate must be rebuilt", bestHash.ToHex())
+			}
+			path, err := bc.getChainPath(headHash, bestHash)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Replaying %d blocks into the UTXO set...\n", len(path))
+			for i := len(path) - 1; i >= 0; i-- {
+				block, err := bc.blockStore.Get(path[i])
+				if err != nil {
+					return fmt.Errorf("could not replay block %s: %w", path[i].ToHex(), err)
+				}
+				if err := bc.updateUTXOSet(block); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if bc.utxoCache == nil {
+		// Store langsung diperbarui tanpa cache, sehingga penanda ini akan usang
+		return bc.store.Delete(utxoBestKey)
+	}
+	return bc.FlushUTXOs()
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"swatantra/crypto"
+)
+
+func TestUTXOCacheFreshDirtySpent(t *testing.T) {
+	store := newTestStore(t)
+	cache := NewUTXOCache(store, 10)
+
+	stored := crypto.Hash{1}
+	encoded, _ := (&TxOutput{Value: 7}).Encode()
+	if err := store.Put(getUTXOKey(stored, 0), encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	// Output baru yang dihabiskan sebelum flush tidak pernah menyentuh store.
+	fresh := crypto.Hash{2}
+	cache.Add(fresh, 0, &TxOutput{Value: 5})
+	if _, err := cache.Spend(fresh, 0); err != nil {
+		t.Fatalf("Spend fresh entry failed: %v", err)
+	}
+	if _, err := cache.Spend(stored, 0); err != nil {
+		t.Fatalf("Spend stored entry failed: %v", err)
+	}
+	if _, err := cache.Get(stored, 0); !errors.Is(err, ErrUTXONotFound) {
+		t.Fatalf("Spent entry should not be found, got %v", err)
+	}
+	cache.Add(crypto.Hash{3}, 1, &TxOutput{Value: 9})
+
+	if ok, _ := store.Has(getUTXOKey(stored, 0)); !ok {
+		t.Fatal("Store must not change before flush")
+	}
+	best := crypto.Hash{4}
+	if err := cache.Flush(store.NewBatch(), best); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if ok, _ := store.Has(getUTXOKey(stored, 0)); ok {
+		t.Error("Spent output still in store after flush")
+	}
+	if ok, _ := store.Has(getUTXOKey(fresh, 0)); ok {
+		t.Error("Fresh spent output was written to store")
+	}
+	if output, err := getStoreUTXO(store, crypto.Hash{3}, 1); err != nil || output.Value != 9 {
+		t.Errorf("New output not flushed: %v", err)
+	}
+	if data, err := store.Get(utxoBestKey); err != nil || crypto.Hash(data) != best {
+		t.Errorf("Best block not written with the flush: %v", err)
+	}
+
+	stats := cache.Stats()
+	if stats.Dirty != 0 || stats.Flushes != 1 {
+		t.Errorf("Unexpected stats after flush: %+v", stats)
+	}
+	if stats.Hits == 0 || stats.Misses == 0 || stats.HitRate() <= 0 || stats.HitRate() >= 1 {
+		t.Errorf("Unexpected hit statistics: %+v", stats)
+	}
+}
+
+func TestUTXOCacheReplayAfterUnflushedShutdown(t *testing.T) {
+	store := newTestStore(t)
+	opts := Options{UTXOCacheSize: 1000, UTXOFlushInterval: time.Hour}
+	bc, err := NewBlockchainWithOptions(store, 10, opts)
+	if err != nil {
+		t.Fatalf("NewBlockchainWithOptions failed: %v", err)
+	}
+	privKey, _ := crypto.GeneratePrivateKey()
+	miner := privKey.Public().Address()
+
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 1000, Address: miner}})
+	txHash, _ := tx.Hash()
+	for _, txs := range [][]*Transaction{{tx}, nil, nil} {
+		if err := bc.AddBlock(mineTestBlock(t, bc, bc.Head(), miner, txs)); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+	}
+	if ok, _ := store.Has(getUTXOKey(txHash, 0)); ok {
+		t.Fatal("UTXO should still be buffered in the cache")
+	}
+
+	// Node berhenti tanpa Close: UTXO set di store masih di genesis.
+	for _, cacheSize := range []int{1000, 0} {
+		reopened, err := NewBlockchainWithOptions(store, 10, Options{UTXOCacheSize: cacheSize})
+		if err != nil {
+			t.Fatalf("Reopen with cache size %d failed: %v", cacheSize, err)
+		}
+		if reopened.Head().Hash() != bc.Head().Hash() {
+			t.Fatal("Head changed after reopening")
+		}
+		if ok, err := reopened.HasUTXO(txHash, 0); err != nil || !ok {
+			t.Fatalf("UTXO of block 1 missing after replay: %v", err)
+		}
+		if ok, _ := store.Has(getUTXOKey(txHash, 0)); !ok {
+			t.Fatal("Replayed UTXO set was not written to store")
+		}
+	}
+}
+
+func TestUTXOCacheReorgSurvivesUnflushedShutdown(t *testing.T) {
+	store := newTestStore(t)
+	opts := Options{UTXOCacheSize: 1000, UTXOFlushInterval: time.Hour}
+	bc, err := NewBlockchainWithOptions(store, 10, opts)
+	if err != nil {
+		t.Fatalf("NewBlockchainWithOptions failed: %v", err)
+	}
+	genesis := bc.Head()
+	a1 := mineTestBlock(t, bc, genesis, crypto.Address{1}, nil)
+	if err := bc.AddBlock(a1); err != nil {
+		t.Fatalf("AddBlock a1 failed: %v", err)
+	}
+	if err := bc.FlushUTXOs(); err != nil {
+		t.Fatalf("FlushUTXOs failed: %v", err)
+	}
+	b1 := mineTestBlock(t, bc, genesis, crypto.Address{2}, nil)
+	b2 := mineTestBlock(t, bc, b1.Header, crypto.Address{2}, nil)
+	for _, b := range []*Block{b1, b2} {
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("AddBlock fork failed: %v", err)
+		}
+	}
+	if bc.Head().Hash() != b2.Header.Hash() {
+		t.Fatal("Fork with more work should become the head")
+	}
+
+	// Node berhenti tanpa Close setelah reorg; UTXO a1 sudah di-flush dan data
+	// undo-nya sudah dihapus, sehingga a1 tidak bisa di-disconnect lagi.
+	reopened, err := NewBlockchainWithOptions(store, 10, opts)
+	if err != nil {
+		t.Fatalf("Reopen after reorg failed: %v", err)
+	}
+	if reopened.Head().Hash() != b2.Header.Hash() {
+		t.Error("Head after reopening should be the fork tip")
+	}
+	coinbaseHash, _ := b2.Transactions[0].Hash()
+	if ok, err := reopened.HasUTXO(coinbaseHash, 0); err != nil || !ok {
+		t.Errorf("Coinbase of the fork tip missing after reopening: %v", err)
+	}
+}
+
+// benchmarkChain adalah chain yang dipakai bersama oleh benchmark sinkronisasi.
+var benchmarkChain []*Block
+
+// buildBenchmarkChain me-mining chain di mana setiap block menghabiskan output
+// block-block sebelumnya, sehingga setiap block memerlukan lookup UTXO.
+func buildBenchmarkChain(b *testing.B) []*Block {
+	if benchmarkChain != nil {
+		return benchmarkChain
+	}
+	bc, err := NewBlockchain(newTestStore(b), 10)
+	if err != nil {
+		b.Fatal(err)
+	}
+	privKey, _ := crypto.GeneratePrivateKey()
+	miner := privKey.Public().Address()
+
+	const outputsPerTx = 20
+	var spendable []*TxInput
+	for i := 0; i < 60; i++ {
+		var txs []*Transaction
+		if len(spendable) >= outputsPerTx {
+			inputs := spendable[:outputsPerTx]
+			spendable = spendable[outputsPerTx:]
+			outputs := make([]*TxOutput, outputsPerTx)
+			for j := range outputs {
+				outputs[j] = &TxOutput{Value: 50, Address: miner}
+			}
+			tx := NewTransaction(inputs, outputs)
+			if err := tx.Sign(privKey); err != nil {
+				b.Fatal(err)
+			}
+			txs = append(txs, tx)
+		}
+		block := mineTestBlock(b, bc, bc.Head(), miner, txs)
+		if err := bc.AddBlock(block); err != nil {
+			b.Fatal(err)
+		}
+		for _, tx := range block.Transactions {
+			txHash, _ := tx.Hash()
+			for j := range tx.Outputs {
+				spendable = append(spendable, &TxInput{PrevTxHash: txHash, PrevOutIndex: uint32(j)})
+			}
+		}
+		benchmarkChain = append(benchmarkChain, block)
+	}
+	return benchmarkChain
+}
+
+func benchmarkSync(b *testing.B, opts Options) {
+	chain := buildBenchmarkChain(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		bc, err := NewBlockchainWithOptions(newTestStore(b), 10, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		for _, block := range chain {
+			if err := bc.AddBlock(block); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := bc.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSyncWithUTXOCache(b *testing.B) {
+	benchmarkSync(b, Options{UTXOCacheSize: 100000})
+}
+
+func BenchmarkSyncWithoutUTXOCache(b *testing.B) {
+	benchmarkSync(b, Options{})
+}
@@ -0,0 +1,765 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"swatantra/crypto"
+	"swatantra/storage"
+)
+
+// Format file snapshot UTXO (semua integer big-endian):
+//
+//	magic "SWUT" | version uint16
+//	base height uint32 | base hash [32]
+//	jumlah header uint32 | (panjang uint32 | header gob) dari genesis sampai base
+//	jumlah entri uint64
+//	chunk: jumlah entri uint32 (>0) | entri 64 byte... | hash chunk [32]
+//	penutup: uint32 0 | commitment [32]
+//
+// Entri diurutkan berdasarkan (tx hash, index) dan di-encode secara kanonik
+// (tx hash | index | value | address), sehingga commitment tidak bergantung
+// pada encoding database. Commitment adalah Keccak256 dari gabungan hash
+// semua chunk, dengan SnapshotChunkSize entri per chunk.
+const (
+	snapshotMagic   = "SWUT"
+	snapshotVersion = 1
+
+	// SnapshotChunkSize adalah jumlah entri per chunk snapshot.
+	SnapshotChunkSize = 4096
+
+	snapshotEntrySize = 32 + 4 + 8 + crypto.AddressLength
+	maxSnapshotHeader = 1 << 16
+)
+
+var (
+	snapshotBaseKey       = []byte("snapshotbase")
+	snapshotUTXOKeyPrefix = []byte("v") // 'v' untuk UTXO set sementara validasi history
+
+	// ErrSnapshotNotPinned dikembalikan jika snapshot tidak cocok dengan nilai di parameter chain.
+	ErrSnapshotNotPinned = errors.New("snapshot does not match any pinned snapshot in chain parameters")
+
+	// ErrSnapshotInvalid dikembalikan jika history di bawah base snapshot gagal
+	// divalidasi. UTXO set dari snapshot tidak bisa dipakai lagi; chainstate
+	// harus dibangun ulang dengan reindex.
+	ErrSnapshotInvalid = errors.New("UTXO snapshot failed history validation, the chainstate must be rebuilt with reindex")
+)
+
+// SnapshotParams mengidentifikasi snapshot UTXO set di height tertentu.
+type SnapshotParams struct {
+	Height       uint32
+	BlockHash    crypto.Hash
+	SnapshotHash crypto.Hash
+}
+
+// snapshotState disimpan di database node yang dimulai dari snapshot.
+type snapshotState struct {
+	Params     SnapshotParams
+	NextHeight uint32 // Height berikutnya yang akan divalidasi di background
+	Validated  bool
+	Invalid    bool
+}
+
+type snapshotEntry struct {
+	TxHash  crypto.Hash
+	Index   uint32
+	Value   uint64
+	Address crypto.Address
+}
+
+func (e *snapshotEntry) marshal() []byte {
+	buf := make([]byte, 0, snapshotEntrySize)
+	buf = append(buf, e.TxHash[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, e.Index)
+	buf = binary.BigEndian.AppendUint64(buf, e.Value)
+	return append(buf, e.Address[:]...)
+}
+
+func (e *snapshotEntry) unmarshal(b []byte) {
+	copy(e.TxHash[:], b[:32])
+	e.Index = binary.BigEndian.Uint32(b[32:36])
+	e.Value = binary.BigEndian.Uint64(b[36:44])
+	copy(e.Address[:], b[44:])
+}
+
+func sortSnapshotEntries(entries []snapshotEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if c := bytes.Compare(entries[i].TxHash[:], entries[j].TxHash[:]); c != 0 {
+			return c < 0
+		}
+		return entries[i].Index < entries[j].Index
+	})
+}
+
+// utxoCommitment menghitung commitment dari entri yang sudah terurut.
+func utxoCommitment(entries []snapshotEntry) crypto.Hash {
+	var c snapshotCommitment
+	for i := range entries {
+		c.add(&entries[i])
+	}
+	return c.sum()
+}
+
+// snapshotCommitment menghitung commitment dari entri yang ditambahkan satu
+// per satu dalam urutan kanonik. Hanya chunk yang sedang diisi yang disimpan.
+type snapshotCommitment struct {
+	chunk       []byte
+	chunkHashes []byte
+}
+
+func (c *snapshotCommitment) add(e *snapshotEntry) {
+	c.chunk = append(c.chunk, e.marshal()...)
+	if len(c.chunk) == SnapshotChunkSize*snapshotEntrySize {
+		c.endChunk()
+	}
+}
+
+func (c *snapshotCommitment) endChunk() {
+	chunkHash := crypto.Keccak256(c.chunk)
+	c.chunkHashes = append(c.chunkHashes, chunkHash[:]...)
+	c.chunk = c.chunk[:0]
+}
+
+func (c *snapshotCommitment) sum() crypto.Hash {
+	if len(c.chunk) > 0 {
+		c.endChunk()
+	}
+	return crypto.Keccak256(c.chunkHashes)
+}
+
+func marshalChunk(entries []snapshotEntry) []byte {
+	buf := make([]byte, 0, len(entries)*snapshotEntrySize)
+	for i := range entries {
+		buf = append(buf, entries[i].marshal()...)
+	}
+	return buf
+}
+
+// utxoSetAt mengembalikan UTXO set main chain di height tertentu dengan
+// membatalkan block di atasnya (di memori) memakai data undo.
+func (bc *Blockchain) utxoSetAt(height uint32) ([]snapshotEntry, error) {
+	if height > bc.head.Height {
+		return nil, fmt.Errorf("height %d is above the chain head %d", height, bc.head.Height)
+	}
+
+	if err := bc.FlushUTXOs(); err != nil {
+		return nil, err
+	}
+	set := make(map[outpoint]*TxOutput)
+	it := bc.store.NewIterator(utxoKeyPrefix)
+	for it.Next() {
+		key := it.Key()
+		output := &TxOutput{}
+		if err := output.Decode(it.Value()); err != nil {
+			it.Close()
+			return nil, err
+		}
+		var op outpoint
+		copy(op.hash[:], key[len(utxoKeyPrefix):len(utxoKeyPrefix)+32])
+		op.index = binary.BigEndian.Uint32(key[len(utxoKeyPrefix)+32:])
+		set[op] = output
+	}
+	it.Close()
+
+	for h := bc.head.Height; h > height; h-- {
+		block, err := bc.GetBlockByHeight(h)
+		if err != nil {
+			return nil, fmt.Errorf("could not load block at height %d: %w", h, err)
+		}
+		undo, err := bc.getUndo(block)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range block.Transactions {
+			txHash, _ := tx.Hash()
+			for i := range tx.Outputs {
+				delete(set, outpoint{txHash, uint32(i)})
+			}
+		}
+		for _, spent := range undo.SpentUTXOs {
+			set[outpoint{spent.TxHash, spent.Index}] = spent.Output
+		}
+	}
+
+	entries := make([]snapshotEntry, 0, len(set))
+	for op, output := range set {
+		entries = append(entries, snapshotEntry{op.hash, op.index, output.Value, output.Address})
+	}
+	sortSnapshotEntries(entries)
+	return entries, nil
+}
+
+// getUndo memuat data undo sebuah block.
+func (bc *Blockchain) getUndo(b *Block) (*BlockUndo, error) {
+	blockHash, _ := b.Hash()
+	undoData, err := bc.store.Get(getUndoKey(blockHash))
+	if err != nil {
+		return nil, fmt.Errorf("could not find undo data for block %s", blockHash.ToHex())
+	}
+	undo := &BlockUndo{}
+	if err := undo.Decode(undoData); err != nil {
+		return nil, err
+	}
+	return undo, nil
+}
+
+// DumpUTXOSnapshot menulis snapshot UTXO set main chain di height tertentu ke w.
+func (bc *Blockchain) DumpUTXOSnapshot(height uint32, w io.Writer) (*SnapshotParams, error) {
+	entries, err := bc.utxoSetAt(height)
+	if err != nil {
+		return nil, err
+	}
+	baseHash, err := bc.GetHashByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(snapshotMagic)
+	buf.Write(binary.BigEndian.AppendUint16(nil, snapshotVersion))
+	buf.Write(binary.BigEndian.AppendUint32(nil, height))
+	buf.Write(baseHash[:])
+
+	buf.Write(binary.BigEndian.AppendUint32(nil, height+1))
+	for h := uint32(0); h <= height; h++ {
+		hash, err := bc.GetHashByHeight(h)
+		if err != nil {
+			return nil, err
+		}
+		header, err := bc.getHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := header.Encode()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(encoded))))
+		buf.Write(encoded)
+	}
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(len(entries))))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(entries); start += SnapshotChunkSize {
+		end := min(start+SnapshotChunkSize, len(entries))
+		chunk := marshalChunk(entries[start:end])
+		chunkHash := crypto.Keccak256(chunk)
+		if _, err := w.Write(binary.BigEndian.AppendUint32(nil, uint32(end-start))); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(chunkHash[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	commitment := utxoCommitment(entries)
+	if _, err := w.Write(binary.BigEndian.AppendUint32(nil, 0)); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(commitment[:]); err != nil {
+		return nil, err
+	}
+
+	return &SnapshotParams{Height: height, BlockHash: baseHash, SnapshotHash: commitment}, nil
+}
+
+// utxoSnapshot adalah bagian awal file snapshot: base, header dan jumlah
+// entri. Entri UTXO dibaca per chunk dengan readSnapshotChunks.
+type utxoSnapshot struct {
+	params     SnapshotParams
+	headers    []*Header
+	numEntries uint64
+}
+
+// readUTXOSnapshot membaca bagian awal snapshot sampai jumlah entri.
+func readUTXOSnapshot(r io.Reader) (*utxoSnapshot, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != snapshotMagic {
+		return nil, errors.New("not a UTXO snapshot file")
+	}
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	snap := &utxoSnapshot{}
+	if err := binary.Read(r, binary.BigEndian, &snap.params.Height); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, snap.params.BlockHash[:]); err != nil {
+		return nil, err
+	}
+
+	var numHeaders uint32
+	if err := binary.Read(r, binary.BigEndian, &numHeaders); err != nil {
+		return nil, err
+	}
+	if numHeaders != snap.params.Height+1 {
+		return nil, fmt.Errorf("snapshot has %d headers, expected %d", numHeaders, snap.params.Height+1)
+	}
+	for i := uint32(0); i < numHeaders; i++ {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		if size > maxSnapshotHeader {
+			return nil, fmt.Errorf("header %d is too large (%d bytes)", i, size)
+		}
+		encoded := make([]byte, size)
+		if _, err := io.ReadFull(r, encoded); err != nil {
+			return nil, err
+		}
+		header := &Header{}
+		if err := header.Decode(encoded); err != nil {
+			return nil, fmt.Errorf("could not decode header %d: %v", i, err)
+		}
+		snap.headers = append(snap.headers, header)
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &snap.numEntries); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// readSnapshotChunks membaca entri snapshot per chunk, memverifikasi hash
+// setiap chunk dan urutan kanonik entri, lalu memanggil put untuk setiap chunk.
+// Commitment di akhir file dicocokkan dengan hash chunk yang dibaca dan
+// disimpan di snap.params.SnapshotHash.
+func readSnapshotChunks(r io.Reader, snap *utxoSnapshot, put func([]snapshotEntry) error) error {
+	var read uint64
+	var prev snapshotEntry
+	chunkHashes := []byte{}
+	entries := make([]snapshotEntry, 0, SnapshotChunkSize)
+	for {
+		var count uint32
+		if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+			return err
+		}
+		if count == 0 {
+			break
+		}
+		if count > SnapshotChunkSize {
+			return fmt.Errorf("chunk with %d entries exceeds the chunk size", count)
+		}
+		if read+uint64(count) > snap.numEntries {
+			return fmt.Errorf("snapshot has more entries than the %d in its header", snap.numEntries)
+		}
+		chunk := make([]byte, int(count)*snapshotEntrySize)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return err
+		}
+		var expected crypto.Hash
+		if _, err := io.ReadFull(r, expected[:]); err != nil {
+			return err
+		}
+		chunkHash := crypto.Keccak256(chunk)
+		if chunkHash != expected {
+			return fmt.Errorf("checksum mismatch in chunk %d", len(chunkHashes)/32)
+		}
+		chunkHashes = append(chunkHashes, chunkHash[:]...)
+
+		entries = entries[:0]
+		for off := 0; off < len(chunk); off += snapshotEntrySize {
+			var e snapshotEntry
+			e.unmarshal(chunk[off : off+snapshotEntrySize])
+			if read > 0 {
+				if c := bytes.Compare(prev.TxHash[:], e.TxHash[:]); c > 0 || (c == 0 && prev.Index >= e.Index) {
+					return errors.New("snapshot entries are not in canonical order")
+				}
+			}
+			prev = e
+			read++
+			entries = append(entries, e)
+		}
+		if err := put(entries); err != nil {
+			return err
+		}
+	}
+	if read != snap.numEntries {
+		return fmt.Errorf("snapshot has %d entries, header says %d", read, snap.numEntries)
+	}
+
+	if _, err := io.ReadFull(r, snap.params.SnapshotHash[:]); err != nil {
+		return err
+	}
+	if crypto.Keccak256(chunkHashes) != snap.params.SnapshotHash {
+		return errors.New("snapshot commitment does not match its contents")
+	}
+	return nil
+}
+
+// verifySnapshotHeaders memeriksa bahwa header di snapshot membentuk chain
+// valid dari genesis kita sampai base, dan menghitung ulang CumulativeWork.
+func verifySnapshotHeaders(headers []*Header, genesisHash, baseHash crypto.Hash) error {
+	if headers[0].Hash() != genesisHash {
+		return errors.New("snapshot was made for a different genesis block")
+	}
+	headers[0].CumulativeWork = big.NewInt(0)
+	for i := 1; i < len(headers); i++ {
+		h, prev := headers[i], headers[i-1]
+		if h.Height != prev.Height+1 || h.PrevHash != prev.Hash() {
+			return fmt.Errorf("snapshot header %d does not link to its parent", i)
+		}
+		pow := NewProofOfWork(&Block{Header: h})
+		valid, err := pow.Validate()
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return fmt.Errorf("snapshot header %d has invalid proof of work", i)
+		}
+		h.CumulativeWork = new(big.Int).Add(prev.CumulativeWork, pow.Work())
+	}
+	if headers[len(headers)-1].Hash() != baseHash {
+		return errors.New("snapshot headers do not end at the snapshot base block")
+	}
+	return nil
+}
+
+// LoadUTXOSnapshot mengisi database kosong dengan snapshot UTXO set. Snapshot
+// harus cocok dengan salah satu nilai di pinned. Setelah dimuat, node berjalan
+// dari block base snapshot dan history di bawahnya divalidasi di background
+// (lihat ValidateSnapshotHistory). Riwayat address index di bawah base tidak
+// tersedia; hanya UTXO-nya yang diindeks.
+func LoadUTXOSnapshot(s storage.Store, initialDifficulty uint32, r io.Reader, pinned []SnapshotParams) (*SnapshotParams, error) {
+	if ok, err := s.Has(headKey); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, errors.New("database already contains a chain; load a snapshot into an empty data directory")
+	}
+
+	snap, err := readUTXOSnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+	// Commitment baru diketahui di akhir file, jadi base dicocokkan lebih dulu
+	var candidates []SnapshotParams
+	for _, p := range pinned {
+		if p.Height == snap.params.Height && p.BlockHash == snap.params.BlockHash {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrSnapshotNotPinned
+	}
+
+	genesis := CreateGenesisBlock(crypto.Address{}, 1000, initialDifficulty)
+	genesisHash, _ := genesis.Hash()
+	if err := verifySnapshotHeaders(snap.headers, genesisHash, snap.params.BlockHash); err != nil {
+		return nil, err
+	}
+
+	// UTXO set dan address index ditulis per chunk selama snapshot dibaca.
+	// Jika snapshot ternyata tidak valid, semua yang sudah ditulis dihapus lagi,
+	// begitu juga sisa load sebelumnya yang terhenti sebelum head ditulis.
+	if err := wipeKeys(s, derivedStateKeys()); err != nil {
+		return nil, err
+	}
+	err = readSnapshotChunks(r, snap, func(entries []snapshotEntry) error {
+		batch := s.NewBatch()
+		for _, e := range entries {
+			encoded, err := (&TxOutput{Value: e.Value, Address: e.Address}).Encode()
+			if err != nil {
+				return err
+			}
+			batch.Put(getUTXOKey(e.TxHash, e.Index), encoded)
+			batch.Put(getAddrUTXOKey(e.Address, e.TxHash, e.Index), encoded)
+		}
+		return batch.Write()
+	})
+	if err == nil {
+		err = ErrSnapshotNotPinned
+		for _, p := range candidates {
+			if p == snap.params {
+				err = nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		if wipeErr := wipeKeys(s, derivedStateKeys()); wipeErr != nil {
+			return nil, wipeErr
+		}
+		return nil, err
+	}
+
+	bs := NewBlockStore(s)
+	bc := &Blockchain{store: s, indexStore: s, blockStore: bs, headers: make(map[crypto.Hash]*Header)}
+	if err := bs.Put(genesis); err != nil {
+		return nil, err
+	}
+	for _, h := range snap.headers {
+		if err := bs.PutHeader(h); err != nil {
+			return nil, err
+		}
+		if err := bc.putHeightIndex(h); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.Put(addrIndexBuiltKey, []byte{1}); err != nil {
+		return nil, err
+	}
+	if err := putSnapshotState(s, &snapshotState{Params: snap.params}); err != nil {
+		return nil, err
+	}
+	if err := s.Put(headKey, snap.params.BlockHash[:]); err != nil {
+		return nil, err
+	}
+	return &snap.params, nil
+}
+
+func putSnapshotState(s storage.Store, state *snapshotState) error {
+	batch := s.NewBatch()
+	if err := putSnapshotStateBatch(batch, state); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+func putSnapshotStateBatch(batch storage.Batch, state *snapshotState) error {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(state); err != nil {
+		return err
+	}
+	batch.Put(snapshotBaseKey, buf.Bytes())
+	return nil
+}
+
+func (bc *Blockchain) loadSnapshotState() error {
+	ok, err := bc.store.Has(snapshotBaseKey)
+	if err != nil || !ok {
+		return err
+	}
+	data, err := bc.store.Get(snapshotBaseKey)
+	if err != nil {
+		return err
+	}
+	state := &snapshotState{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(state); err != nil {
+		return err
+	}
+	bc.setSnapshotState(state)
+	return nil
+}
+
+func (bc *Blockchain) snapshotState() *snapshotState {
+	bc.snapshotLock.Lock()
+	defer bc.snapshotLock.Unlock()
+	return bc.snapshot
+}
+
+func (bc *Blockchain) setSnapshotState(state *snapshotState) {
+	bc.snapshotLock.Lock()
+	defer bc.snapshotLock.Unlock()
+	bc.snapshot = state
+}
+
+// NeedsHistory melaporkan apakah node dimulai dari snapshot UTXO dan body
+// block di bawah base snapshot belum selesai divalidasi.
+func (bc *Blockchain) NeedsHistory() bool {
+	state := bc.snapshotState()
+	return state != nil && !state.Validated && !state.Invalid
+}
+
+// SnapshotBase mengembalikan parameter snapshot yang dipakai untuk memulai
+// node, atau nil jika node disinkronkan dari genesis.
+func (bc *Blockchain) SnapshotBase() *SnapshotParams {
+	state := bc.snapshotState()
+	if state == nil {
+		return nil
+	}
+	params := state.Params
+	return &params
+}
+
+// addHistoryBlock menyimpan body block di bawah base snapshot yang header-nya
+// sudah kita miliki. Header yang tersimpan dipakai, bukan header dari peer.
+func (bc *Blockchain) addHistoryBlock(b *Block) error {
+	blockHash, _ := b.Hash()
+	if !bc.IsMainChain(blockHash) {
+		return fmt.Errorf("history block %s is not on the snapshot chain", blockHash.ToHex())
+	}
+	if ok, err := bc.blockStore.Has(blockHash); err != nil || ok {
+		return err
+	}
+	mTree, err := NewMerkleTree(b.Transactions)
+	if err != nil {
+		return err
+	}
+	if mTree == nil || mTree.RootNode.Data != b.Header.MerkleRoot {
+		return errors.New("invalid merkle root")
+	}
+	header, err := bc.getHeader(blockHash)
+	if err != nil {
+		return err
+	}
+	return bc.blockStore.Put(NewBlock(header, b.Transactions))
+}
+
+func getSnapshotUTXOKey(hash crypto.Hash, index uint32) []byte {
+	key := append(append([]byte{}, snapshotUTXOKeyPrefix...), hash[:]...)
+	return binary.BigEndian.AppendUint32(key, index)
+}
+
+// ValidateSnapshotHistory memvalidasi ulang block dari genesis sampai base
+// snapshot ke UTXO set sementara, lalu membandingkan commitment-nya dengan
+// snapshot. Fungsi ini menunggu (polling setiap poll) sampai body block yang
+// dibutuhkan tersedia, dan melanjutkan progres setelah restart.
+func (bc *Blockchain) ValidateSnapshotHistory(poll time.Duration) error {
+	if !bc.NeedsHistory() {
+		return nil
+	}
+	state := *bc.snapshotState()
+
+	for height := state.NextHeight; height <= state.Params.Height; height++ {
+		hash, err := bc.GetHashByHeight(height)
+		if err != nil {
+			return err
+		}
+		// Hanya body yang belum diunduh yang ditunggu; error lain dikembalikan
+		for {
+			ok, err := bc.blockStore.Has(hash)
+			if err != nil {
+				return err
+			}
+			if ok {
+				break
+			}
+			time.Sleep(poll)
+		}
+		block, err := bc.blockStore.Get(hash)
+		if err != nil {
+			return err
+		}
+
+		// Perubahan UTXO set sementara dan progres ditulis dalam satu batch,
+		// sehingga restart selalu melanjutkan dari awal block yang belum selesai
+		batch := bc.store.NewBatch()
+		if err := bc.validateHistoryBlock(block, batch); err != nil {
+			state.Invalid = true
+			bc.setSnapshotState(&state)
+			putSnapshotState(bc.store, &state)
+			return fmt.Errorf("%w: history block %d (%s) is invalid: %v", ErrSnapshotInvalid, height, hash.ToHex(), err)
+		}
+		next := state
+		next.NextHeight = height + 1
+		if err := putSnapshotStateBatch(batch, &next); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		state = next
+		bc.setSnapshotState(&state)
+	}
+
+	// Key UTXO set sementara terurut sama seperti entri snapshot, sehingga
+	// commitment dihitung langsung dari iterator
+	var c snapshotCommitment
+	batch := bc.store.NewBatch()
+	keyLen := len(getSnapshotUTXOKey(crypto.Hash{}, 0))
+	it := bc.store.NewIterator(snapshotUTXOKeyPrefix)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != keyLen {
+			continue // Body block lama dengan hash berawalan 'v'
+		}
+		output := &TxOutput{}
+		if err := output.Decode(it.Value()); err != nil {
+			it.Close()
+			return err
+		}
+		var e snapshotEntry
+		copy(e.TxHash[:], key[len(snapshotUTXOKeyPrefix):len(snapshotUTXOKeyPrefix)+32])
+		e.Index = binary.BigEndian.Uint32(key[len(snapshotUTXOKeyPrefix)+32:])
+		e.Value, e.Address = output.Value, output.Address
+		c.add(&e)
+		batch.Delete(getSnapshotUTXOKey(e.TxHash, e.Index))
+	}
+	it.Close()
+
+	if commitment := c.sum(); commitment != state.Params.SnapshotHash {
+		state.Invalid = true
+		bc.setSnapshotState(&state)
+		putSnapshotState(bc.store, &state)
+		return fmt.Errorf("%w: history replay produced UTXO commitment %s, snapshot claims %s", ErrSnapshotInvalid, commitment.ToHex(), state.Params.SnapshotHash.ToHex())
+	}
+	state.Validated = true
+	if err := putSnapshotStateBatch(batch, &state); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	bc.setSnapshotState(&state)
+	return nil
+}
+
+// validateHistoryBlock memvalidasi block history dengan aturan header dan
+// transaksi yang sama seperti addBlock, terhadap UTXO set sementara, lalu
+// menambahkan perubahannya pada UTXO set tersebut ke batch. Parent dan
+// ancestor untuk median-time-past dimuat dari header yang tersimpan, bukan
+// dari header di memori, karena validasi ini berjalan di goroutine sendiri.
+func (bc *Blockchain) validateHistoryBlock(b *Block, batch storage.Batch) error {
+	if b.Header.Height > 0 {
+		prevHeader, err := bc.blockStore.GetHeader(b.Header.PrevHash)
+		if err != nil {
+			return fmt.Errorf("parent block %s not found for validation: %v", b.Header.PrevHash.ToHex(), err)
+		}
+		if err := bc.checkHeaderWithParent(b.Header, prevHeader, bc.blockStore.GetHeader); err != nil {
+			return err
+		}
+	}
+	if err := checkMerkleRoot(b); err != nil {
+		return err
+	}
+	hasUTXO := func(hash crypto.Hash, index uint32) (bool, error) {
+		return bc.store.Has(getSnapshotUTXOKey(hash, index))
+	}
+	if err := checkBlockInputs(b, hasUTXO); err != nil {
+		return err
+	}
+	if err := bc.verifyBlockSignatures(b); err != nil {
+		return err
+	}
+
+	for _, tx := range b.Transactions {
+		if !tx.IsCoinbase() {
+			for _, input := range tx.Inputs {
+				batch.Delete(getSnapshotUTXOKey(input.PrevTxHash, input.PrevOutIndex))
+			}
+		}
+		txHash, err := tx.Hash()
+		if err != nil {
+			return err
+		}
+		for i, output := range tx.Outputs {
+			encoded, err := output.Encode()
+			if err != nil {
+				return err
+			}
+			batch.Put(getSnapshotUTXOKey(txHash, uint32(i)), encoded)
+		}
+	}
+	return nil
+}
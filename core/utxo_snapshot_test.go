@@ -0,0 +1,180 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"swatantra/crypto"
+)
+
+func TestUTXOSnapshotDumpAndLoad(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+
+	alicePriv, _ := crypto.GeneratePrivateKey()
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{
+		{Value: 700, Address: alicePriv.Public().Address()},
+		{Value: 300, Address: miner},
+	})
+	blocks := []*Block{mineTestBlock(t, bc, bc.Head(), miner, []*Transaction{tx})}
+	if err := bc.AddBlock(blocks[0]); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		block := mineTestBlock(t, bc, bc.Head(), miner, nil)
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	// Snapshot di height 2 harus membatalkan block 3 memakai data undo.
+	buf := new(bytes.Buffer)
+	params, err := bc.DumpUTXOSnapshot(2, buf)
+	if err != nil {
+		t.Fatalf("DumpUTXOSnapshot failed: %v", err)
+	}
+	if params.BlockHash != blocks[1].Header.Hash() {
+		t.Fatalf("Snapshot base is not the block at height 2")
+	}
+
+	again := new(bytes.Buffer)
+	if _, err := bc.DumpUTXOSnapshot(2, again); err != nil || !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Fatalf("Snapshot is not deterministic")
+	}
+
+	if _, err := LoadUTXOSnapshot(newTestStore(t), 10, bytes.NewReader(buf.Bytes()), nil); !errors.Is(err, ErrSnapshotNotPinned) {
+		t.Fatalf("Expected ErrSnapshotNotPinned, got %v", err)
+	}
+
+	corrupted := append([]byte{}, buf.Bytes()...)
+	corrupted[len(corrupted)-40] ^= 0xff
+	if _, err := LoadUTXOSnapshot(newTestStore(t), 10, bytes.NewReader(corrupted), []SnapshotParams{*params}); err == nil {
+		t.Fatal("Expected corrupted snapshot to be rejected")
+	}
+
+	// Chunk ditulis sebelum commitment di akhir file dibaca; snapshot yang
+	// ditolak tidak boleh meninggalkan UTXO set di store.
+	store := newTestStore(t)
+	badCommitment := append([]byte{}, buf.Bytes()...)
+	badCommitment[len(badCommitment)-1] ^= 0xff
+	if _, err := LoadUTXOSnapshot(store, 10, bytes.NewReader(badCommitment), []SnapshotParams{*params}); err == nil {
+		t.Fatal("Expected snapshot with a wrong commitment to be rejected")
+	}
+	for _, prefix := range [][]byte{utxoKeyPrefix, addrUTXOKeyPrefix} {
+		it := store.NewIterator(prefix)
+		if it.Next() {
+			t.Errorf("Rejected snapshot left key %x in the store", it.Key())
+		}
+		it.Close()
+	}
+
+	if _, err := LoadUTXOSnapshot(store, 10, bytes.NewReader(buf.Bytes()), []SnapshotParams{*params}); err != nil {
+		t.Fatalf("LoadUTXOSnapshot failed: %v", err)
+	}
+	loaded, err := NewBlockchain(store, 10)
+	if err != nil {
+		t.Fatalf("NewBlockchain on snapshot failed: %v", err)
+	}
+	if loaded.Head().Hash() != params.BlockHash || !loaded.NeedsHistory() {
+		t.Fatalf("Loaded chain should start at the snapshot base and need history")
+	}
+	if loaded.PrunedHeight() != 2 {
+		t.Errorf("Snapshot node should advertise bodies up to height 2 as missing, got %d", loaded.PrunedHeight())
+	}
+	balance, _, _ := loaded.GetAddressBalance(alicePriv.Public().Address())
+	if balance != 700 {
+		t.Errorf("Alice balance after load: got %d, expected 700", balance)
+	}
+
+	// Block baru di atas base bisa langsung di-connect.
+	if err := loaded.AddBlock(blocks[2]); err != nil {
+		t.Fatalf("AddBlock on top of snapshot failed: %v", err)
+	}
+	if loaded.Head().Hash() != blocks[2].Header.Hash() {
+		t.Fatal("Loaded chain did not advance past the snapshot base")
+	}
+
+	// History dilengkapi lalu divalidasi terhadap commitment snapshot.
+	for _, b := range blocks[:2] {
+		if err := loaded.AddBlock(b); err != nil {
+			t.Fatalf("Adding history block failed: %v", err)
+		}
+	}
+	if err := loaded.ValidateSnapshotHistory(time.Millisecond); err != nil {
+		t.Fatalf("ValidateSnapshotHistory failed: %v", err)
+	}
+	if loaded.NeedsHistory() {
+		t.Error("History should be validated")
+	}
+}
+
+func TestInvalidSnapshotRefusesToOpen(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := NewBlockchain(store, 10); err != nil {
+		t.Fatalf("NewBlockchain failed: %v", err)
+	}
+	if err := putSnapshotState(store, &snapshotState{Invalid: true}); err != nil {
+		t.Fatalf("putSnapshotState failed: %v", err)
+	}
+	if _, err := NewBlockchain(store, 10); !errors.Is(err, ErrSnapshotInvalid) {
+		t.Errorf("Expected ErrSnapshotInvalid, got %v", err)
+	}
+}
+
+func TestValidateHistoryBlockUsesBlockRules(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+	genesis, err := bc.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatalf("GetBlockByHeight failed: %v", err)
+	}
+	batch := bc.store.NewBatch()
+	if err := bc.validateHistoryBlock(genesis, batch); err != nil {
+		t.Fatalf("Validating genesis failed: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("Writing genesis UTXOs failed: %v", err)
+	}
+	remine := func(b *Block) *Block {
+		nonce, _, err := NewProofOfWork(b).Run()
+		if err != nil {
+			t.Fatalf("Failed to mine block: %v", err)
+		}
+		b.Header.Nonce = nonce
+		return b
+	}
+
+	badDifficulty := mineTestBlock(t, bc, genesis.Header, miner, nil)
+	badDifficulty.Header.Difficulty++
+	if err := bc.validateHistoryBlock(remine(badDifficulty), bc.store.NewBatch()); err == nil {
+		t.Error("History block with the wrong difficulty should be rejected")
+	}
+
+	early := mineTestBlock(t, bc, genesis.Header, miner, nil)
+	early.Header.Timestamp = genesis.Header.Timestamp
+	early.Header.Difficulty, early.Header.EMABlockTime = bc.CalculateNextDifficulty(genesis.Header, early.Header.Timestamp)
+	if err := bc.validateHistoryBlock(remine(early), bc.store.NewBatch()); err == nil {
+		t.Error("History block not after median time past should be rejected")
+	}
+
+	// Output yang dibuat di block yang sama tidak boleh langsung dihabiskan,
+	// sama seperti di checkBlockTransactions.
+	tx := spendGenesis(t, bc, privKey, []*TxOutput{{Value: 1000, Address: miner}})
+	txHash, _ := tx.Hash()
+	chained := NewTransaction([]*TxInput{{PrevTxHash: txHash, PrevOutIndex: 0}}, []*TxOutput{{Value: 1000, Address: miner}})
+	if err := chained.Sign(privKey); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	intraBlock := mineTestBlock(t, bc, genesis.Header, miner, []*Transaction{tx, chained})
+	if err := bc.validateHistoryBlock(intraBlock, bc.store.NewBatch()); err == nil {
+		t.Error("History block spending an output created in the same block should be rejected")
+	}
+
+	valid := mineTestBlock(t, bc, genesis.Header, miner, []*Transaction{tx})
+	if err := bc.validateHistoryBlock(valid, bc.store.NewBatch()); err != nil {
+		t.Errorf("Valid history block rejected: %v", err)
+	}
+}
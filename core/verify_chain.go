@@ -0,0 +1,292 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"swatantra/crypto"
+)
+
+// Level pemeriksaan VerifyChain. Setiap level juga menjalankan level di bawahnya.
+const (
+	VerifyLevelHeaders   = iota // Keterkaitan header, height dan height index
+	VerifyLevelBlocks           // Proof of work dan merkle root body block
+	VerifyLevelWork             // CumulativeWork dihitung ulang dari parent
+	VerifyLevelUndo             // Data undo ada dan cocok dengan input block
+	VerifyLevelReconnect        // Block di-disconnect lalu di-connect ulang memakai data undo
+)
+
+// InconsistencyError menjelaskan inkonsistensi pertama yang ditemukan VerifyChain.
+type InconsistencyError struct {
+	Level  int
+	Height uint32
+	Hash   crypto.Hash
+	Reason string
+}
+
+func (e *InconsistencyError) Error() string {
+	return fmt.Sprintf("level %d check failed at height %d (%s): %s", e.Level, e.Height, e.Hash.ToHex(), e.Reason)
+}
+
+// verifiedBlock adalah block main chain yang sudah diperiksa dan siap diputar
+// ulang pada level VerifyLevelReconnect.
+type verifiedBlock struct {
+	hash  crypto.Hash
+	block *Block
+	undo  *BlockUndo
+}
+
+// VerifyChain memeriksa depth block terakhir main chain (0 berarti hingga
+// genesis) dari head ke belakang pada level tertentu. Inkonsistensi pertama
+// dikembalikan sebagai *InconsistencyError; error lain berarti pemeriksaan
+// tidak bisa dijalankan. Body block yang sudah di-prune hanya diperiksa header-nya.
+func (bc *Blockchain) VerifyChain(depth uint32, level int, progress func(height uint32)) error {
+	if level < VerifyLevelHeaders || level > VerifyLevelReconnect {
+		return fmt.Errorf("invalid verify level %d", level)
+	}
+	if level >= VerifyLevelReconnect {
+		if err := bc.FlushUTXOs(); err != nil {
+			return err
+		}
+	}
+
+	var (
+		checked   []verifiedBlock
+		reconnect = level >= VerifyLevelReconnect
+	)
+	hash := bc.head.Hash()
+	for n := uint32(0); depth == 0 || n < depth; n++ {
+		header, err := bc.blockStore.GetHeader(hash)
+		if err != nil {
+			return &InconsistencyError{VerifyLevelHeaders, bc.head.Height - n, hash, fmt.Sprintf("header cannot be read: %v", err)}
+		}
+		fail := func(level int, format string, args ...interface{}) error {
+			return &InconsistencyError{level, header.Height, hash, fmt.Sprintf(format, args...)}
+		}
+
+		if header.Hash() != hash {
+			return fail(VerifyLevelHeaders, "stored header hashes to %s", header.Hash().ToHex())
+		}
+		if header.Height != bc.head.Height-n {
+			return fail(VerifyLevelHeaders, "expected height %d, header says %d", bc.head.Height-n, header.Height)
+		}
+		if indexed, err := bc.GetHashByHeight(header.Height); err != nil || indexed != hash {
+			return fail(VerifyLevelHeaders, "height index points to %s (%v)", indexed.ToHex(), err)
+		}
+		if header.Height == 0 && !header.PrevHash.IsZero() {
+			return fail(VerifyLevelHeaders, "genesis has non-zero previous hash %s", header.PrevHash.ToHex())
+		}
+
+		var block *Block
+		hasBody := header.Height == 0 || header.Height > bc.PrunedHeight()
+		if level >= VerifyLevelBlocks && hasBody {
+			block, err = bc.blockStore.Get(hash)
+			if err != nil {
+				return fail(VerifyLevelBlocks, "block body cannot be read: %v", err)
+			}
+			if block.Header.Hash() != hash {
+				return fail(VerifyLevelBlocks, "block body header hashes to %s", block.Header.Hash().ToHex())
+			}
+			if valid, err := NewProofOfWork(block).Validate(); err != nil || !valid {
+				return fail(VerifyLevelBlocks, "invalid proof of work (%v)", err)
+			}
+			mTree, err := NewMerkleTree(block.Transactions)
+			if err != nil {
+				return fail(VerifyLevelBlocks, "merkle tree cannot be built: %v", err)
+			}
+			if mTree.RootNode.Data != header.MerkleRoot {
+				return fail(VerifyLevelBlocks, "merkle root is %s, header says %s", mTree.RootNode.Data.ToHex(), header.MerkleRoot.ToHex())
+			}
+		}
+
+		if level >= VerifyLevelWork {
+			if err := bc.verifyCumulativeWork(header); err != nil {
+				return fail(VerifyLevelWork, "%v", err)
+			}
+		}
+
+		if level >= VerifyLevelUndo && block != nil && header.Height > 0 && header.Height > bc.minReorgHeight() {
+			undo, err := bc.getUndo(block)
+			if err != nil {
+				return fail(VerifyLevelUndo, "%v", err)
+			}
+			if err := checkUndoMatchesInputs(block, undo); err != nil {
+				return fail(VerifyLevelUndo, "%v", err)
+			}
+			if reconnect {
+				checked = append(checked, verifiedBlock{hash, block, undo})
+			}
+		} else {
+			// Block di bawah ini tidak bisa di-disconnect, jadi pemutaran ulang berhenti di sini
+			reconnect = false
+		}
+
+		if progress != nil {
+			progress(header.Height)
+		}
+		if header.Height == 0 {
+			break
+		}
+		hash = header.PrevHash
+	}
+
+	if level >= VerifyLevelReconnect {
+		return bc.verifyReconnect(checked)
+	}
+	return nil
+}
+
+// verifyCumulativeWork memeriksa bahwa CumulativeWork header sama dengan milik
+// parent ditambah work block itu sendiri.
+func (bc *Blockchain) verifyCumulativeWork(header *Header) error {
+	if header.CumulativeWork == nil {
+		return fmt.Errorf("cumulative work is missing")
+	}
+	if header.Height == 0 {
+		if header.CumulativeWork.Sign() != 0 {
+			return fmt.Errorf("genesis cumulative work is %s, expected 0", header.CumulativeWork)
+		}
+		return nil
+	}
+	parent, err := bc.blockStore.GetHeader(header.PrevHash)
+	if err != nil {
+		return fmt.Errorf("parent header cannot be read: %v", err)
+	}
+	if parent.CumulativeWork == nil {
+		return fmt.Errorf("parent cumulative work is missing")
+	}
+	expected := new(big.Int).Add(parent.CumulativeWork, NewProofOfWork(&Block{Header: header}).Work())
+	if header.CumulativeWork.Cmp(expected) != 0 {
+		return fmt.Errorf("cumulative work is %s, expected %s", header.CumulativeWork, expected)
+	}
+	return nil
+}
+
+// checkUndoMatchesInputs memastikan data undo berisi tepat satu entri untuk
+// setiap input non-coinbase, dalam urutan yang sama seperti updateUTXOSet.
+func checkUndoMatchesInputs(b *Block, undo *BlockUndo) error {
+	i := 0
+	for _, tx := range b.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			if i >= len(undo.SpentUTXOs) {
+				return fmt.Errorf("undo data has %d entries, block spends more outputs", len(undo.SpentUTXOs))
+			}
+			s := undo.SpentUTXOs[i]
+			if s.TxHash != input.PrevTxHash || s.Index != input.PrevOutIndex {
+				return fmt.Errorf("undo entry %d is %s:%d, input spends %s:%d", i, s.TxHash.ToHex(), s.Index, input.PrevTxHash.ToHex(), input.PrevOutIndex)
+			}
+			if s.Output == nil {
+				return fmt.Errorf("undo entry %d has no output", i)
+			}
+			i++
+		}
+	}
+	if i != len(undo.SpentUTXOs) {
+		return fmt.Errorf("undo data has %d entries, block spends %d outputs", len(undo.SpentUTXOs), i)
+	}
+	return nil
+}
+
+// utxoView adalah lapisan UTXO di memori di atas UTXO set main chain.
+// Nilai nil berarti outpoint dihabiskan di view.
+type utxoView struct {
+	bc      *Blockchain
+	changes map[outpoint]*TxOutput
+}
+
+func (v *utxoView) get(op outpoint) (*TxOutput, error) {
+	if output, ok := v.changes[op]; ok {
+		return output, nil
+	}
+	output, err := v.bc.GetUTXO(op.hash, op.index)
+	if errors.Is(err, ErrUTXONotFound) {
+		return nil, nil
+	}
+	return output, err
+}
+
+func sameOutput(a, b *TxOutput) bool {
+	return a.Value == b.Value && a.Address == b.Address
+}
+
+// verifyReconnect men-disconnect block (dari head ke belakang) di view memori
+// memakai data undo, lalu men-connect ulang, dan memastikan UTXO set akhirnya
+// sama dengan UTXO set yang tersimpan. Output yang dikembalikan data undo
+// dibandingkan dengan transaksi pembuatnya jika block tersebut ikut di-disconnect.
+func (bc *Blockchain) verifyReconnect(blocks []verifiedBlock) error {
+	view := &utxoView{bc: bc, changes: make(map[outpoint]*TxOutput)}
+
+	for _, vb := range blocks {
+		fail := func(format string, args ...interface{}) error {
+			return &InconsistencyError{VerifyLevelReconnect, vb.block.Header.Height, vb.hash, "disconnect: " + fmt.Sprintf(format, args...)}
+		}
+		for _, tx := range vb.block.Transactions {
+			txHash, _ := tx.Hash()
+			for i, output := range tx.Outputs {
+				op := outpoint{txHash, uint32(i)}
+				current, err := view.get(op)
+				if err != nil {
+					return err
+				}
+				if current == nil || !sameOutput(current, output) {
+					return fail("output %s:%d is not unspent in the UTXO set", txHash.ToHex(), i)
+				}
+				view.changes[op] = nil
+			}
+		}
+		for _, s := range vb.undo.SpentUTXOs {
+			op := outpoint{s.TxHash, s.Index}
+			current, err := view.get(op)
+			if err != nil {
+				return err
+			}
+			if current != nil {
+				return fail("restored output %s:%d is already unspent", s.TxHash.ToHex(), s.Index)
+			}
+			view.changes[op] = s.Output
+		}
+	}
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		vb := blocks[i]
+		fail := func(format string, args ...interface{}) error {
+			return &InconsistencyError{VerifyLevelReconnect, vb.block.Header.Height, vb.hash, "reconnect: " + fmt.Sprintf(format, args...)}
+		}
+		for _, s := range vb.undo.SpentUTXOs {
+			op := outpoint{s.TxHash, s.Index}
+			current, err := view.get(op)
+			if err != nil {
+				return err
+			}
+			if current == nil || !sameOutput(current, s.Output) {
+				return fail("input %s:%d does not match its undo record", s.TxHash.ToHex(), s.Index)
+			}
+			view.changes[op] = nil
+		}
+		for _, tx := range vb.block.Transactions {
+			txHash, _ := tx.Hash()
+			for j, output := range tx.Outputs {
+				view.changes[outpoint{txHash, uint32(j)}] = output
+			}
+		}
+	}
+
+	for op, output := range view.changes {
+		stored, err := bc.GetUTXO(op.hash, op.index)
+		if err != nil && !errors.Is(err, ErrUTXONotFound) {
+			return err
+		}
+		if (output == nil) != (stored == nil) || (output != nil && !sameOutput(output, stored)) {
+			height, hash := bc.head.Height, bc.head.Hash()
+			if len(blocks) > 0 {
+				height, hash = blocks[0].block.Header.Height, blocks[0].hash
+			}
+			return &InconsistencyError{VerifyLevelReconnect, height, hash, fmt.Sprintf("UTXO %s:%d differs from the stored UTXO set after reconnecting", op.hash.ToHex(), op.index)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestVerifyChainLevels(t *testing.T) {
+	bc, privKey := newTestBlockchain(t)
+	miner := privKey.Public().Address()
+
+	block1 := mineTestBlock(t, bc, bc.Head(), miner, nil)
+	if err := bc.AddBlock(block1); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	coinbaseHash, _ := block1.Transactions[0].Hash()
+	tx := NewTransaction([]*TxInput{{PrevTxHash: coinbaseHash, PrevOutIndex: 0}}, []*TxOutput{{Value: 50, Address: miner}})
+	if err := tx.Sign(privKey); err != nil {
+		t.Fatal(err)
+	}
+	for _, txs := range [][]*Transaction{{tx}, nil} {
+		if err := bc.AddBlock(mineTestBlock(t, bc, bc.Head(), miner, txs)); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+	}
+	for level := VerifyLevelHeaders; level <= VerifyLevelReconnect; level++ {
+		if err := bc.VerifyChain(0, level, nil); err != nil {
+			t.Fatalf("Level %d on a consistent chain failed: %v", level, err)
+		}
+	}
+
+	expectInconsistency := func(level int, height uint32) {
+		t.Helper()
+		var inconsistency *InconsistencyError
+		err := bc.VerifyChain(0, VerifyLevelReconnect, nil)
+		if !errors.As(err, &inconsistency) {
+			t.Fatalf("Expected an inconsistency, got %v", err)
+		}
+		if inconsistency.Level != level || inconsistency.Height != height {
+			t.Fatalf("Expected level %d at height %d, got %v", level, height, inconsistency)
+		}
+	}
+
+	// Nilai output di data undo yang salah hanya terdeteksi saat block yang
+	// membuat output tersebut (block 1) ikut di-disconnect.
+	block2, _ := bc.GetBlockByHeight(2)
+	undo, err := bc.getUndo(block2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *undo.SpentUTXOs[0].Output
+	undo.SpentUTXOs[0].Output = &TxOutput{Value: original.Value + 1, Address: original.Address}
+	putUndo(t, bc, block2, undo)
+	if err := bc.VerifyChain(0, VerifyLevelUndo, nil); err != nil {
+		t.Fatalf("Level 3 should not detect a wrong undo value: %v", err)
+	}
+	expectInconsistency(VerifyLevelReconnect, 1)
+	undo.SpentUTXOs[0].Output = &original
+	putUndo(t, bc, block2, undo)
+
+	// Data undo yang tidak cocok dengan input block.
+	undo.SpentUTXOs[0].Index++
+	putUndo(t, bc, block2, undo)
+	expectInconsistency(VerifyLevelUndo, 2)
+	undo.SpentUTXOs[0].Index--
+	putUndo(t, bc, block2, undo)
+
+	// CumulativeWork header yang salah.
+	header := *bc.Head()
+	header.CumulativeWork = new(big.Int).Add(header.CumulativeWork, big.NewInt(1))
+	if err := bc.blockStore.PutHeader(&header); err != nil {
+		t.Fatal(err)
+	}
+	expectInconsistency(VerifyLevelWork, bc.Head().Height)
+	if err := bc.blockStore.PutHeader(bc.Head()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Height index yang hilang.
+	bc.deleteHeightIndex(1)
+	expectInconsistency(VerifyLevelHeaders, 1)
+}
+
+func putUndo(t *testing.T, bc *Blockchain, b *Block, undo *BlockUndo) {
+	t.Helper()
+	hash, _ := b.Hash()
+	data, err := undo.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.store.Put(getUndoKey(hash), data); err != nil {
+		t.Fatal(err)
+	}
+}
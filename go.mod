@@ -4,12 +4,15 @@ go 1.24.0
 
 toolchain go1.24.7
 
+require (
+	github.com/spf13/cobra v1.10.1
+	github.com/syndtr/goleveldb v1.0.0
+	golang.org/x/crypto v0.42.0
+)
+
 require (
 	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.10.1 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
-	github.com/syndtr/goleveldb v1.0.0 // indirect
-	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 )
@@ -2,6 +2,7 @@ package mempool
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 
 	"swatantra/core"
@@ -10,6 +11,9 @@ import (
 
 var (
 	ErrTxInMempool = errors.New("transaction already in mempool")
+	// ErrInvalidTx membungkus error validasi transaksi yang pasti invalid,
+	// berbeda dengan transaksi yang input-nya belum kita ketahui.
+	ErrInvalidTx = errors.New("invalid transaction")
 )
 
 // Mempool adalah cache untuk transaksi yang belum dikonfirmasi.
@@ -51,11 +55,14 @@ func (mp *Mempool) Add(tx *core.Transaction) error {
 
 	// Validasi transaksi terhadap state blockchain saat ini
 	valid, err := mp.blockchain.ValidateTransaction(tx)
-	if err != nil {
+	if errors.Is(err, core.ErrMissingInputs) {
 		return err
 	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTx, err)
+	}
 	if !valid {
-		return errors.New("invalid transaction")
+		return ErrInvalidTx
 	}
 
 	mp.pool[txHash] = tx
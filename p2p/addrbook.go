@@ -0,0 +1,252 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxNewAddresses membatasi alamat yang belum pernah berhasil dihubungi.
+	maxNewAddresses = 2000
+	// maxTriedAddresses membatasi alamat yang pernah berhasil dihubungi.
+	maxTriedAddresses = 500
+	// maxAddrAttempts adalah jumlah percobaan gagal sebelum alamat baru dibuang.
+	maxAddrAttempts = 5
+	// addrRetryInterval dikalikan jumlah percobaan gagal untuk jeda sebelum
+	// alamat dicoba lagi.
+	addrRetryInterval = time.Minute
+)
+
+// KnownAddress adalah alamat peer di AddrBook.
+type KnownAddress struct {
+	Addr        string    `json:"addr"`
+	LastSeen    time.Time `json:"lastSeen"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	Attempts    int       `json:"attempts,omitempty"` // Percobaan gagal sejak koneksi terakhir berhasil
+	Tried       bool      `json:"tried"`
+}
+
+// AddrBook menyimpan alamat peer yang diketahui dalam dua bucket: "new" untuk
+// alamat dari gossip yang belum pernah berhasil dihubungi, dan "tried" untuk
+// alamat yang pernah berhasil. Isinya disimpan sebagai JSON di path-nya.
+type AddrBook struct {
+	lock   sync.Mutex
+	path   string
+	addrs  map[string]*KnownAddress
+	nTried int
+}
+
+// NewAddrBook membuat AddrBook dan memuat isinya dari path jika file sudah ada.
+// Path kosong membuat AddrBook yang hanya ada di memori.
+func NewAddrBook(path string) (*AddrBook, error) {
+	book := &AddrBook{path: path, addrs: make(map[string]*KnownAddress)}
+	if path == "" {
+		return book, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var addrs []*KnownAddress
+	if err := json.Unmarshal(data, &addrs); err != nil {
+		return nil, fmt.Errorf("address book %s: %w", path, err)
+	}
+	for _, a := range addrs {
+		if validAddr(a.Addr) {
+			book.addrs[a.Addr] = a
+			if a.Tried {
+				book.nTried++
+			}
+		}
+	}
+	return book, nil
+}
+
+// validAddr memeriksa apakah alamat berbentuk host:port yang bisa dihubungi.
+func validAddr(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	p, err := strconv.Atoi(port)
+	return err == nil && p > 0 && p <= 65535
+}
+
+// Add menambahkan alamat ke bucket new. Mengembalikan true jika alamat belum
+// diketahui; untuk alamat yang sudah ada hanya LastSeen yang diperbarui.
+func (b *AddrBook) Add(addr string, lastSeen time.Time) bool {
+	if !validAddr(addr) {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if a, ok := b.addrs[addr]; ok {
+		if lastSeen.After(a.LastSeen) {
+			a.LastSeen = lastSeen
+		}
+		return false
+	}
+	if len(b.addrs)-b.nTried >= maxNewAddresses {
+		b.evictOldest(false)
+	}
+	b.addrs[addr] = &KnownAddress{Addr: addr, LastSeen: lastSeen}
+	return true
+}
+
+// evictOldest membuang alamat dengan LastSeen paling lama dari bucket new,
+// atau memindahkannya kembali ke bucket new jika dari bucket tried.
+func (b *AddrBook) evictOldest(tried bool) {
+	var oldest *KnownAddress
+	for _, a := range b.addrs {
+		if a.Tried == tried && (oldest == nil || a.LastSeen.Before(oldest.LastSeen)) {
+			oldest = a
+		}
+	}
+	if oldest == nil {
+		return
+	}
+	if tried {
+		oldest.Tried = false
+		b.nTried--
+		return
+	}
+	delete(b.addrs, oldest.Addr)
+}
+
+// Attempt mencatat percobaan koneksi yang gagal. Alamat di bucket new yang
+// terlalu sering gagal dibuang.
+func (b *AddrBook) Attempt(addr string, now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	a, ok := b.addrs[addr]
+	if !ok {
+		return
+	}
+	a.Attempts++
+	a.LastAttempt = now
+	if !a.Tried && a.Attempts >= maxAddrAttempts {
+		delete(b.addrs, addr)
+	}
+}
+
+// Good memindahkan alamat ke bucket tried setelah koneksi dan handshake berhasil.
+func (b *AddrBook) Good(addr string, now time.Time) {
+	if !validAddr(addr) {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	a, ok := b.addrs[addr]
+	if !ok {
+		a = &KnownAddress{Addr: addr}
+		b.addrs[addr] = a
+	}
+	a.LastSeen = now
+	a.LastAttempt = now
+	a.LastSuccess = now
+	a.Attempts = 0
+	if !a.Tried {
+		if b.nTried >= maxTriedAddresses {
+			b.evictOldest(true)
+		}
+		a.Tried = true
+		b.nTried++
+	}
+}
+
+// Remove menghapus alamat dari AddrBook.
+func (b *AddrBook) Remove(addr string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if a, ok := b.addrs[addr]; ok {
+		if a.Tried {
+			b.nTried--
+		}
+		delete(b.addrs, addr)
+	}
+}
+
+// Select memilih alamat acak untuk dihubungi yang tidak dikecualikan oleh
+// skip dan sudah melewati jeda percobaan ulangnya. Bucket tried dan new dipilih
+// dengan peluang yang sama jika keduanya memiliki kandidat.
+func (b *AddrBook) Select(skip func(addr string) bool, now time.Time) (string, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var tried, fresh []string
+	for addr, a := range b.addrs {
+		if skip(addr) || now.Sub(a.LastAttempt) < time.Duration(a.Attempts)*addrRetryInterval {
+			continue
+		}
+		if a.Tried {
+			tried = append(tried, addr)
+		} else {
+			fresh = append(fresh, addr)
+		}
+	}
+	candidates := fresh
+	if len(tried) > 0 && (len(fresh) == 0 || rand.Intn(2) == 0) {
+		candidates = tried
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	return candidates[rand.Intn(len(candidates))], true
+}
+
+// Sample mengembalikan hingga n alamat acak untuk dibagikan ke peer.
+func (b *AddrBook) Sample(n int) []KnownAddress {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	sample := make([]KnownAddress, 0, len(b.addrs))
+	for _, a := range b.addrs {
+		sample = append(sample, *a)
+	}
+	rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	if len(sample) > n {
+		sample = sample[:n]
+	}
+	return sample
+}
+
+// Len mengembalikan jumlah alamat di bucket new dan tried.
+func (b *AddrBook) Len() (fresh, tried int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.addrs) - b.nTried, b.nTried
+}
+
+// Save menulis isi AddrBook ke path-nya. File ditulis ke file sementara lalu
+// di-rename agar tidak rusak jika proses berhenti di tengah penulisan.
+func (b *AddrBook) Save() error {
+	if b.path == "" {
+		return nil
+	}
+	b.lock.Lock()
+	addrs := make([]*KnownAddress, 0, len(b.addrs))
+	for _, a := range b.addrs {
+		copied := *a
+		addrs = append(addrs, &copied)
+	}
+	b.lock.Unlock()
+
+	data, err := json.MarshalIndent(addrs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
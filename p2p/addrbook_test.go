@@ -0,0 +1,63 @@
+package p2p
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAddrBookBuckets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	book, err := NewAddrBook(path)
+	if err != nil {
+		t.Fatalf("NewAddrBook failed: %v", err)
+	}
+	now := time.Now()
+	if !book.Add("127.0.0.1:3001", now) || !book.Add("127.0.0.1:3002", now) {
+		t.Fatal("New addresses should be added")
+	}
+	if book.Add("127.0.0.1:3001", now) {
+		t.Error("Known address should not be added twice")
+	}
+	for _, addr := range []string{"3001", ":3001", "127.0.0.1:0", "127.0.0.1:x"} {
+		if book.Add(addr, now) {
+			t.Errorf("Invalid address %q should be rejected", addr)
+		}
+	}
+
+	book.Good("127.0.0.1:3001", now)
+	if fresh, tried := book.Len(); fresh != 1 || tried != 1 {
+		t.Fatalf("Expected 1 new and 1 tried address, got %d and %d", fresh, tried)
+	}
+
+	// Alamat yang gagal dihubungi menunggu jeda sebelum dicoba lagi.
+	book.Attempt("127.0.0.1:3002", now)
+	skipTried := func(addr string) bool { return addr == "127.0.0.1:3001" }
+	if addr, ok := book.Select(skipTried, now.Add(time.Second)); ok {
+		t.Errorf("Address should be in its retry backoff, got %s", addr)
+	}
+	if addr, ok := book.Select(skipTried, now.Add(addrRetryInterval)); !ok || addr != "127.0.0.1:3002" {
+		t.Errorf("Address should be selectable after its backoff, got %q", addr)
+	}
+	for i := 1; i < maxAddrAttempts; i++ {
+		book.Attempt("127.0.0.1:3002", now)
+	}
+	if fresh, _ := book.Len(); fresh != 0 {
+		t.Error("New address should be dropped after too many failed attempts")
+	}
+
+	// Isi AddrBook bertahan setelah disimpan dan dimuat ulang.
+	if err := book.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := NewAddrBook(path)
+	if err != nil {
+		t.Fatalf("Loading the address book failed: %v", err)
+	}
+	if fresh, tried := loaded.Len(); fresh != 0 || tried != 1 {
+		t.Errorf("Loaded book: expected 0 new and 1 tried address, got %d and %d", fresh, tried)
+	}
+	if addr, ok := loaded.Select(func(string) bool { return false }, now); !ok || addr != "127.0.0.1:3001" {
+		t.Errorf("Loaded book should select the tried address, got %q", addr)
+	}
+}
@@ -0,0 +1,135 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BanEntry adalah IP yang dilarang terhubung hingga Until.
+type BanEntry struct {
+	IP     string    `json:"ip"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// BanList menyimpan IP yang di-ban. Isinya disimpan sebagai JSON di path-nya
+// setiap kali berubah, sehingga ban tetap berlaku setelah node di-restart.
+type BanList struct {
+	lock sync.Mutex
+	path string
+	bans map[string]BanEntry
+}
+
+// NewBanList membuat BanList dan memuat isinya dari path jika file sudah ada.
+// Path kosong membuat BanList yang hanya ada di memori.
+func NewBanList(path string) (*BanList, error) {
+	list := &BanList{path: path, bans: make(map[string]BanEntry)}
+	if path == "" {
+		return list, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bans []BanEntry
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, fmt.Errorf("ban list %s: %w", path, err)
+	}
+	for _, b := range bans {
+		if ip := net.ParseIP(b.IP); ip != nil {
+			b.IP = ip.String()
+			list.bans[b.IP] = b
+		}
+	}
+	return list, nil
+}
+
+// Ban melarang ip terhubung hingga until. Ban yang sudah ada diganti.
+func (l *BanList) Ban(ip string, until time.Time, reason string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fmt.Errorf("invalid IP address %q", ip)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.bans[parsed.String()] = BanEntry{IP: parsed.String(), Until: until, Reason: reason}
+	return l.save()
+}
+
+// Unban menghapus ban ip. Mengembalikan false jika ip tidak di-ban.
+func (l *BanList) Unban(ip string) (bool, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, fmt.Errorf("invalid IP address %q", ip)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.bans[parsed.String()]; !ok {
+		return false, nil
+	}
+	delete(l.bans, parsed.String())
+	return true, l.save()
+}
+
+// IsBanned memeriksa apakah ip sedang di-ban. Ban yang sudah kedaluwarsa dibuang.
+func (l *BanList) IsBanned(ip string, now time.Time) (BanEntry, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return BanEntry{}, false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b, ok := l.bans[parsed.String()]
+	if !ok {
+		return BanEntry{}, false
+	}
+	if !now.Before(b.Until) {
+		delete(l.bans, b.IP)
+		return BanEntry{}, false
+	}
+	return b, true
+}
+
+// List mengembalikan ban yang masih berlaku, terurut dari yang paling cepat berakhir.
+func (l *BanList) List(now time.Time) []BanEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	bans := make([]BanEntry, 0, len(l.bans))
+	for _, b := range l.bans {
+		if now.Before(b.Until) {
+			bans = append(bans, b)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans
+}
+
+// save menulis isi BanList ke path-nya lewat file sementara. Pemanggil harus
+// memegang l.lock.
+func (l *BanList) save() error {
+	if l.path == "" {
+		return nil
+	}
+	bans := make([]BanEntry, 0, len(l.bans))
+	for _, b := range l.bans {
+		bans = append(bans, b)
+	}
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanListPersistsAndExpires(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banlist.json")
+	list, err := NewBanList(path)
+	if err != nil {
+		t.Fatalf("NewBanList failed: %v", err)
+	}
+	now := time.Now()
+	if err := list.Ban("10.0.0.1", now.Add(time.Hour), "invalid block"); err != nil {
+		t.Fatalf("Ban failed: %v", err)
+	}
+	if err := list.Ban("10.0.0.2", now.Add(time.Minute), "spam"); err != nil {
+		t.Fatalf("Ban failed: %v", err)
+	}
+	if err := list.Ban("not-an-ip", now.Add(time.Hour), "bad"); err == nil {
+		t.Error("Banning an invalid IP should fail")
+	}
+
+	// Ban tetap berlaku setelah BanList dimuat ulang dari disk.
+	loaded, err := NewBanList(path)
+	if err != nil {
+		t.Fatalf("Reloading ban list failed: %v", err)
+	}
+	if ban, ok := loaded.IsBanned("10.0.0.1", now); !ok || ban.Reason != "invalid block" {
+		t.Errorf("Ban should survive a reload, got %+v (%v)", ban, ok)
+	}
+	if bans := loaded.List(now); len(bans) != 2 || bans[0].IP != "10.0.0.2" {
+		t.Errorf("Expected 2 bans ordered by expiry, got %+v", bans)
+	}
+
+	// Ban yang kedaluwarsa tidak lagi berlaku.
+	later := now.Add(2 * time.Minute)
+	if _, ok := loaded.IsBanned("10.0.0.2", later); ok {
+		t.Error("Expired ban should no longer apply")
+	}
+	if bans := loaded.List(later); len(bans) != 1 {
+		t.Errorf("Expected 1 active ban after expiry, got %d", len(bans))
+	}
+
+	if removed, err := loaded.Unban("10.0.0.1"); err != nil || !removed {
+		t.Fatalf("Unban failed: %v (removed %v)", err, removed)
+	}
+	if removed, _ := loaded.Unban("10.0.0.1"); removed {
+		t.Error("Unbanning an IP that is not banned should report false")
+	}
+	reloaded, err := NewBanList(path)
+	if err != nil {
+		t.Fatalf("Reloading ban list failed: %v", err)
+	}
+	if _, ok := reloaded.IsBanned("10.0.0.1", now); ok {
+		t.Error("Unban should be persisted")
+	}
+}
@@ -0,0 +1,81 @@
+package p2p
+
+import (
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	// BanThreshold adalah ban score yang membuat IP peer di-ban.
+	BanThreshold = 100
+	// DefaultBanDuration adalah lama ban karena ban score.
+	DefaultBanDuration = 24 * time.Hour
+)
+
+// Bobot pelanggaran peer. Pelanggaran yang tidak mungkin dilakukan peer jujur
+// langsung mencapai BanThreshold; yang bisa terjadi karena perbedaan state
+// atau versi diberi bobot kecil agar harus berulang sebelum peer di-ban.
+const (
+	scoreInvalidBlock     = 100
+	scoreInvalidTx        = 10
+	scoreMalformedMessage = 20
+	scoreUnsolicited      = 5
+)
+
+// misbehaving menambahkan score ke ban score peer. Jika score mencapai
+// BanThreshold, IP peer di-ban dan semua koneksinya diputus.
+func (s *Server) misbehaving(from net.Addr, score int, reason string) {
+	s.lock.Lock()
+	peer, ok := s.peers[from]
+	if !ok {
+		s.lock.Unlock()
+		return
+	}
+	peer.banScore += score
+	total := peer.banScore
+	s.lock.Unlock()
+
+	log.Printf("P2P: Peer %s misbehaving (+%d, ban score %d): %s", from, score, total, reason)
+	if total < BanThreshold {
+		return
+	}
+	ip := from.(*net.TCPAddr).IP.String()
+	if err := s.Ban(ip, DefaultBanDuration, reason); err != nil {
+		log.Printf("P2P: Error banning %s: %v", ip, err)
+	}
+}
+
+// Ban melarang ip terhubung selama duration dan memutus semua peer dari ip.
+func (s *Server) Ban(ip string, duration time.Duration, reason string) error {
+	if err := s.banList.Ban(ip, time.Now().Add(duration), reason); err != nil {
+		return err
+	}
+	ip = net.ParseIP(ip).String()
+	log.Printf("P2P: Banned %s for %v: %s", ip, duration, reason)
+
+	s.lock.RLock()
+	var banned []*Peer
+	for addr, peer := range s.peers {
+		if addr.(*net.TCPAddr).IP.String() == ip {
+			banned = append(banned, peer)
+		}
+	}
+	s.lock.RUnlock()
+	for _, peer := range banned {
+		// readLoop peer akan membersihkan sisanya
+		s.sendDisconnect(peer, "banned: "+reason)
+		peer.conn.Close()
+	}
+	return nil
+}
+
+// Unban menghapus ban ip. Mengembalikan false jika ip tidak di-ban.
+func (s *Server) Unban(ip string) (bool, error) {
+	return s.banList.Unban(ip)
+}
+
+// Bans mengembalikan daftar ban yang masih berlaku.
+func (s *Server) Bans() []BanEntry {
+	return s.banList.List(time.Now())
+}
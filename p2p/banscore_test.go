@@ -0,0 +1,92 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMisbehavingPeerIsBanned(t *testing.T) {
+	s, addr := startTestServer(t, Options{})
+	ip := net.IPv4(127, 0, 0, 4)
+
+	peer, msg := dialTestPeer(t, addr, ip)
+	if msg.Type != MessageTypeHandshake {
+		t.Fatalf("Peer should be accepted before misbehaving, got message type %d", msg.Type)
+	}
+	// Setiap pesan yang tidak bisa di-decode menambah scoreMalformedMessage.
+	for i := 0; i < BanThreshold/scoreMalformedMessage; i++ {
+		if err := peer.Send(&Message{Type: MessageTypeTx, Payload: []byte("garbage")}); err != nil {
+			t.Fatalf("Sending malformed message failed: %v", err)
+		}
+	}
+	peer.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		msg, err := peer.Receive()
+		if err != nil {
+			t.Fatalf("Expected a disconnect message before the connection closed: %v", err)
+		}
+		if msg.Type == MessageTypeDisconnect {
+			break
+		}
+	}
+
+	bans := s.Bans()
+	if len(bans) != 1 || bans[0].IP != ip.String() {
+		t.Fatalf("Expected %s to be banned, got %+v", ip, bans)
+	}
+
+	// Koneksi baru dari IP yang di-ban ditutup tanpa handshake.
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: ip}, Timeout: time.Second}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	banned := NewPeer(conn, MagicRegtest)
+	sendPayload(banned, MessageTypeHandshake, HandshakePayload{Version: "test"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if msg, err := banned.Receive(); err == nil {
+		t.Errorf("Banned peer should be refused, got message type %d", msg.Type)
+	}
+
+	// Setelah ban dihapus, IP boleh terhubung lagi.
+	if removed, err := s.Unban(ip.String()); err != nil || !removed {
+		t.Fatalf("Unban failed: %v (removed %v)", err, removed)
+	}
+	if _, msg := dialTestPeer(t, addr, ip); msg.Type != MessageTypeHandshake {
+		t.Errorf("Unbanned peer should be accepted, got message type %d", msg.Type)
+	}
+}
+
+func TestRateLimitExemptsRequestedReplies(t *testing.T) {
+	peer := &Peer{limiter: NewRateLimiter(10, 1), done: make(chan struct{})}
+	block := &Message{Type: MessageTypeBlock}
+	if peer.takeReply(block) {
+		t.Error("Block should not count as a reply before we request one")
+	}
+	peer.expectReplies(2)
+	if peer.takeReply(&Message{Type: MessageTypeInv}) {
+		t.Error("Inv should never count as a reply")
+	}
+	if !peer.takeReply(block) || !peer.takeReply(block) {
+		t.Error("Requested blocks should count as replies")
+	}
+	if peer.takeReply(block) {
+		t.Error("Only as many replies as requested should be exempt")
+	}
+
+	// Pesan di atas rate limit ditahan, bukan dibuang.
+	peer.limiter.Allow()
+	start := time.Now()
+	if !peer.limiter.Wait(peer.done) {
+		t.Fatal("Wait should succeed once a token is refilled")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Wait returned after %v, expected it to wait for a token", elapsed)
+	}
+	close(peer.done)
+	if peer.limiter.Wait(peer.done) {
+		t.Error("Wait should give up once the peer is closed")
+	}
+}
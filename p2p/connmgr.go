@@ -0,0 +1,160 @@
+package p2p
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultMaxInbound adalah jumlah maksimum koneksi inbound.
+	DefaultMaxInbound = 64
+	// DefaultMaxInboundPerIP adalah jumlah maksimum koneksi inbound dari satu IP.
+	DefaultMaxInboundPerIP = 8
+	// reconnectInitialDelay adalah jeda sebelum menghubungi ulang peer addnode;
+	// jeda digandakan setiap kali gagal hingga reconnectMaxDelay.
+	reconnectInitialDelay = time.Second
+	reconnectMaxDelay     = 5 * time.Minute
+	// evictProtectRelayers adalah jumlah peer inbound yang terakhir mengirim
+	// block baru, dan juga transaksi baru, yang dilindungi dari eviction.
+	evictProtectRelayers = 4
+)
+
+var (
+	errTooManyFromIP = errors.New("too many connections from this address")
+	errInboundFull   = errors.New("inbound connection slots are full")
+)
+
+// AddNode menambahkan peer addnode yang koneksinya dijaga terus. Jika koneksi
+// gagal atau terputus, peer dihubungi ulang dengan backoff eksponensial.
+// Peer addnode tidak memakai slot outbound.
+func (s *Server) AddNode(addr string) {
+	s.lock.Lock()
+	if s.addNodes[addr] {
+		s.lock.Unlock()
+		return
+	}
+	s.addNodes[addr] = true
+	s.lock.Unlock()
+
+	go func() {
+		delay := reconnectInitialDelay
+		for {
+			peer, err := s.connect(addr, true)
+			if err == nil {
+				delay = reconnectInitialDelay
+				<-peer.done
+				log.Printf("P2P: Lost connection to addnode peer %s, reconnecting in %v", addr, delay)
+			} else {
+				log.Printf("P2P: Error connecting to addnode peer %s: %v, retrying in %v", addr, err, delay)
+			}
+			time.Sleep(delay)
+			if err != nil {
+				delay = min(delay*2, reconnectMaxDelay)
+			}
+		}
+	}()
+}
+
+// admitInbound memeriksa apakah koneksi inbound dari ip boleh diterima. Jika
+// slot inbound penuh, peer inbound yang paling tidak berguna dikembalikan
+// untuk diputus. Pemanggil harus memegang s.lock.
+func (s *Server) admitInbound(ip string) (*Peer, error) {
+	inbound, fromIP := 0, 0
+	var candidates []evictionCandidate
+	for addr, peer := range s.peers {
+		if peer.outbound {
+			continue
+		}
+		inbound++
+		peerIP := addr.(*net.TCPAddr).IP.String()
+		if peerIP == ip {
+			fromIP++
+		}
+		candidates = append(candidates, evictionCandidate{
+			addr:      addr,
+			ip:        peerIP,
+			connected: peer.connectedAt,
+			lastBlock: peer.lastBlock,
+			lastTx:    peer.lastTx,
+		})
+	}
+	if fromIP >= s.maxInboundPerIP {
+		return nil, errTooManyFromIP
+	}
+	if inbound < s.maxInbound {
+		return nil, nil
+	}
+	addr, ok := selectEviction(candidates)
+	if !ok {
+		return nil, errInboundFull
+	}
+	return s.peers[addr], nil
+}
+
+// markUseful mencatat bahwa peer baru saja mengirim block atau transaksi baru,
+// sehingga peer tersebut dilindungi dari eviction.
+func (s *Server) markUseful(from net.Addr, block bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	peer, ok := s.peers[from]
+	if !ok {
+		return
+	}
+	if block {
+		peer.lastBlock = time.Now()
+	} else {
+		peer.lastTx = time.Now()
+	}
+}
+
+// evictionCandidate adalah data peer inbound untuk memilih peer yang diputus.
+type evictionCandidate struct {
+	addr      net.Addr
+	ip        string
+	connected time.Time
+	lastBlock time.Time
+	lastTx    time.Time
+}
+
+// selectEviction memilih peer inbound yang paling tidak berguna. Peer yang
+// terakhir mengirim block atau transaksi baru dan separuh peer yang paling
+// lama terhubung dilindungi. Dari sisanya, peer terbaru dari IP dengan koneksi
+// terbanyak dipilih, sehingga satu IP tidak bisa menyingkirkan peer lain.
+func selectEviction(candidates []evictionCandidate) (net.Addr, bool) {
+	candidates = protectRecent(candidates, func(c evictionCandidate) time.Time { return c.lastBlock })
+	candidates = protectRecent(candidates, func(c evictionCandidate) time.Time { return c.lastTx })
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].connected.Before(candidates[j].connected) })
+	candidates = candidates[len(candidates)/2:]
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	// Kandidat terurut dari yang paling lama terhubung, sehingga entri terakhir
+	// setiap IP adalah koneksi terbarunya.
+	groups := make(map[string][]evictionCandidate)
+	for _, c := range candidates {
+		groups[c.ip] = append(groups[c.ip], c)
+	}
+	var worst []evictionCandidate
+	for _, group := range groups {
+		if len(group) > len(worst) || (len(group) == len(worst) && group[len(group)-1].connected.After(worst[len(worst)-1].connected)) {
+			worst = group
+		}
+	}
+	return worst[len(worst)-1].addr, true
+}
+
+// protectRecent membuang hingga evictProtectRelayers kandidat dengan waktu
+// terbaru menurut at; kandidat dengan waktu nol tidak dilindungi.
+func protectRecent(candidates []evictionCandidate, at func(evictionCandidate) time.Time) []evictionCandidate {
+	sorted := append([]evictionCandidate(nil), candidates...)
+	sort.Slice(sorted, func(i, j int) bool { return at(sorted[i]).After(at(sorted[j])) })
+	protected := 0
+	for protected < len(sorted) && protected < evictProtectRelayers && !at(sorted[protected]).IsZero() {
+		protected++
+	}
+	return sorted[protected:]
+}
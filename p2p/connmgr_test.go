@@ -0,0 +1,119 @@
+package p2p
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSelectEviction(t *testing.T) {
+	now := time.Now()
+	addr := func(i int) net.Addr { return &net.TCPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 3000} }
+	candidates := []evictionCandidate{
+		{addr: addr(1), ip: "10.0.0.1", connected: now.Add(-time.Hour)},
+		{addr: addr(2), ip: "10.0.0.2", connected: now.Add(-50 * time.Minute)},
+		{addr: addr(3), ip: "10.0.0.9", connected: now.Add(-3 * time.Minute)},
+		{addr: addr(4), ip: "10.0.0.9", connected: now.Add(-2 * time.Minute)},
+		{addr: addr(5), ip: "10.0.0.5", connected: now.Add(-time.Minute), lastBlock: now},
+		{addr: addr(6), ip: "10.0.0.6", connected: now},
+	}
+	// Peer 5 dilindungi karena baru mengirim block, peer 1-2 karena paling lama
+	// terhubung; dari sisanya IP 10.0.0.9 memiliki koneksi terbanyak.
+	if got, ok := selectEviction(candidates); !ok || got.String() != addr(4).String() {
+		t.Errorf("Expected the newest peer of the busiest IP to be evicted, got %v", got)
+	}
+
+	if _, ok := selectEviction(nil); ok {
+		t.Error("No peer should be evicted without candidates")
+	}
+}
+
+// dialTestPeer membuka koneksi inbound dari IP lokal tertentu dan melakukan
+// handshake, lalu mengembalikan peer beserta balasan pertama server.
+func dialTestPeer(t *testing.T, addr string, localIP net.IP) (*Peer, *Message) {
+	t.Helper()
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: localIP}, Timeout: time.Second}
+	var conn net.Conn
+	var err error
+	for attempt := 0; attempt < 50; attempt++ {
+		if conn, err = dialer.Dial("tcp", addr); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Dial from %s failed: %v", localIP, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	peer := NewPeer(conn, MagicRegtest)
+	if err := sendPayload(peer, MessageTypeHandshake, HandshakePayload{Version: "test", ListenAddr: ":1", Nonce: rand.Uint64()}); err != nil {
+		t.Fatalf("Sending handshake failed: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg, err := peer.Receive()
+	if err != nil {
+		t.Fatalf("Reading handshake reply failed: %v", err)
+	}
+	return peer, msg
+}
+
+func TestInboundLimits(t *testing.T) {
+	_, addr := startTestServer(t, Options{MaxInbound: 2, MaxInboundPerIP: 1})
+
+	if _, msg := dialTestPeer(t, addr, net.IPv4(127, 0, 0, 1)); msg.Type != MessageTypeHandshake {
+		t.Fatalf("First inbound peer should be accepted, got message type %d", msg.Type)
+	}
+	if _, msg := dialTestPeer(t, addr, net.IPv4(127, 0, 0, 1)); msg.Type != MessageTypeDisconnect {
+		t.Errorf("Second peer from the same IP should be refused, got message type %d", msg.Type)
+	}
+
+	second, msg := dialTestPeer(t, addr, net.IPv4(127, 0, 0, 2))
+	if msg.Type != MessageTypeHandshake {
+		t.Fatalf("Peer from another IP should be accepted, got message type %d", msg.Type)
+	}
+	// Slot penuh: peer yang paling lama terhubung dilindungi, peer terbaru diputus.
+	if _, msg := dialTestPeer(t, addr, net.IPv4(127, 0, 0, 3)); msg.Type != MessageTypeHandshake {
+		t.Fatalf("New peer should be accepted after evicting another, got message type %d", msg.Type)
+	}
+	second.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if msg, err := second.Receive(); err != nil || msg.Type != MessageTypeDisconnect {
+		t.Errorf("Newest existing peer should have been evicted, got %v (%v)", msg, err)
+	}
+}
+
+func TestAddNodeReconnects(t *testing.T) {
+	target, targetAddr := startTestServer(t, Options{})
+	s, _ := startTestServer(t, Options{})
+	s.AddNode(targetAddr)
+
+	// waitAddNode menunggu hingga s terhubung ke target dan mengembalikan peer-nya.
+	waitAddNode := func(previous *Peer) *Peer {
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			s.lock.RLock()
+			for _, peer := range s.peers {
+				if peer.addr == targetAddr && peer != previous {
+					s.lock.RUnlock()
+					return peer
+				}
+			}
+			s.lock.RUnlock()
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatal("Timed out waiting for the addnode connection")
+		return nil
+	}
+	first := waitAddNode(nil)
+	if !first.persistent || s.outboundCount() != 0 {
+		t.Error("Addnode peer should be persistent and not use an outbound slot")
+	}
+
+	// Putus koneksi dari sisi target; s harus menghubungi ulang.
+	target.lock.RLock()
+	for _, peer := range target.peers {
+		peer.conn.Close()
+	}
+	target.lock.RUnlock()
+	waitAddNode(first)
+}
@@ -0,0 +1,211 @@
+package p2p
+
+import (
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	// MaxAddrsPerMessage adalah jumlah alamat maksimum dalam satu pesan addr.
+	MaxAddrsPerMessage = 1000
+	// DefaultMaxOutbound adalah target jumlah koneksi outbound.
+	DefaultMaxOutbound = 8
+	// maxAddrRelay adalah ukuran pesan addr terbesar yang alamat barunya
+	// diteruskan; pesan yang lebih besar adalah balasan getaddr.
+	maxAddrRelay = 10
+	// addrRelayPeers adalah jumlah peer tujuan penerusan alamat baru.
+	addrRelayPeers = 2
+	// dialTimeout membatasi waktu membuka koneksi TCP ke peer.
+	dialTimeout = 5 * time.Second
+	// connectInterval adalah jeda antar percobaan koneksi outbound.
+	connectInterval = time.Second
+	// addrBookSaveInterval adalah jeda antar penulisan AddrBook ke disk.
+	addrBookSaveInterval = time.Minute
+)
+
+// errSelfConnection dikembalikan saat handshake ternyata dengan node kita sendiri.
+var errSelfConnection = errors.New("connected to self")
+
+// advertisedAddr menyusun alamat yang bisa dihubungi dari peer inbound: port
+// dari ListenAddr di handshake, dengan host dari koneksi jika ListenAddr tidak
+// menyebutkan host.
+func advertisedAddr(remote net.Addr, listenAddr string) (string, bool) {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "", false
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		tcpAddr, ok := remote.(*net.TCPAddr)
+		if !ok {
+			return "", false
+		}
+		host = tcpAddr.IP.String()
+	}
+	addr := net.JoinHostPort(host, port)
+	return addr, validAddr(addr)
+}
+
+// registerPeerAddr mencatat alamat peer di AddrBook setelah handshake. Alamat
+// peer outbound dipindahkan ke bucket tried dan peer diminta alamat yang
+// diketahuinya; alamat baru dari peer inbound diteruskan ke peer lain.
+func (s *Server) registerPeerAddr(peer *Peer, listenAddr string) {
+	now := time.Now()
+	if peer.outbound {
+		s.addrBook.Good(peer.addr, now)
+		if err := peer.Send(&Message{Type: MessageTypeGetAddr}); err != nil {
+			log.Printf("P2P: Error requesting addresses from %s: %v", peer.conn.RemoteAddr(), err)
+		}
+		return
+	}
+	addr, ok := advertisedAddr(peer.conn.RemoteAddr(), listenAddr)
+	if !ok {
+		return
+	}
+	s.lock.Lock()
+	peer.addr = addr
+	s.lock.Unlock()
+	if s.addrBook.Add(addr, now) {
+		s.relayAddrs(peer, []NetAddress{{Addr: addr, LastSeen: now.Unix()}})
+	}
+}
+
+// handleGetAddr membalas getaddr dengan sampel acak AddrBook. Setiap koneksi
+// hanya dilayani sekali agar peer tidak bisa menyalin seluruh AddrBook.
+func (s *Server) handleGetAddr(from net.Addr) {
+	peer, ok := s.getPeer(from)
+	if !ok || peer.sentAddrs {
+		return
+	}
+	peer.sentAddrs = true
+	var addrs []NetAddress
+	for _, a := range s.addrBook.Sample(MaxAddrsPerMessage) {
+		addrs = append(addrs, NetAddress{Addr: a.Addr, LastSeen: a.LastSeen.Unix()})
+	}
+	if len(addrs) == 0 {
+		return
+	}
+	if err := sendPayload(peer, MessageTypeAddr, AddrPayload{Addrs: addrs}); err != nil {
+		log.Printf("P2P: Error sending addresses to %s: %v", from, err)
+	}
+}
+
+// handleAddr menambahkan alamat dari peer ke AddrBook. Alamat baru dari
+// pengumuman kecil diteruskan ke beberapa peer lain.
+func (s *Server) handleAddr(from net.Addr, payload *AddrPayload) {
+	peer, ok := s.getPeer(from)
+	if !ok {
+		return
+	}
+	if len(payload.Addrs) > MaxAddrsPerMessage {
+		log.Printf("P2P: Ignoring %d addresses from %s (maximum %d)", len(payload.Addrs), from, MaxAddrsPerMessage)
+		s.misbehaving(from, scoreMalformedMessage, "oversized addr")
+		return
+	}
+	now := time.Now()
+	var fresh []NetAddress
+	for _, a := range payload.Addrs {
+		lastSeen := time.Unix(a.LastSeen, 0)
+		if lastSeen.After(now) {
+			lastSeen = now
+		}
+		if s.addrBook.Add(a.Addr, lastSeen) {
+			fresh = append(fresh, a)
+		}
+	}
+	log.Printf("P2P: Received %d addresses (%d new) from %s", len(payload.Addrs), len(fresh), from)
+	if len(fresh) > 0 && len(payload.Addrs) <= maxAddrRelay {
+		s.relayAddrs(peer, fresh)
+	}
+}
+
+// relayAddrs meneruskan alamat ke hingga addrRelayPeers peer selain source.
+func (s *Server) relayAddrs(source *Peer, addrs []NetAddress) {
+	var targets []*Peer
+	for _, peer := range s.peerList() {
+		if peer != source && len(targets) < addrRelayPeers {
+			targets = append(targets, peer)
+		}
+	}
+	for _, peer := range targets {
+		if err := sendPayload(peer, MessageTypeAddr, AddrPayload{Addrs: addrs}); err != nil {
+			log.Printf("P2P: Error relaying addresses to %s: %v", peer.conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// connectedAddrs mengembalikan alamat yang sedang atau akan dihubungi, termasuk
+// alamat node kita sendiri, sehingga tidak dipilih lagi oleh maintainOutbound.
+// Pemanggil harus memegang s.lock.
+func (s *Server) connectedAddrs() map[string]bool {
+	addrs := make(map[string]bool, len(s.peers)+len(s.dialing)+len(s.localAddrs))
+	for _, peer := range s.peers {
+		if peer.addr != "" {
+			addrs[peer.addr] = true
+		}
+	}
+	for addr := range s.dialing {
+		addrs[addr] = true
+	}
+	for addr := range s.localAddrs {
+		addrs[addr] = true
+	}
+	return addrs
+}
+
+// outboundCount mengembalikan jumlah koneksi outbound yang aktif atau sedang
+// dibuka, tanpa peer addnode.
+func (s *Server) outboundCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	count := 0
+	for _, persistent := range s.dialing {
+		if !persistent {
+			count++
+		}
+	}
+	for _, peer := range s.peers {
+		if peer.outbound && !peer.persistent {
+			count++
+		}
+	}
+	return count
+}
+
+// maintainOutbound menjaga jumlah koneksi outbound sesuai target dengan
+// memilih alamat dari AddrBook, dan menyimpan AddrBook secara berkala.
+func (s *Server) maintainOutbound() {
+	ticker := time.NewTicker(connectInterval)
+	defer ticker.Stop()
+	lastSave := time.Now()
+	for now := range ticker.C {
+		if now.Sub(lastSave) >= addrBookSaveInterval {
+			if err := s.addrBook.Save(); err != nil {
+				log.Printf("P2P: Error saving address book: %v", err)
+			}
+			lastSave = now
+		}
+		if s.outboundCount() >= s.maxOutbound {
+			continue
+		}
+		s.lock.RLock()
+		skip := s.connectedAddrs()
+		s.lock.RUnlock()
+		addr, ok := s.addrBook.Select(func(addr string) bool { return skip[addr] }, now)
+		if !ok {
+			continue
+		}
+		err := s.Connect(addr)
+		switch {
+		case errors.Is(err, errSelfConnection):
+			s.lock.Lock()
+			s.localAddrs[addr] = true
+			s.lock.Unlock()
+			s.addrBook.Remove(addr)
+		case err != nil:
+			log.Printf("P2P: Error connecting to %s: %v", addr, err)
+			s.addrBook.Attempt(addr, time.Now())
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"swatantra/core"
+	"swatantra/mempool"
+	"swatantra/storage"
+)
+
+func TestAdvertisedAddr(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 51234}
+	tests := []struct {
+		listen string
+		want   string
+		ok     bool
+	}{
+		{":3000", "10.0.0.5:3000", true},
+		{"0.0.0.0:3000", "10.0.0.5:3000", true},
+		{"192.168.1.2:3000", "192.168.1.2:3000", true},
+		{"", "", false},
+		{":0", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := advertisedAddr(remote, tt.listen)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("advertisedAddr(%q) = %q, %v; expected %q, %v", tt.listen, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// startTestServer menjalankan Server dengan blockchain baru di port lokal acak.
+func startTestServer(t *testing.T, opts Options) (*Server, string) {
+	t.Helper()
+	store, err := storage.NewLevelDBStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLevelDBStore failed: %v", err)
+	}
+	bc, err := core.NewBlockchain(store, 10)
+	if err != nil {
+		t.Fatalf("NewBlockchain failed: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	opts.Magic = MagicRegtest
+	s := NewServerWithOptions(addr, bc, mempool.NewMempool(bc, 100), opts)
+	go s.Start()
+	go s.ProcessMessages()
+	return s, addr
+}
+
+// connectedPeerAddrs mengembalikan alamat listen peer yang terhubung ke s.
+func connectedPeerAddrs(s *Server) map[string]bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	addrs := make(map[string]bool)
+	for _, peer := range s.peers {
+		if peer.addr != "" {
+			addrs[peer.addr] = true
+		}
+	}
+	return addrs
+}
+
+func TestNetworkAssemblesFromSeed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("multi-node test")
+	}
+	seed, seedAddr := startTestServer(t, Options{})
+	servers := []*Server{seed}
+	addrs := []string{seedAddr}
+	for i := 0; i < 3; i++ {
+		s, addr := startTestServer(t, Options{})
+		// Seed mungkin belum selesai membuka listener-nya
+		var err error
+		for attempt := 0; attempt < 50; attempt++ {
+			if err = s.Connect(seedAddr); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("Connecting to the seed failed: %v", err)
+		}
+		servers = append(servers, s)
+		addrs = append(addrs, addr)
+	}
+
+	// Setiap node hanya mengenal seed, tetapi akhirnya terhubung ke semua node lain.
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		complete := true
+		for i, s := range servers {
+			peers := connectedPeerAddrs(s)
+			for j, addr := range addrs {
+				if i != j && !peers[addr] {
+					complete = false
+				}
+			}
+		}
+		if complete {
+			break
+		}
+		if time.Now().After(deadline) {
+			for i, s := range servers {
+				t.Logf("Node %s is connected to %v", addrs[i], connectedPeerAddrs(s))
+			}
+			t.Fatal("Network did not assemble into a full mesh")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	for i, s := range servers {
+		if connectedPeerAddrs(s)[addrs[i]] {
+			t.Errorf("Node %s should not be connected to itself", addrs[i])
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package p2p
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"swatantra/core"
+	"swatantra/crypto"
+)
+
+const (
+	// downloadWindow adalah jumlah block di depan head yang boleh diminta atau
+	// ditahan sekaligus saat sinkronisasi.
+	downloadWindow = 512
+	// maxBlocksInFlightPerPeer membatasi permintaan block yang belum dibalas per peer.
+	maxBlocksInFlightPerPeer = 16
+	// blockStallTimeout adalah batas waktu sebelum permintaan block dianggap
+	// macet dan dialihkan ke peer lain.
+	blockStallTimeout = 20 * time.Second
+	// downloadCheckInterval adalah interval pemeriksaan permintaan yang macet.
+	downloadCheckInterval = 2 * time.Second
+)
+
+// blockRequest adalah permintaan body block yang sedang menunggu balasan.
+type blockRequest struct {
+	peer      net.Addr
+	requested time.Time
+}
+
+// bufferedBlock adalah block yang tiba sebelum parent-nya ter-connect.
+type bufferedBlock struct {
+	block *core.Block
+	from  net.Addr
+}
+
+// downloadPeer adalah peer yang bisa dimintai block hingga height tertentu.
+type downloadPeer struct {
+	addr   net.Addr
+	height uint32
+}
+
+// blockDownloader menjadwalkan pengunduhan body block di chain best header
+// dari beberapa peer sekaligus. Hanya block dalam window di depan head yang
+// diminta; block yang tiba lebih awal ditahan sampai parent-nya ter-connect,
+// sehingga block di-connect ke Blockchain secara berurutan.
+type blockDownloader struct {
+	lock     sync.Mutex
+	bestHash crypto.Hash    // Best header tempat chain dihitung
+	chain    []*core.Header // Block yang belum dimiliki, dari height terendah
+	inFlight map[crypto.Hash]*blockRequest
+	perPeer  map[net.Addr]int
+	stalled  map[crypto.Hash]net.Addr       // Peer terakhir yang gagal mengirim block
+	buffered map[crypto.Hash]*bufferedBlock // Berdasarkan PrevHash block
+}
+
+func newBlockDownloader() *blockDownloader {
+	return &blockDownloader{
+		inFlight: make(map[crypto.Hash]*blockRequest),
+		perPeer:  make(map[net.Addr]int),
+		stalled:  make(map[crypto.Hash]net.Addr),
+		buffered: make(map[crypto.Hash]*bufferedBlock),
+	}
+}
+
+// needsChain memeriksa apakah chain perlu dihitung ulang untuk best header baru.
+func (d *blockDownloader) needsChain(best crypto.Hash) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.bestHash != best
+}
+
+// setChain mengganti daftar block yang harus diunduh. Block yang ditahan tetapi
+// tidak lagi berada di chain dibuang.
+func (d *blockDownloader) setChain(best crypto.Hash, chain []*core.Header) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.bestHash = best
+	d.chain = chain
+	inChain := make(map[crypto.Hash]bool, len(chain))
+	for _, h := range chain {
+		inChain[h.Hash()] = true
+	}
+	for prev, b := range d.buffered {
+		if !inChain[b.block.Header.Hash()] {
+			delete(d.buffered, prev)
+		}
+	}
+}
+
+// schedule membagikan block di window kepada peer yang height-nya mencukupi,
+// mengutamakan peer dengan permintaan tertunda paling sedikit dan menghindari
+// peer yang sebelumnya macet untuk block yang sama. have
+// melaporkan block yang sudah ter-connect sehingga window bisa bergeser.
+func (d *blockDownloader) schedule(peers []downloadPeer, have func(crypto.Hash) bool, now time.Time) map[net.Addr][]crypto.Hash {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for len(d.chain) > 0 && have(d.chain[0].Hash()) {
+		d.chain = d.chain[1:]
+	}
+	window := d.chain
+	if len(window) > downloadWindow {
+		window = window[:downloadWindow]
+	}
+
+	requests := make(map[net.Addr][]crypto.Hash)
+	for _, h := range window {
+		hash := h.Hash()
+		if _, ok := d.inFlight[hash]; ok {
+			continue
+		}
+		if b, ok := d.buffered[h.PrevHash]; ok && b.block.Header.Hash() == hash {
+			continue
+		}
+		var best, fallback *downloadPeer
+		for i := range peers {
+			p := &peers[i]
+			if p.height < h.Height || d.perPeer[p.addr] >= maxBlocksInFlightPerPeer {
+				continue
+			}
+			if d.stalled[hash] == p.addr {
+				// Peer yang macet hanya dipakai jika tidak ada peer lain
+				fallback = p
+				continue
+			}
+			if best == nil || d.perPeer[p.addr] < d.perPeer[best.addr] {
+				best = p
+			}
+		}
+		if best == nil {
+			best = fallback
+		}
+		if best == nil {
+			continue
+		}
+		d.inFlight[hash] = &blockRequest{peer: best.addr, requested: now}
+		d.perPeer[best.addr]++
+		requests[best.addr] = append(requests[best.addr], hash)
+	}
+	return requests
+}
+
+// received mencatat block yang tiba. Mengembalikan true jika block memang diminta.
+func (d *blockDownloader) received(hash crypto.Hash) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	req, ok := d.inFlight[hash]
+	if !ok {
+		return false
+	}
+	d.release(hash, req)
+	delete(d.stalled, hash)
+	return true
+}
+
+func (d *blockDownloader) release(hash crypto.Hash, req *blockRequest) {
+	delete(d.inFlight, hash)
+	if d.perPeer[req.peer]--; d.perPeer[req.peer] <= 0 {
+		delete(d.perPeer, req.peer)
+	}
+}
+
+// buffer menahan block yang parent-nya belum ter-connect.
+func (d *blockDownloader) buffer(b *core.Block, from net.Addr) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.buffered[b.Header.PrevHash] = &bufferedBlock{block: b, from: from}
+}
+
+// takeChild mengambil block yang ditahan menunggu parent hash.
+func (d *blockDownloader) takeChild(hash crypto.Hash) *bufferedBlock {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	b, ok := d.buffered[hash]
+	if ok {
+		delete(d.buffered, hash)
+	}
+	return b
+}
+
+// expire membatalkan permintaan yang lebih lama dari blockStallTimeout dan
+// mengembalikan peer yang macet untuk setiap block. Block tersebut akan
+// dijadwalkan ulang ke peer lain.
+func (d *blockDownloader) expire(now time.Time) map[crypto.Hash]net.Addr {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	expired := make(map[crypto.Hash]net.Addr)
+	for hash, req := range d.inFlight {
+		if now.Sub(req.requested) < blockStallTimeout {
+			continue
+		}
+		d.release(hash, req)
+		d.stalled[hash] = req.peer
+		expired[hash] = req.peer
+	}
+	return expired
+}
+
+// peerGone membatalkan semua permintaan ke peer yang terputus.
+func (d *blockDownloader) peerGone(addr net.Addr) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for hash, req := range d.inFlight {
+		if req.peer == addr {
+			d.release(hash, req)
+		}
+	}
+}
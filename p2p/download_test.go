@@ -0,0 +1,174 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"swatantra/core"
+	"swatantra/crypto"
+)
+
+// testHeaderChain membuat rangkaian header sederhana mulai dari height 1.
+func testHeaderChain(n int) []*core.Header {
+	var headers []*core.Header
+	prev := crypto.Hash{0xaa}
+	for i := 1; i <= n; i++ {
+		h := &core.Header{PrevHash: prev, Height: uint32(i)}
+		headers = append(headers, h)
+		prev = h.Hash()
+	}
+	return headers
+}
+
+func testAddr(port int) net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+}
+
+func TestBlockDownloaderSchedule(t *testing.T) {
+	chain := testHeaderChain(40)
+	d := newBlockDownloader()
+	d.setChain(chain[len(chain)-1].Hash(), chain)
+
+	full, short := testAddr(1), testAddr(2)
+	peers := []downloadPeer{{addr: full, height: 40}, {addr: short, height: 5}}
+	have := func(crypto.Hash) bool { return false }
+	requests := d.schedule(peers, have, time.Now())
+
+	if len(requests[full]) != maxBlocksInFlightPerPeer {
+		t.Errorf("Full peer: got %d requests, expected the in-flight limit %d", len(requests[full]), maxBlocksInFlightPerPeer)
+	}
+	// Block di bawah height peer pendek dibagi rata, sisanya ke peer lengkap.
+	heights := make(map[crypto.Hash]uint32)
+	for _, h := range chain {
+		heights[h.Hash()] = h.Height
+	}
+	if len(requests[short]) != 2 {
+		t.Errorf("Short peer: got %d requests, expected 2", len(requests[short]))
+	}
+	for _, hash := range requests[short] {
+		if heights[hash] > 5 {
+			t.Errorf("Short peer was asked for height %d above its height 5", heights[hash])
+		}
+		if d.inFlight[hash].peer != short {
+			t.Fatal("Block should be in flight to the peer it was requested from")
+		}
+	}
+
+	// Block yang sudah diminta tidak diminta ulang, dan batas per peer dipatuhi.
+	if again := d.schedule(peers, have, time.Now()); len(again) != 0 {
+		t.Errorf("Expected no new requests while peers are at their limit, got %v", again)
+	}
+
+	// Setelah block tiba, slot peer bisa dipakai untuk block berikutnya.
+	if !d.received(requests[full][0]) {
+		t.Fatal("Requested block should be reported as requested")
+	}
+	if d.received(crypto.Hash{0xbb}) {
+		t.Error("Unrequested block should not be reported as requested")
+	}
+	if next := d.schedule(peers, have, time.Now()); len(next[full]) != 1 {
+		t.Errorf("Expected one new request after a block arrived, got %d", len(next[full]))
+	}
+}
+
+func TestBlockDownloaderWindow(t *testing.T) {
+	chain := testHeaderChain(downloadWindow + 10)
+	d := newBlockDownloader()
+	d.setChain(chain[len(chain)-1].Hash(), chain)
+
+	var peers []downloadPeer
+	for i := 0; i < downloadWindow; i++ {
+		peers = append(peers, downloadPeer{addr: testAddr(1000 + i), height: uint32(len(chain))})
+	}
+	requested := make(map[crypto.Hash]bool)
+	for _, hashes := range d.schedule(peers, func(crypto.Hash) bool { return false }, time.Now()) {
+		for _, hash := range hashes {
+			requested[hash] = true
+		}
+	}
+	if len(requested) != downloadWindow || requested[chain[downloadWindow].Hash()] {
+		t.Fatalf("Expected exactly the first %d blocks to be requested, got %d", downloadWindow, len(requested))
+	}
+
+	// Window bergeser setelah block terdepan ter-connect.
+	connected := map[crypto.Hash]bool{chain[0].Hash(): true}
+	d.received(chain[0].Hash())
+	next := d.schedule(peers, func(hash crypto.Hash) bool { return connected[hash] }, time.Now())
+	var got []crypto.Hash
+	for _, hashes := range next {
+		got = append(got, hashes...)
+	}
+	if len(got) != 1 || got[0] != chain[downloadWindow].Hash() {
+		t.Errorf("Expected the window to advance by one block, got %d requests", len(got))
+	}
+}
+
+func TestBlockDownloaderStallReassign(t *testing.T) {
+	chain := testHeaderChain(3)
+	d := newBlockDownloader()
+	d.setChain(chain[len(chain)-1].Hash(), chain)
+
+	slow, fast := testAddr(1), testAddr(2)
+	have := func(crypto.Hash) bool { return false }
+	start := time.Now()
+	requests := d.schedule([]downloadPeer{{addr: slow, height: 3}}, have, start)
+	if len(requests[slow]) != 3 {
+		t.Fatalf("Expected 3 requests to the only peer, got %d", len(requests[slow]))
+	}
+
+	if expired := d.expire(start.Add(blockStallTimeout / 2)); len(expired) != 0 {
+		t.Fatalf("Requests should not expire before the stall timeout, got %d", len(expired))
+	}
+	expired := d.expire(start.Add(blockStallTimeout))
+	if len(expired) != 3 || expired[chain[0].Hash()] != slow {
+		t.Fatalf("Expected all 3 requests to the slow peer to expire, got %v", expired)
+	}
+
+	// Block yang macet dialihkan ke peer lain, bukan ke peer yang macet.
+	peers := []downloadPeer{{addr: slow, height: 3}, {addr: fast, height: 3}}
+	reassigned := d.schedule(peers, have, start.Add(blockStallTimeout))
+	if len(reassigned[fast]) != 3 || len(reassigned[slow]) != 0 {
+		t.Errorf("Expected stalled blocks to move to the other peer, got %d fast and %d slow", len(reassigned[fast]), len(reassigned[slow]))
+	}
+
+	// Peer yang terputus melepaskan permintaannya.
+	d.peerGone(fast)
+	if len(d.inFlight) != 0 || d.perPeer[fast] != 0 {
+		t.Error("Disconnected peer should have no blocks in flight")
+	}
+	// Tanpa peer lain, peer yang macet tetap dipakai.
+	if retry := d.schedule([]downloadPeer{{addr: slow, height: 3}}, have, time.Now()); len(retry[slow]) != 3 {
+		t.Errorf("Expected the stalled peer to be retried when it is the only one, got %d", len(retry[slow]))
+	}
+}
+
+func TestBlockDownloaderBuffer(t *testing.T) {
+	chain := testHeaderChain(3)
+	d := newBlockDownloader()
+	d.setChain(chain[len(chain)-1].Hash(), chain)
+
+	peer := testAddr(1)
+	child := &core.Block{Header: chain[1]}
+	d.buffer(child, peer)
+	requests := d.schedule([]downloadPeer{{addr: peer, height: 3}}, func(crypto.Hash) bool { return false }, time.Now())
+	for _, hash := range requests[peer] {
+		if hash == chain[1].Hash() {
+			t.Error("Buffered block should not be requested again")
+		}
+	}
+	if got := d.takeChild(chain[0].Hash()); got == nil || got.block != child {
+		t.Fatal("Buffered block should be returned once its parent is connected")
+	}
+	if d.takeChild(chain[0].Hash()) != nil {
+		t.Error("Buffered block should only be returned once")
+	}
+
+	// Block yang tidak lagi berada di chain best header dibuang.
+	d.buffer(child, peer)
+	other := testHeaderChain(1)
+	d.setChain(other[0].Hash(), other)
+	if d.takeChild(chain[0].Hash()) != nil {
+		t.Error("Buffered block outside the new best chain should be dropped")
+	}
+}
@@ -0,0 +1,63 @@
+package p2p
+
+import (
+	"sync"
+
+	"swatantra/crypto"
+)
+
+// Tipe inventory pada InvPayload dan GetDataPayload.
+const (
+	InvTypeBlock byte = 'b'
+	InvTypeTx    byte = 't'
+)
+
+const (
+	// MaxInvHashes adalah jumlah hash maksimum dalam satu pesan inv atau getdata.
+	MaxInvHashes = 1000
+	// maxKnownInventory membatasi jumlah inventory yang diingat per peer.
+	maxKnownInventory = 10000
+)
+
+type invKey struct {
+	typ  byte
+	hash crypto.Hash
+}
+
+// knownInventory mencatat inventory yang sudah diketahui sebuah peer, baik
+// karena peer mengirim/mengumumkannya maupun karena kita sudah mengirimnya.
+// Jika penuh, entri tertua dibuang.
+type knownInventory struct {
+	lock  sync.Mutex
+	set   map[invKey]struct{}
+	order []invKey // Antrian FIFO untuk eviction
+}
+
+func newKnownInventory() *knownInventory {
+	return &knownInventory{set: make(map[invKey]struct{})}
+}
+
+// Add mencatat inventory sebagai diketahui. Mengembalikan false jika sudah tercatat.
+func (k *knownInventory) Add(typ byte, hash crypto.Hash) bool {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	key := invKey{typ, hash}
+	if _, ok := k.set[key]; ok {
+		return false
+	}
+	if len(k.order) >= maxKnownInventory {
+		delete(k.set, k.order[0])
+		k.order = k.order[1:]
+	}
+	k.set[key] = struct{}{}
+	k.order = append(k.order, key)
+	return true
+}
+
+// Has memeriksa apakah inventory sudah diketahui.
+func (k *knownInventory) Has(typ byte, hash crypto.Hash) bool {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	_, ok := k.set[invKey{typ, hash}]
+	return ok
+}
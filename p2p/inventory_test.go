@@ -0,0 +1,33 @@
+package p2p
+
+import (
+	"testing"
+
+	"swatantra/crypto"
+)
+
+func TestKnownInventory(t *testing.T) {
+	known := newKnownInventory()
+	if !known.Add(InvTypeBlock, crypto.Hash{1}) {
+		t.Fatal("First Add should report a new entry")
+	}
+	if known.Add(InvTypeBlock, crypto.Hash{1}) {
+		t.Error("Second Add of the same entry should report it as known")
+	}
+	if known.Has(InvTypeTx, crypto.Hash{1}) {
+		t.Error("Inventory types should be tracked separately")
+	}
+
+	// Entri tertua dibuang saat set penuh.
+	var last crypto.Hash
+	for i := 0; i < maxKnownInventory; i++ {
+		last = crypto.Hash{byte(i), byte(i >> 8), 0xff}
+		known.Add(InvTypeTx, last)
+	}
+	if known.Has(InvTypeBlock, crypto.Hash{1}) {
+		t.Error("Oldest entry should have been evicted")
+	}
+	if !known.Has(InvTypeTx, last) {
+		t.Error("Newest entry should still be known")
+	}
+}
@@ -1,6 +1,8 @@
 package p2p
 
 import (
+	"math/big"
+
 	"swatantra/core"
 	"swatantra/crypto"
 )
@@ -9,12 +11,20 @@ import (
 type MessageType byte
 
 const (
-	MessageTypeTx        MessageType = 0x1
-	MessageTypeBlock     MessageType = 0x2
-	MessageTypeGetBlocks MessageType = 0x3
-	MessageTypeInv       MessageType = 0x4
-	MessageTypeGetData   MessageType = 0x5
-	MessageTypeHandshake MessageType = 0x6
+	MessageTypeTx         MessageType = 0x1
+	MessageTypeBlock      MessageType = 0x2
+	MessageTypeGetBlocks  MessageType = 0x3
+	MessageTypeInv        MessageType = 0x4
+	MessageTypeGetData    MessageType = 0x5
+	MessageTypeHandshake  MessageType = 0x6
+	MessageTypeDisconnect MessageType = 0x7
+	MessageTypeGetHeaders MessageType = 0x8
+	MessageTypeHeaders    MessageType = 0x9
+	MessageTypeStatus     MessageType = 0xA
+	MessageTypeGetAddr    MessageType = 0xB // Tanpa payload
+	MessageTypeAddr       MessageType = 0xC
+	MessageTypePing       MessageType = 0xD
+	MessageTypePong       MessageType = 0xE
 )
 
 // Message merepresentasikan pesan yang dikirim antar peer.
@@ -25,10 +35,54 @@ type Message struct {
 
 // HandshakePayload adalah payload untuk pesan handshake.
 type HandshakePayload struct {
-	Version    string
-	Height     uint32
-	HeadHash   crypto.Hash
-	ListenAddr string
+	Version  string
+	Height   uint32
+	HeadHash crypto.Hash
+	// CumulativeWork adalah total work chain hingga HeadHash; peer dibandingkan
+	// berdasarkan nilai ini, bukan height.
+	CumulativeWork *big.Int
+	ListenAddr     string
+	// Nonce acak per node untuk mendeteksi koneksi ke diri sendiri.
+	Nonce uint64
+	// Pruned menandakan node tidak lagi menyimpan body block sampai PrunedHeight.
+	Pruned       bool
+	PrunedHeight uint32
+}
+
+// StatusPayload memberitahu peer tip main chain kita setelah berubah.
+type StatusPayload struct {
+	Height         uint32
+	HeadHash       crypto.Hash
+	CumulativeWork *big.Int
+}
+
+// NetAddress adalah alamat peer yang bisa dihubungi beserta waktu terakhir
+// peer tersebut terlihat aktif (Unix detik).
+type NetAddress struct {
+	Addr     string
+	LastSeen int64
+}
+
+// AddrPayload berisi alamat peer, sebagai balasan getaddr atau pengumuman
+// alamat baru.
+type AddrPayload struct {
+	Addrs []NetAddress
+}
+
+// PingPayload meminta peer membalas dengan pong bernonce sama, untuk
+// memeriksa koneksi masih hidup dan mengukur latency.
+type PingPayload struct {
+	Nonce uint64
+}
+
+// PongPayload adalah balasan ping.
+type PongPayload struct {
+	Nonce uint64
+}
+
+// DisconnectPayload memberitahu peer alasan koneksinya diputus.
+type DisconnectPayload struct {
+	Reason string
 }
 
 // TxPayload adalah payload untuk pesan transaksi.
@@ -43,19 +97,32 @@ type BlockPayload struct {
 
 // GetBlocksPayload adalah payload untuk meminta block.
 type GetBlocksPayload struct {
-	From crypto.Hash // Hash dari block mana permintaan dimulai
-	To   crypto.Hash // Hash dari block mana permintaan berakhir (opsional)
+	From    crypto.Hash   // Hash dari block mana permintaan dimulai
+	To      crypto.Hash   // Hash dari block mana permintaan berakhir (opsional)
+	Locator []crypto.Hash // Block locator peminta; jika diisi, From diabaikan
+}
+
+// GetHeadersPayload meminta header main chain setelah titik percabangan locator.
+type GetHeadersPayload struct {
+	Locator []crypto.Hash // Block locator peminta, dari best header-nya
+	Stop    crypto.Hash   // Header terakhir yang diminta (opsional)
+}
+
+// HeadersPayload adalah balasan getheaders. CumulativeWork diabaikan penerima
+// dan dihitung ulang dari parent masing-masing header.
+type HeadersPayload struct {
+	Headers []*core.Header
 }
 
 // InvPayload adalah payload untuk pesan inventory.
 // Ini digunakan untuk memberitahu peer tentang data baru yang kita miliki.
 type InvPayload struct {
-	Type    byte          // 'b' untuk block, 't' untuk transaksi
-	Hashes  []crypto.Hash
+	Type   byte // InvTypeBlock atau InvTypeTx
+	Hashes []crypto.Hash
 }
 
 // GetDataPayload adalah payload untuk meminta data spesifik (block atau tx).
 type GetDataPayload struct {
-	Type byte        // 'b' untuk block, 't' untuk transaksi
-	Hash crypto.Hash // Hash dari data yang diminta
+	Type   byte          // InvTypeBlock atau InvTypeTx
+	Hashes []crypto.Hash // Hash dari data yang diminta
 }
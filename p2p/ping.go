@@ -0,0 +1,159 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultPingInterval adalah jeda antar ping ke setiap peer. Peer yang tidak
+	// membalas ping sebelum ping berikutnya diputus.
+	DefaultPingInterval = 30 * time.Second
+	// idleTimeoutPings adalah jumlah interval ping tanpa pesan apa pun dari
+	// peer sebelum pembacaan dari koneksinya dihentikan.
+	idleTimeoutPings = 3
+	// handshakeTimeout membatasi waktu menunggu handshake dari peer.
+	handshakeTimeout = 10 * time.Second
+	// writeTimeout membatasi waktu menulis satu frame ke peer.
+	writeTimeout = 30 * time.Second
+)
+
+// PeerInfo adalah statistik koneksi sebuah peer.
+type PeerInfo struct {
+	Addr           string // Alamat remote koneksi
+	ListenAddr     string // Alamat listen peer, kosong jika tidak diketahui
+	Outbound       bool
+	Persistent     bool
+	ConnectedSince time.Time
+	BytesIn        uint64
+	BytesOut       uint64
+	RTT            time.Duration // Nol jika belum ada pong
+	BanScore       int
+}
+
+// startPing mencatat ping baru dan mengembalikan nonce-nya. Mengembalikan false
+// jika ping sebelumnya belum dibalas.
+func (p *Peer) startPing(now time.Time) (uint64, bool) {
+	p.pingLock.Lock()
+	defer p.pingLock.Unlock()
+	if p.pingNonce != 0 {
+		return 0, false
+	}
+	for p.pingNonce == 0 {
+		p.pingNonce = rand.Uint64()
+	}
+	p.pingSent = now
+	return p.pingNonce, true
+}
+
+// pingPending memeriksa apakah ada ping yang belum dibalas.
+func (p *Peer) pingPending() bool {
+	p.pingLock.Lock()
+	defer p.pingLock.Unlock()
+	return p.pingNonce != 0
+}
+
+// pongReceived mencatat RTT jika nonce cocok dengan ping yang belum dibalas.
+func (p *Peer) pongReceived(nonce uint64, now time.Time) bool {
+	p.pingLock.Lock()
+	defer p.pingLock.Unlock()
+	if nonce == 0 || nonce != p.pingNonce {
+		return false
+	}
+	p.rtt = now.Sub(p.pingSent)
+	p.pingNonce = 0
+	return true
+}
+
+// lastRTT mengembalikan RTT ping terakhir yang dibalas.
+func (p *Peer) lastRTT() time.Duration {
+	p.pingLock.Lock()
+	defer p.pingLock.Unlock()
+	return p.rtt
+}
+
+// idleTimeout adalah batas waktu membaca satu pesan dari peer.
+func (s *Server) idleTimeout() time.Duration {
+	return idleTimeoutPings * s.pingInterval
+}
+
+// pingLoop mengirim ping ke peer setiap pingInterval hingga koneksinya
+// terputus. Peer yang belum membalas ping sebelumnya diputus.
+func (s *Server) pingLoop(peer *Peer) {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-peer.done:
+			return
+		case now = <-ticker.C:
+		}
+		// Waktu tick bisa bergeser, jadi ping dianggap timeout jika belum
+		// dibalas saat tick berikutnya, bukan berdasarkan selisih waktunya
+		if peer.pingPending() {
+			s.sendDisconnect(peer, "ping timeout")
+			peer.conn.Close()
+			return
+		}
+		if nonce, ok := peer.startPing(now); ok {
+			if err := sendPayload(peer, MessageTypePing, PingPayload{Nonce: nonce}); err != nil {
+				log.Printf("P2P: Error sending ping to %s: %v", peer.conn.RemoteAddr(), err)
+			}
+		}
+	}
+}
+
+// handlePing membalas ping dengan pong bernonce sama. Ping dan pong ditangani
+// langsung di readLoop agar RTT tidak termasuk antrean ProcessMessages.
+func (s *Server) handlePing(peer *Peer, msg *Message) {
+	var payload PingPayload
+	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&payload); err != nil {
+		log.Printf("P2P: Error decoding PingPayload from %s: %v", peer.conn.RemoteAddr(), err)
+		s.misbehaving(peer.conn.RemoteAddr(), scoreMalformedMessage, "malformed PingPayload")
+		return
+	}
+	if err := sendPayload(peer, MessageTypePong, PongPayload{Nonce: payload.Nonce}); err != nil {
+		log.Printf("P2P: Error sending pong to %s: %v", peer.conn.RemoteAddr(), err)
+	}
+}
+
+// handlePong mencatat RTT peer dari balasan ping kita.
+func (s *Server) handlePong(peer *Peer, msg *Message) {
+	var payload PongPayload
+	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&payload); err != nil {
+		log.Printf("P2P: Error decoding PongPayload from %s: %v", peer.conn.RemoteAddr(), err)
+		s.misbehaving(peer.conn.RemoteAddr(), scoreMalformedMessage, "malformed PongPayload")
+		return
+	}
+	if !peer.pongReceived(payload.Nonce, time.Now()) {
+		s.misbehaving(peer.conn.RemoteAddr(), scoreUnsolicited, "unexpected pong")
+	}
+}
+
+// Peers mengembalikan statistik semua peer yang terhubung, dari yang paling
+// lama terhubung.
+func (s *Server) Peers() []PeerInfo {
+	s.lock.RLock()
+	infos := make([]PeerInfo, 0, len(s.peers))
+	for addr, peer := range s.peers {
+		infos = append(infos, PeerInfo{
+			Addr:           addr.String(),
+			ListenAddr:     peer.addr,
+			Outbound:       peer.outbound,
+			Persistent:     peer.persistent,
+			ConnectedSince: peer.connectedAt,
+			BytesIn:        peer.bytesIn.Load(),
+			BytesOut:       peer.bytesOut.Load(),
+			RTT:            peer.lastRTT(),
+			BanScore:       peer.banScore,
+		})
+	}
+	s.lock.RUnlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ConnectedSince.Before(infos[j].ConnectedSince) })
+	return infos
+}
@@ -0,0 +1,74 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPingMeasuresRTT(t *testing.T) {
+	opts := Options{PingInterval: 50 * time.Millisecond}
+	_, targetAddr := startTestServer(t, opts)
+	s, _ := startTestServer(t, opts)
+	// Target mungkin belum selesai membuka listener-nya
+	var err error
+	for attempt := 0; attempt < 50; attempt++ {
+		if err = s.Connect(targetAddr); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if peers := s.Peers(); len(peers) == 1 && peers[0].RTT > 0 {
+			p := peers[0]
+			if !p.Outbound || p.ListenAddr != targetAddr {
+				t.Errorf("Unexpected peer info %+v", p)
+			}
+			if p.BytesIn == 0 || p.BytesOut == 0 {
+				t.Errorf("Expected traffic to be counted, got %d in and %d out", p.BytesIn, p.BytesOut)
+			}
+			if p.ConnectedSince.IsZero() || p.ConnectedSince.After(time.Now()) {
+				t.Errorf("Unexpected connected-since time %v", p.ConnectedSince)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for a ping round trip")
+}
+
+func TestUnresponsivePeerDisconnected(t *testing.T) {
+	_, addr := startTestServer(t, Options{PingInterval: 100 * time.Millisecond})
+	peer, msg := dialTestPeer(t, addr, net.IPv4(127, 0, 0, 1))
+	if msg.Type != MessageTypeHandshake {
+		t.Fatalf("Expected handshake reply, got message type %d", msg.Type)
+	}
+
+	// Peer ini tidak pernah membalas ping, sehingga diputus setelah satu interval.
+	pinged := false
+	peer.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		msg, err := peer.Receive()
+		if err != nil {
+			t.Fatalf("Expected a disconnect message before the connection closed: %v", err)
+		}
+		switch msg.Type {
+		case MessageTypePing:
+			pinged = true
+		case MessageTypeDisconnect:
+			var payload DisconnectPayload
+			gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&payload)
+			if !pinged || payload.Reason != "ping timeout" {
+				t.Errorf("Expected a ping timeout after a ping, got %q (pinged %v)", payload.Reason, pinged)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package p2p
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// disconnectTimeout membatasi waktu menulis pesan disconnect, yang ditulis
+// langsung oleh goroutine yang memutus peer.
+const disconnectTimeout = time.Second
+
+// Prioritas antrean kirim peer, dari yang paling didahulukan.
+const (
+	priorityControl = iota
+	priorityBlock
+	priorityTx
+	numPriorities
+)
+
+// sendQueueSizes adalah kapasitas antrean kirim per prioritas. Antrean block
+// dan transaksi cukup untuk membalas satu getdata penuh.
+var sendQueueSizes = [numPriorities]int{
+	priorityControl: 500,
+	priorityBlock:   MaxInvHashes,
+	priorityTx:      MaxInvHashes,
+}
+
+var (
+	errSendQueueFull = errors.New("send queue full")
+	errPeerClosed    = errors.New("peer connection closed")
+)
+
+// messagePriority menentukan antrean kirim untuk tipe pesan. Pesan selain
+// block dan transaksi kecil dan mengatur jalannya sinkronisasi, sehingga
+// didahulukan.
+func messagePriority(t MessageType) int {
+	switch t {
+	case MessageTypeBlock, MessageTypeHeaders:
+		return priorityBlock
+	case MessageTypeTx:
+		return priorityTx
+	default:
+		return priorityControl
+	}
+}
+
+// writeLoop menulis pesan dari antrean kirim ke koneksi, selalu mengambil
+// dari antrean dengan prioritas tertinggi yang berisi. Berhenti saat peer
+// ditutup atau penulisan gagal.
+func (p *Peer) writeLoop() {
+	control, blocks, txs := p.queues[priorityControl], p.queues[priorityBlock], p.queues[priorityTx]
+	for {
+		var msg *Message
+		select {
+		case msg = <-control:
+		default:
+			select {
+			case msg = <-control:
+			case msg = <-blocks:
+			default:
+				select {
+				case msg = <-control:
+				case msg = <-blocks:
+				case msg = <-txs:
+				case <-p.done:
+					return
+				}
+			}
+		}
+		if err := p.write(msg, writeTimeout); err != nil {
+			log.Printf("P2P: Error writing to %s: %v", p.conn.RemoteAddr(), err)
+			p.conn.Close()
+			return
+		}
+	}
+}
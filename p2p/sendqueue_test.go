@@ -0,0 +1,75 @@
+package p2p
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSendQueuePriority(t *testing.T) {
+	local, remote := net.Pipe()
+	peer := NewPeer(local, MagicRegtest)
+	defer peer.close()
+	reader := NewPeer(remote, MagicRegtest)
+	defer reader.close()
+
+	// Pesan pertama diambil writeLoop dan tertahan karena pipe belum dibaca.
+	if err := peer.Send(&Message{Type: MessageTypeTx, Payload: []byte{1}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(peer.queues[priorityTx]) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the writer to take the first message")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, msg := range []*Message{
+		{Type: MessageTypeTx, Payload: []byte{2}},
+		{Type: MessageTypeBlock, Payload: []byte{3}},
+		{Type: MessageTypeInv, Payload: []byte{4}},
+	} {
+		if err := peer.Send(msg); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	// Pesan yang mengantre ditulis sesuai prioritas: control, block, lalu transaksi.
+	remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []byte{1, 4, 3, 2} {
+		msg, err := reader.Receive()
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if msg.Payload[0] != want {
+			t.Errorf("Expected message %d, got %d (type %d)", want, msg.Payload[0], msg.Type)
+		}
+	}
+}
+
+func TestSendQueueOverflowDisconnects(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	peer := NewPeer(local, MagicRegtest)
+	defer peer.close()
+
+	// Tidak ada yang membaca dari pipe: satu pesan tertahan di writeLoop dan
+	// sisanya memenuhi antrean.
+	var err error
+	for i := 0; i <= sendQueueSizes[priorityTx]+1 && err == nil; i++ {
+		err = peer.Send(&Message{Type: MessageTypeTx})
+	}
+	if !errors.Is(err, errSendQueueFull) {
+		t.Fatalf("Expected the send queue to overflow, got %v", err)
+	}
+	// Koneksi peer yang lambat ditutup.
+	if _, err := local.Write([]byte{0}); err == nil {
+		t.Error("Connection should be closed after the send queue overflows")
+	}
+
+	peer.close()
+	if err := peer.Send(&Message{Type: MessageTypeInv}); !errors.Is(err, errPeerClosed) {
+		t.Errorf("Send to a closed peer should fail with errPeerClosed, got %v", err)
+	}
+}
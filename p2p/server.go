@@ -1,16 +1,21 @@
 package p2p
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/gob"
 	"errors"
 	"fmt"
 	"log"
+	"math/big"
+	"math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"swatantra/core"
+	"swatantra/crypto"
 	"swatantra/mempool"
 )
 
@@ -59,39 +64,218 @@ func (rl *RateLimiter) Allow() bool {
 	return false
 }
 
+// Wait menunggu sampai satu token tersedia lalu memakainya. Mengembalikan
+// false jika done ditutup sebelum token tersedia.
+func (rl *RateLimiter) Wait(done <-chan struct{}) bool {
+	for !rl.Allow() {
+		select {
+		case <-done:
+			return false
+		case <-time.After(time.Second / time.Duration(rl.rate)):
+		}
+	}
+	return true
+}
+
 // Peer merepresentasikan node lain yang terhubung.
 type Peer struct {
-	conn    net.Conn
-	encoder *gob.Encoder
-	decoder *gob.Decoder
-	limiter *RateLimiter
+	conn      net.Conn
+	magic     Magic
+	reader    *bufio.Reader
+	writeLock sync.Mutex
+	limiter   *RateLimiter
+	knownInv  *knownInventory // Inventory yang sudah diketahui peer
+	requested *knownInventory // Inventory yang kita minta lewat getdata
+
+	// Dipakai untuk mengenali data yang tidak diminta; hanya diakses dari
+	// goroutine ProcessMessages.
+	headersRequested int    // Permintaan getheaders yang belum dibalas
+	historyRequested bool   // History block dari genesis diminta lewat getblocks
+	historyEnd       uint32 // Height block terakhir di halaman getblocks history yang diminta
+
+	// Tip chain peer dengan cumulative work terbesar yang header-nya sudah tervalidasi
+	bestHeight uint32
+	bestHash   crypto.Hash
+	bestWork   *big.Int
+
+	outbound   bool   // Koneksi dibuka oleh kita
+	persistent bool   // Peer addnode yang dihubungi ulang jika terputus
+	addr       string // Alamat listen peer yang bisa dihubungi, kosong jika tidak diketahui
+	sentAddrs  bool   // getaddr dari peer sudah dibalas
+
+	// Dipakai untuk memilih peer inbound yang diputus saat slot penuh; lastBlock
+	// dan lastTx dilindungi s.lock.
+	connectedAt time.Time
+	lastBlock   time.Time     // Terakhir mengirim block baru
+	lastTx      time.Time     // Terakhir mengirim transaksi baru
+	done        chan struct{} // Ditutup saat koneksi terputus
+	closeOnce   sync.Once
+	banScore    int // Dilindungi s.lock
+
+	// Antrean kirim per prioritas, dikosongkan oleh writeLoop
+	queues [numPriorities]chan *Message
+
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+
+	// Jumlah pesan block, tx dan headers yang kita minta dan belum tiba;
+	// pesan tersebut tidak dikenai rate limit.
+	pendingReplies atomic.Int64
+
+	pingLock  sync.Mutex
+	pingNonce uint64        // Nonce ping yang belum dibalas, nol jika tidak ada
+	pingSent  time.Time     // Waktu ping yang belum dibalas dikirim
+	rtt       time.Duration // Round-trip time ping terakhir
 }
 
-func NewPeer(conn net.Conn) *Peer {
-	return &Peer{
-		conn:    conn,
-		encoder: gob.NewEncoder(conn),
-		decoder: gob.NewDecoder(conn),
-		limiter: NewRateLimiter(10, 100), // 10 msg/sec, burst of 100
+// NewPeer membuat Peer untuk koneksi dan menjalankan writeLoop-nya hingga
+// peer ditutup.
+func NewPeer(conn net.Conn, magic Magic) *Peer {
+	p := &Peer{
+		conn:        conn,
+		magic:       magic,
+		reader:      bufio.NewReader(conn),
+		limiter:     NewRateLimiter(10, 100), // 10 msg/sec, burst of 100
+		knownInv:    newKnownInventory(),
+		requested:   newKnownInventory(),
+		connectedAt: time.Now(),
+		done:        make(chan struct{}),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan *Message, sendQueueSizes[i])
 	}
+	go p.writeLoop()
+	return p
 }
 
-// Send mengirim pesan ke peer.
+// expectReplies mencatat n balasan yang kita minta dari peer.
+func (p *Peer) expectReplies(n int) {
+	p.pendingReplies.Add(int64(n))
+}
+
+// takeReply melaporkan apakah msg adalah balasan atas permintaan kita yang
+// masih ditunggu, dan jika ya mengurangi jumlah balasan yang ditunggu.
+func (p *Peer) takeReply(msg *Message) bool {
+	switch msg.Type {
+	case MessageTypeBlock, MessageTypeTx, MessageTypeHeaders:
+	default:
+		return false
+	}
+	for {
+		n := p.pendingReplies.Load()
+		if n <= 0 {
+			return false
+		}
+		if p.pendingReplies.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+// Send memasukkan pesan ke antrean kirim peer sesuai prioritasnya tanpa
+// menunggu pesan ditulis. Jika antrean penuh, peer terlalu lambat membaca;
+// pesan dibuang dan koneksinya ditutup.
 func (p *Peer) Send(msg *Message) error {
-	return p.encoder.Encode(msg)
+	select {
+	case <-p.done:
+		return errPeerClosed
+	default:
+	}
+	select {
+	case p.queues[messagePriority(msg.Type)] <- msg:
+		return nil
+	default:
+		log.Printf("P2P: Send queue to %s is full, disconnecting", p.conn.RemoteAddr())
+		p.conn.Close()
+		return errSendQueueFull
+	}
 }
 
-const BlacklistDuration = 24 * time.Hour // Durasi peer akan berada di daftar hitam
+// close menutup koneksi peer dan menghentikan writeLoop-nya.
+func (p *Peer) close() {
+	p.closeOnce.Do(func() {
+		p.conn.Close()
+		close(p.done)
+	})
+}
+
+// write menulis pesan ke koneksi sebagai satu frame. Peer yang tidak membaca
+// dalam timeout membuat write gagal, bukan menahan penulis selamanya.
+func (p *Peer) write(msg *Message, timeout time.Duration) error {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+	p.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if err := writeFrame(p.conn, p.magic, msg); err != nil {
+		return err
+	}
+	p.bytesOut.Add(uint64(frameHeaderSize + len(msg.Payload)))
+	return nil
+}
+
+// Receive membaca frame berikutnya dari peer. Frame yang rusak menghasilkan
+// error yang membungkus ErrInvalidFrame.
+func (p *Peer) Receive() (*Message, error) {
+	msg, err := readFrame(p.reader, p.magic)
+	if err != nil {
+		return nil, err
+	}
+	p.bytesIn.Add(uint64(frameHeaderSize + len(msg.Payload)))
+	return msg, nil
+}
+
+// updateTip mencatat tip chain peer jika work-nya lebih besar dari tip yang
+// sudah diketahui, sesuai aturan fork choice Blockchain.
+func (p *Peer) updateTip(height uint32, hash crypto.Hash, work *big.Int) {
+	if work == nil || (p.bestWork != nil && work.Cmp(p.bestWork) <= 0) {
+		return
+	}
+	p.bestHeight = height
+	p.bestHash = hash
+	p.bestWork = work
+}
+
+// Options mengatur perilaku Server. Nilai nol memakai default.
+type Options struct {
+	// Magic adalah magic jaringan pada setiap frame, default MagicMainnet.
+	Magic Magic
+	// AddrBook menyimpan alamat peer yang diketahui. Nil memakai AddrBook di
+	// memori yang tidak disimpan ke disk.
+	AddrBook *AddrBook
+	// MaxOutbound adalah target jumlah koneksi outbound, default DefaultMaxOutbound.
+	MaxOutbound int
+	// MaxInbound adalah jumlah maksimum koneksi inbound, default DefaultMaxInbound.
+	MaxInbound int
+	// MaxInboundPerIP membatasi koneksi inbound dari satu IP, default
+	// DefaultMaxInboundPerIP.
+	MaxInboundPerIP int
+	// BanList menyimpan IP yang di-ban. Nil memakai BanList di memori yang
+	// hilang saat node di-restart.
+	BanList *BanList
+	// PingInterval adalah jeda antar ping ke setiap peer, default DefaultPingInterval.
+	PingInterval time.Duration
+}
 
 // Server adalah server P2P yang mengelola koneksi peer.
 type Server struct {
 	listenAddr string
+	magic      Magic
 	listener   net.Listener
 	peers      map[net.Addr]*Peer
 	lock       sync.RWMutex
-	blacklist  map[string]time.Time
+	banList    *BanList
+	nonce      uint64 // Nonce handshake untuk mendeteksi koneksi ke diri sendiri
+
+	addrBook        *AddrBook
+	maxOutbound     int
+	maxInbound      int
+	maxInboundPerIP int
+	dialing         map[string]bool // Alamat yang sedang dihubungi; true untuk peer addnode
+	localAddrs      map[string]bool // Alamat yang ternyata node kita sendiri
+	addNodes        map[string]bool // Alamat peer addnode
+	pingInterval    time.Duration
 
 	msgCh      chan *RPC
+	downloads  *blockDownloader
 	blockchain *core.Blockchain
 	mempool    *mempool.Mempool
 }
@@ -103,15 +287,52 @@ type RPC struct {
 	Type    MessageType
 }
 
-// NewServer membuat instance baru dari Server.
+// NewServer membuat instance baru dari Server dengan opsi default.
 func NewServer(listenAddr string, bc *core.Blockchain, mp *mempool.Mempool) *Server {
+	return NewServerWithOptions(listenAddr, bc, mp, Options{})
+}
+
+// NewServerWithOptions membuat instance baru dari Server dengan opsi tambahan.
+func NewServerWithOptions(listenAddr string, bc *core.Blockchain, mp *mempool.Mempool, opts Options) *Server {
+	if opts.Magic == (Magic{}) {
+		opts.Magic = MagicMainnet
+	}
+	if opts.AddrBook == nil {
+		opts.AddrBook, _ = NewAddrBook("")
+	}
+	if opts.MaxOutbound == 0 {
+		opts.MaxOutbound = DefaultMaxOutbound
+	}
+	if opts.MaxInbound == 0 {
+		opts.MaxInbound = DefaultMaxInbound
+	}
+	if opts.MaxInboundPerIP == 0 {
+		opts.MaxInboundPerIP = DefaultMaxInboundPerIP
+	}
+	if opts.BanList == nil {
+		opts.BanList, _ = NewBanList("")
+	}
+	if opts.PingInterval == 0 {
+		opts.PingInterval = DefaultPingInterval
+	}
 	return &Server{
-		listenAddr: listenAddr,
-		peers:      make(map[net.Addr]*Peer),
-		blacklist:  make(map[string]time.Time),
-		msgCh:      make(chan *RPC, 128),
-		blockchain: bc,
-		mempool:    mp,
+		listenAddr:      listenAddr,
+		magic:           opts.Magic,
+		peers:           make(map[net.Addr]*Peer),
+		banList:         opts.BanList,
+		nonce:           rand.Uint64(),
+		addrBook:        opts.AddrBook,
+		maxOutbound:     opts.MaxOutbound,
+		maxInbound:      opts.MaxInbound,
+		maxInboundPerIP: opts.MaxInboundPerIP,
+		dialing:         make(map[string]bool),
+		localAddrs:      make(map[string]bool),
+		addNodes:        make(map[string]bool),
+		pingInterval:    opts.PingInterval,
+		msgCh:           make(chan *RPC, 128),
+		downloads:       newBlockDownloader(),
+		blockchain:      bc,
+		mempool:         mp,
 	}
 }
 
@@ -124,6 +345,7 @@ func (s *Server) Start() error {
 	s.listener = ln
 
 	fmt.Printf("Server P2P berjalan di %s\n", s.listenAddr)
+	go s.maintainOutbound()
 
 	for {
 		conn, err := s.listener.Accept()
@@ -136,52 +358,67 @@ func (s *Server) Start() error {
 	}
 }
 
-// blacklistPeer menambahkan IP peer ke daftar hitam.
-func (s *Server) blacklistPeer(peer *Peer) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// sendDisconnect memberitahu peer alasan koneksinya akan diputus. Pesan
+// ditulis langsung, melewati antrean kirim, karena koneksi segera ditutup.
+// Pemanggil tetap bertanggung jawab menutup koneksi.
+func (s *Server) sendDisconnect(peer *Peer, reason string) {
+	log.Printf("P2P: Disconnecting %s: %s", peer.conn.RemoteAddr(), reason)
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(DisconnectPayload{Reason: reason}); err != nil {
+		return
+	}
+	peer.write(&Message{Type: MessageTypeDisconnect, Payload: buf.Bytes()}, disconnectTimeout)
+}
 
-	// Dapatkan hanya IP, tanpa port
-	ip := peer.conn.RemoteAddr().(*net.TCPAddr).IP.String()
-	if _, exists := s.blacklist[ip]; !exists {
-		s.blacklist[ip] = time.Now()
-		fmt.Printf("Peer %s has been blacklisted.\n", ip)
+// logDisconnect mencatat alasan yang dikirim peer sebelum memutus koneksi dan
+// mengembalikannya.
+func logDisconnect(peer *Peer, msg *Message) string {
+	var payload DisconnectPayload
+	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&payload); err != nil {
+		log.Printf("P2P: Peer %s disconnected with an unreadable reason: %v", peer.conn.RemoteAddr(), err)
+		return ""
 	}
+	log.Printf("P2P: Peer %s disconnected: %s", peer.conn.RemoteAddr(), payload.Reason)
+	return payload.Reason
 }
 
 // handleConnection menangani koneksi masuk dari peer.
 func (s *Server) handleConnection(conn net.Conn) {
 	ip := conn.RemoteAddr().(*net.TCPAddr).IP.String()
-	s.lock.RLock()
-	if blacklistedAt, exists := s.blacklist[ip]; exists {
-		if time.Since(blacklistedAt) > BlacklistDuration {
-			s.lock.RUnlock() // Release RLock
-			s.lock.Lock()    // Acquire Write Lock
-			delete(s.blacklist, ip)
-			s.lock.Unlock()  // Release Write Lock
-			s.lock.RLock()   // Re-acquire RLock for subsequent checks
-			fmt.Printf("Peer %s was blacklisted at %v, but duration expired. Allowing connection.\n", ip, blacklistedAt)
-			// Continue with connection handling
-		} else {
-			s.lock.RUnlock()
-			fmt.Printf("Refusing connection from blacklisted peer %s (blacklisted at %v, expires in %v)\n", ip, blacklistedAt, blacklistedAt.Add(BlacklistDuration).Sub(time.Now()))
-			conn.Close()
-			return
-		}
+	if ban, banned := s.banList.IsBanned(ip, time.Now()); banned {
+		log.Printf("P2P: Refusing connection from banned peer %s until %v: %s", ip, ban.Until.Format(time.RFC3339), ban.Reason)
+		conn.Close()
+		return
 	}
-	s.lock.RUnlock()
 
-	peer := NewPeer(conn)
+	peer := NewPeer(conn, s.magic)
 
 	s.lock.Lock()
-	s.peers[conn.RemoteAddr()] = peer
+	evict, err := s.admitInbound(ip)
+	if err == nil {
+		s.peers[conn.RemoteAddr()] = peer
+	}
 	s.lock.Unlock()
+	if err != nil {
+		s.sendDisconnect(peer, err.Error())
+		peer.close()
+		return
+	}
+	if evict != nil {
+		// Slot inbound penuh, putus peer yang paling tidak berguna; readLoop-nya
+		// akan membersihkan sisanya
+		s.sendDisconnect(evict, "evicted to make room for a new inbound peer")
+		evict.conn.Close()
+	}
 
 	// Untuk koneksi masuk, kita bertindak sebagai responder handshake
 	if err := s.respondHandshake(peer); err != nil {
 		fmt.Printf("Handshake gagal dengan %s: %v\n", conn.RemoteAddr(), err)
-		s.blacklistPeer(peer)
-		conn.Close()
+		if errors.Is(err, ErrInvalidFrame) || errors.Is(err, errSelfConnection) {
+			// Misalnya node dari jaringan lain; beri tahu alasannya
+			s.sendDisconnect(peer, err.Error())
+		}
+		peer.close()
 		s.lock.Lock()
 		delete(s.peers, conn.RemoteAddr())
 		s.lock.Unlock()
@@ -196,8 +433,9 @@ func (s *Server) handleConnection(conn net.Conn) {
 // respondHandshake menangani handshake dari peer yang masuk (sebagai responder).
 func (s *Server) respondHandshake(peer *Peer) error {
 	// Terima handshake dari peer
-	handshakeMsg := &Message{}
-	if err := peer.decoder.Decode(handshakeMsg); err != nil {
+	peer.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	handshakeMsg, err := peer.Receive()
+	if err != nil {
 		return err
 	}
 	if handshakeMsg.Type != MessageTypeHandshake {
@@ -208,14 +446,12 @@ func (s *Server) respondHandshake(peer *Peer) error {
 		return err
 	}
 	log.Printf("Menerima handshake dari %s (version: %s, height: %d)", peer.conn.RemoteAddr(), peerHandshake.Version, peerHandshake.Height)
+	if peerHandshake.Nonce == s.nonce {
+		return errSelfConnection
+	}
 
 	// Kirim handshake kita sebagai balasan
-	myHandshake := HandshakePayload{
-		Version:    "swatantra-0.1",
-		Height:     s.blockchain.Head().Height,
-		HeadHash:   s.blockchain.Head().Hash(),
-		ListenAddr: s.listenAddr,
-	}
+	myHandshake := s.newHandshake()
 	buf := new(bytes.Buffer)
 	if err := gob.NewEncoder(buf).Encode(myHandshake); err != nil {
 		return err
@@ -228,8 +464,9 @@ func (s *Server) respondHandshake(peer *Peer) error {
 		return err
 	}
 
-	// Setelah bertukar handshake, tangani perbandingan chain
-	return s.handleHandshake(peer, &peerHandshake)
+	// Setelah bertukar handshake, sinkronisasi ditangani ProcessMessages
+	s.queueHandshake(peer, handshakeMsg.Payload)
+	return nil
 }
 
 
@@ -237,26 +474,47 @@ func (s *Server) respondHandshake(peer *Peer) error {
 func (s *Server) readLoop(peer *Peer) {
 	conn := peer.conn
 	defer func() {
-		conn.Close()
 		s.lock.Lock()
 		delete(s.peers, conn.RemoteAddr())
 		s.lock.Unlock()
+		peer.close()
+		// Block yang diminta dari peer ini dijadwalkan ulang saat pemeriksaan berikutnya
+		s.downloads.peerGone(conn.RemoteAddr())
 		fmt.Printf("Peer disconnected: %s\n", conn.RemoteAddr())
 	}()
 
+	go s.pingLoop(peer)
 	for {
-		if !peer.limiter.Allow() {
-			fmt.Printf("Peer %s is sending messages too fast. Blacklisting and disconnecting.\n", conn.RemoteAddr())
-			s.blacklistPeer(peer)
-			return // Defer will handle closing and cleanup
+		// Peer yang tidak mengirim apa pun, termasuk pong, dianggap mati
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout()))
+		msg, err := peer.Receive()
+		if err != nil {
+			var netErr net.Error
+			if errors.Is(err, ErrInvalidFrame) {
+				s.sendDisconnect(peer, err.Error())
+			} else if errors.As(err, &netErr) && netErr.Timeout() {
+				s.sendDisconnect(peer, "idle timeout")
+			}
+			return
 		}
-
-		msg := &Message{}
-		if err := peer.decoder.Decode(msg); err != nil {
-			// fmt.Printf("Error decoding message from %s: %v. Blacklisting and disconnecting.\n", conn.RemoteAddr(), err)
-			// s.blacklistPeer(peer)
+		if msg.Type == MessageTypeDisconnect {
+			logDisconnect(peer, msg)
+			return
+		}
+		// Balasan atas permintaan kita tidak dibatasi. Pesan lain yang melebihi
+		// rate limit ditahan sampai token tersedia, sehingga peer yang
+		// membanjiri hanya diperlambat
+		if !peer.takeReply(msg) && !peer.limiter.Wait(peer.done) {
 			return
 		}
+		switch msg.Type {
+		case MessageTypePing:
+			s.handlePing(peer, msg)
+			continue
+		case MessageTypePong:
+			s.handlePong(peer, msg)
+			continue
+		}
 
 		s.msgCh <- &RPC{
 			From:    conn.RemoteAddr(),
@@ -268,16 +526,22 @@ func (s *Server) readLoop(peer *Peer) {
 
 // ProcessMessages Loop utama untuk memproses pesan yang masuk.
 func (s *Server) ProcessMessages() {
-	for rpc := range s.msgCh {
+	ticker := time.NewTicker(downloadCheckInterval)
+	defer ticker.Stop()
+	for {
+		var rpc *RPC
+		select {
+		case rpc = <-s.msgCh:
+		case <-ticker.C:
+			s.checkStalledDownloads()
+			continue
+		}
 		switch rpc.Type {
 		case MessageTypeTx:
 			var payload TxPayload
 			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
 				log.Println("Error decoding TxPayload:", err)
-				continue
-			}
-			if err := s.mempool.Add(payload.Tx); err != nil {
-				// log.Println("Error adding transaction to mempool:", err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed TxPayload")
 				continue
 			}
 			txHash, err := payload.Tx.Hash()
@@ -285,41 +549,72 @@ func (s *Server) ProcessMessages() {
 				log.Println("Error getting transaction hash:", err)
 				continue
 			}
+			if peer, ok := s.getPeer(rpc.From); ok && !peer.requested.Has(InvTypeTx, txHash) {
+				s.misbehaving(rpc.From, scoreUnsolicited, "unrequested transaction "+txHash.ToHex())
+			}
+			if err := s.mempool.Add(payload.Tx); err != nil {
+				if errors.Is(err, mempool.ErrInvalidTx) {
+					s.misbehaving(rpc.From, scoreInvalidTx, err.Error())
+				}
+				continue
+			}
 			log.Printf("Received new transaction: %s\n", txHash.ToHex())
-			// Broadcast ke peer lain (kecuali pengirim)
-			s.broadcast(rpc.Payload, rpc.Type, rpc.From)
+			s.markUseful(rpc.From, false)
+			if peer, ok := s.getPeer(rpc.From); ok {
+				peer.knownInv.Add(InvTypeTx, txHash)
+			}
+			// Umumkan ke peer lain yang belum mengetahuinya
+			s.announce(InvTypeTx, []crypto.Hash{txHash})
 
 		case MessageTypeBlock:
 			var payload BlockPayload
 			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
 				log.Printf("P2P: Error decoding BlockPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed BlockPayload")
 				continue
 			}
-			blockHash, _ := payload.Block.Hash()
-			log.Printf("P2P: Received Block %s (height %d) from %s", blockHash.ToHex(), payload.Block.Header.Height, rpc.From)
+			s.handleBlock(payload.Block, rpc.From)
 
-			if err := s.blockchain.AddBlock(payload.Block); err != nil {
-				// This error is now critical for debugging sync issues.
-				log.Printf("P2P: Failed to add block %s from %s: %v", blockHash.ToHex(), rpc.From, err)
+		case MessageTypeInv:
+			var payload InvPayload
+			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
+				log.Printf("P2P: Error decoding InvPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed InvPayload")
 				continue
 			}
-			// Hapus transaksi dari mempool yang sudah masuk block
-			for _, tx := range payload.Block.Transactions {
-				txHash, _ := tx.Hash()
-				s.mempool.Remove(txHash)
+			s.handleInv(rpc.From, &payload)
+
+		case MessageTypeGetData:
+			var payload GetDataPayload
+			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
+				log.Printf("P2P: Error decoding GetDataPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed GetDataPayload")
+				continue
 			}
-			// Broadcast ke peer lain (kecuali pengirim)
-			s.broadcast(rpc.Payload, rpc.Type, rpc.From)
+			s.handleGetData(rpc.From, &payload)
+
 		case MessageTypeGetBlocks:
 			var payload GetBlocksPayload
 			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
 				log.Printf("P2P: Error decoding GetBlocksPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed GetBlocksPayload")
 				continue
 			}
-			log.Printf("P2P: Received GetBlocks request from %s (from_hash: %s)", rpc.From, payload.From.ToHex())
+			log.Printf("P2P: Received GetBlocks request from %s (from_hash: %s, locator: %d hashes)", rpc.From, payload.From.ToHex(), len(payload.Locator))
+
+			// Dengan locator, mulai dari titik percabangan chain peminta dengan main chain kita
+			from := payload.From
+			if len(payload.Locator) > 0 {
+				forkPoint, err := s.blockchain.FindForkPoint(payload.Locator)
+				if err != nil {
+					log.Println("Error finding fork point:", err)
+					continue
+				}
+				from = forkPoint.Hash()
+			}
 
-			// Temukan block yang diminta
-			blocks, err := s.blockchain.GetBlocksFrom(payload.From)
+			// Balasan dibatasi MaxBlocksPerMessage; peminta melanjutkan dari block terakhir
+			blocks, err := s.blockchain.GetBlocksFrom(from, MaxBlocksPerMessage)
 			if err != nil {
 				log.Println("Error getting blocks from blockchain:", err)
 				continue
@@ -328,76 +623,341 @@ func (s *Server) ProcessMessages() {
 			log.Printf("P2P: Found %d blocks to send to %s", len(blocks), rpc.From)
 
 			// Kirim block kembali ke pengirim
+			peer, ok := s.getPeer(rpc.From)
+			if !ok {
+				log.Println("Sender peer not found:", rpc.From)
+				continue
+			}
 			for _, block := range blocks {
-				blockPayload := BlockPayload{Block: block}
-				buf := new(bytes.Buffer)
-				if err := gob.NewEncoder(buf).Encode(blockPayload); err != nil {
-					log.Println("Error encoding block payload:", err)
-					continue
-				}
-				msg := &Message{
-					Type:    MessageTypeBlock,
-					Payload: buf.Bytes(),
-				}
-				peer, ok := s.peers[rpc.From]
-				if !ok {
-					log.Println("Sender peer not found:", rpc.From)
-					continue
-				}
-				if err := peer.Send(msg); err != nil {
+				if err := s.sendBlock(peer, block); err != nil {
 					log.Println("Error sending block to peer:", err)
+					break
 				}
 			}
-		// NOTE: Handshake logic is now handled directly in initiate/respond handshake funcs
-		// and is no longer processed via the message channel.
+		case MessageTypeGetHeaders:
+			var payload GetHeadersPayload
+			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
+				log.Printf("P2P: Error decoding GetHeadersPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed GetHeadersPayload")
+				continue
+			}
+			s.handleGetHeaders(rpc.From, &payload)
+
+		case MessageTypeHeaders:
+			var payload HeadersPayload
+			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
+				log.Printf("P2P: Error decoding HeadersPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed HeadersPayload")
+				continue
+			}
+			s.handleHeaders(rpc.From, &payload)
+
+		case MessageTypeGetAddr:
+			s.handleGetAddr(rpc.From)
+
+		case MessageTypeAddr:
+			var payload AddrPayload
+			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
+				log.Printf("P2P: Error decoding AddrPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed AddrPayload")
+				continue
+			}
+			s.handleAddr(rpc.From, &payload)
+
+		case MessageTypeStatus:
+			var payload StatusPayload
+			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
+				log.Printf("P2P: Error decoding StatusPayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed StatusPayload")
+				continue
+			}
+			s.handleStatus(rpc.From, &payload)
+
+		// Pertukaran handshake dilakukan di initiate/respond handshake; pesan ini
+		// hanya diantrekan setelahnya agar sinkronisasi berjalan di goroutine ini.
+		case MessageTypeHandshake:
+			var payload HandshakePayload
+			if err := gob.NewDecoder(bytes.NewReader(rpc.Payload)).Decode(&payload); err != nil {
+				log.Printf("P2P: Error decoding HandshakePayload from %s: %v", rpc.From, err)
+				s.misbehaving(rpc.From, scoreMalformedMessage, "malformed HandshakePayload")
+				continue
+			}
+			peer, ok := s.getPeer(rpc.From)
+			if !ok {
+				continue
+			}
+			if err := s.handleHandshake(peer, &payload); err != nil {
+				log.Printf("P2P: Error syncing with %s after handshake: %v", rpc.From, err)
+			}
 		}
 	}
 }
 
-// BroadcastBlock mengirimkan block ke semua peer.
+// connectBlock menambahkan block ke blockchain, lalu mengumumkannya ke peer
+// lain. Mengembalikan hash block yang ter-connect, termasuk block orphan yang
+// ikut ter-connect.
+func (s *Server) connectBlock(b *core.Block, from net.Addr) []crypto.Hash {
+	blockHash, _ := b.Hash()
+	// Block history di bawah snapshot UTXO tidak perlu diteruskan ke peer lain
+	isHistory := s.blockchain.NeedsHistory() && b.Header.Height <= s.blockchain.PrunedHeight()
+	connected, err := s.blockchain.ProcessBlock(b)
+	if errors.Is(err, core.ErrOrphanBlock) {
+		s.handleOrphan(b, from)
+		return nil
+	}
+	if err != nil {
+		// This error is now critical for debugging sync issues.
+		log.Printf("P2P: Failed to add block %s from %s: %v", blockHash.ToHex(), from, err)
+		if _, invalid, _ := s.blockchain.InvalidReason(blockHash); invalid {
+			s.misbehaving(from, scoreInvalidBlock, "invalid block "+blockHash.ToHex())
+		}
+		return nil
+	}
+	s.markUseful(from, true)
+	hashes := []crypto.Hash{blockHash}
+	if isHistory {
+		return hashes
+	}
+	s.removeBlockTxs(b)
+
+	// Block orphan yang menunggu block ini ikut di-connect
+	for _, orphan := range connected {
+		orphanHash, _ := orphan.Hash()
+		log.Printf("P2P: Connected orphan block %s (height %d)", orphanHash.ToHex(), orphan.Header.Height)
+		s.removeBlockTxs(orphan)
+		hashes = append(hashes, orphanHash)
+	}
+	// Umumkan ke peer lain yang belum mengetahuinya
+	s.announce(InvTypeBlock, hashes)
+	return hashes
+}
+
+// removeBlockTxs menghapus transaksi dari mempool yang sudah masuk block.
+func (s *Server) removeBlockTxs(b *core.Block) {
+	for _, tx := range b.Transactions {
+		txHash, _ := tx.Hash()
+		s.mempool.Remove(txHash)
+	}
+}
+
+// handleOrphan meminta header ancestor yang hilang dari peer pengirim block
+// orphan; body-nya diminta setelah header tervalidasi. Permintaan hanya dikirim
+// untuk orphan pertama dari sebuah rantai; block berikutnya yang parent-nya
+// juga orphan akan ikut terhubung setelah ancestor tiba.
+func (s *Server) handleOrphan(b *core.Block, from net.Addr) {
+	blockHash, _ := b.Hash()
+	missing := s.blockchain.OrphanRoot(blockHash)
+	count, size := s.blockchain.OrphanCount()
+	log.Printf("P2P: Block %s (height %d) from %s is an orphan, missing ancestor %s (%d orphans, %d bytes)", blockHash.ToHex(), b.Header.Height, from, missing.ToHex(), count, size)
+	if s.blockchain.IsOrphan(b.Header.PrevHash) {
+		return
+	}
+
+	s.lock.RLock()
+	peer, ok := s.peers[from]
+	s.lock.RUnlock()
+	if !ok {
+		log.Println("Sender peer not found:", from)
+		return
+	}
+	if err := s.requestHeaders(peer); err != nil {
+		log.Printf("P2P: Error requesting missing ancestors from %s: %v", from, err)
+	}
+}
+
+// BroadcastBlock mengumumkan block ke semua peer yang belum mengetahuinya,
+// lalu mengirim status tip baru kita. Peer yang membutuhkannya akan meminta
+// body block lewat getdata.
 func (s *Server) BroadcastBlock(b *core.Block) error {
-	payload := BlockPayload{Block: b}
+	blockHash, err := b.Hash()
+	if err != nil {
+		return err
+	}
+	s.announce(InvTypeBlock, []crypto.Hash{blockHash})
+	s.broadcastStatus()
+	return nil
+}
+
+// getPeer mengembalikan peer yang terhubung dari alamatnya.
+func (s *Server) getPeer(addr net.Addr) (*Peer, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	peer, ok := s.peers[addr]
+	return peer, ok
+}
+
+// peerList mengembalikan salinan daftar peer yang terhubung, sehingga pesan
+// bisa dikirim tanpa memegang s.lock.
+func (s *Server) peerList() []*Peer {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	peers := make([]*Peer, 0, len(s.peers))
+	for _, peer := range s.peers {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// sendPayload meng-encode payload dan mengirimnya ke peer.
+func sendPayload(peer *Peer, msgType MessageType, payload interface{}) error {
 	buf := new(bytes.Buffer)
 	if err := gob.NewEncoder(buf).Encode(payload); err != nil {
 		return err
 	}
+	return peer.Send(&Message{Type: msgType, Payload: buf.Bytes()})
+}
 
-	msg := &Message{
-		Type:    MessageTypeBlock,
-		Payload: buf.Bytes(),
+// sendBlock mengirim body block ke peer dan mencatatnya sebagai diketahui peer.
+func (s *Server) sendBlock(peer *Peer, b *core.Block) error {
+	blockHash, err := b.Hash()
+	if err != nil {
+		return err
 	}
+	peer.knownInv.Add(InvTypeBlock, blockHash)
+	return sendPayload(peer, MessageTypeBlock, BlockPayload{Block: b})
+}
 
-	return s.broadcast(msg.Payload, msg.Type, nil)
+// announce mengirim inv ke setiap peer berisi hash yang belum diketahuinya.
+// Hash yang diumumkan dicatat sebagai diketahui peer agar tidak diulang.
+func (s *Server) announce(invType byte, hashes []crypto.Hash) {
+	for _, peer := range s.peerList() {
+		var unknown []crypto.Hash
+		for _, hash := range hashes {
+			if peer.knownInv.Add(invType, hash) {
+				unknown = append(unknown, hash)
+			}
+		}
+		if len(unknown) == 0 {
+			continue
+		}
+		if err := sendPayload(peer, MessageTypeInv, InvPayload{Type: invType, Hashes: unknown}); err != nil {
+			// Mungkin peer sudah disconnect, cukup di-log
+			log.Printf("P2P: Error announcing inventory to %s: %v", peer.conn.RemoteAddr(), err)
+		}
+	}
 }
 
-// broadcast mengirim pesan ke semua peer kecuali excludeAddr.
-func (s *Server) broadcast(payload []byte, msgType MessageType, excludeAddr net.Addr) error {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+// handleInv meminta lewat getdata inventory yang diumumkan peer tetapi belum kita miliki.
+func (s *Server) handleInv(from net.Addr, payload *InvPayload) {
+	peer, ok := s.getPeer(from)
+	if !ok {
+		return
+	}
+	if len(payload.Hashes) > MaxInvHashes {
+		log.Printf("P2P: Ignoring inv with %d hashes from %s (maximum %d)", len(payload.Hashes), from, MaxInvHashes)
+		s.misbehaving(from, scoreMalformedMessage, "oversized inv")
+		return
+	}
 
-	for addr, peer := range s.peers {
-		if addr == excludeAddr {
-			continue
+	var wanted []crypto.Hash
+	for _, hash := range payload.Hashes {
+		peer.knownInv.Add(payload.Type, hash)
+		switch payload.Type {
+		case InvTypeBlock:
+			if s.blockchain.KnowsBlock(hash) {
+				continue
+			}
+		case InvTypeTx:
+			if s.mempool.Contains(hash) {
+				continue
+			}
+		default:
+			log.Printf("P2P: Unknown inventory type %q from %s", payload.Type, from)
+			s.misbehaving(from, scoreMalformedMessage, "unknown inventory type")
+			return
 		}
-		msg := &Message{
-			Type:    msgType,
-			Payload: payload,
+		wanted = append(wanted, hash)
+	}
+	if len(wanted) == 0 {
+		return
+	}
+	for _, hash := range wanted {
+		peer.requested.Add(payload.Type, hash)
+	}
+	peer.expectReplies(len(wanted))
+	if err := sendPayload(peer, MessageTypeGetData, GetDataPayload{Type: payload.Type, Hashes: wanted}); err != nil {
+		log.Printf("P2P: Error requesting data from %s: %v", from, err)
+	}
+}
+
+// handleGetData melayani permintaan getdata dari BlockStore atau mempool.
+// Hash yang tidak kita miliki dilewati.
+func (s *Server) handleGetData(from net.Addr, payload *GetDataPayload) {
+	peer, ok := s.getPeer(from)
+	if !ok {
+		return
+	}
+	if len(payload.Hashes) > MaxInvHashes {
+		log.Printf("P2P: Ignoring getdata with %d hashes from %s (maximum %d)", len(payload.Hashes), from, MaxInvHashes)
+		s.misbehaving(from, scoreMalformedMessage, "oversized getdata")
+		return
+	}
+
+	for _, hash := range payload.Hashes {
+		var err error
+		switch payload.Type {
+		case InvTypeBlock:
+			var block *core.Block
+			if block, err = s.blockchain.GetBlockByHash(hash); err != nil {
+				continue
+			}
+			err = s.sendBlock(peer, block)
+		case InvTypeTx:
+			var tx *core.Transaction
+			if tx, err = s.mempool.Get(hash); err != nil {
+				continue
+			}
+			peer.knownInv.Add(InvTypeTx, hash)
+			err = sendPayload(peer, MessageTypeTx, TxPayload{Tx: tx})
+		default:
+			log.Printf("P2P: Unknown getdata type %q from %s", payload.Type, from)
+			s.misbehaving(from, scoreMalformedMessage, "unknown getdata type")
+			return
 		}
-		if err := peer.Send(msg); err != nil {
-			// Mungkin peer sudah disconnect, bisa diabaikan atau di-log
+		if err != nil {
+			log.Printf("P2P: Error sending %s to %s: %v", hash.ToHex(), from, err)
+			return
 		}
 	}
-	return nil
 }
 
-// Connect mencoba terhubung ke peer lain.
+// Connect mencoba terhubung ke peer lain. Alamat yang sudah terhubung atau
+// sedang dihubungi ditolak.
 func (s *Server) Connect(addr string) error {
-	conn, err := net.Dial("tcp", addr)
+	_, err := s.connect(addr, false)
+	return err
+}
+
+// connect membuka koneksi outbound dan melakukan handshake. Peer persistent
+// adalah peer addnode yang tidak dihitung dalam slot outbound.
+func (s *Server) connect(addr string, persistent bool) (*Peer, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if _, banned := s.banList.IsBanned(host, time.Now()); banned {
+			return nil, fmt.Errorf("%s is banned", host)
+		}
+	}
+	s.lock.Lock()
+	if s.connectedAddrs()[addr] {
+		s.lock.Unlock()
+		return nil, fmt.Errorf("already connected to %s", addr)
+	}
+	s.dialing[addr] = persistent
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.dialing, addr)
+		s.lock.Unlock()
+	}()
+
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	peer := NewPeer(conn)
+	peer := NewPeer(conn, s.magic)
+	peer.outbound = true
+	peer.persistent = persistent
+	peer.addr = addr
 
 	s.lock.Lock()
 	s.peers[conn.RemoteAddr()] = peer
@@ -408,27 +968,22 @@ func (s *Server) Connect(addr string) error {
 	// Lakukan handshake sebagai inisiator
 	if err := s.initiateHandshake(peer); err != nil {
 		log.Printf("Handshake gagal dengan %s: %v", conn.RemoteAddr(), err)
-		conn.Close()
+		peer.close()
 		s.lock.Lock()
 		delete(s.peers, conn.RemoteAddr())
 		s.lock.Unlock()
-		return err
+		return nil, err
 	}
 
 	go s.readLoop(peer)
 
-	return nil
+	return peer, nil
 }
 
 // initiateHandshake memulai proses handshake dengan peer (sebagai inisiator).
 func (s *Server) initiateHandshake(peer *Peer) error {
 	// Kirim handshake kita
-	myHandshake := HandshakePayload{
-		Version:    "swatantra-0.1",
-		Height:     s.blockchain.Head().Height,
-		HeadHash:   s.blockchain.Head().Hash(),
-		ListenAddr: s.listenAddr,
-	}
+	myHandshake := s.newHandshake()
 	buf := new(bytes.Buffer)
 	if err := gob.NewEncoder(buf).Encode(myHandshake); err != nil {
 		return err
@@ -442,10 +997,17 @@ func (s *Server) initiateHandshake(peer *Peer) error {
 	}
 
 	// Terima handshake dari peer
-	responseMsg := &Message{}
-	if err := peer.decoder.Decode(responseMsg); err != nil {
+	peer.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	responseMsg, err := peer.Receive()
+	if err != nil {
 		return err
 	}
+	if responseMsg.Type == MessageTypeDisconnect {
+		if logDisconnect(peer, responseMsg) == errSelfConnection.Error() {
+			return errSelfConnection
+		}
+		return errors.New("peer refused handshake")
+	}
 	if responseMsg.Type != MessageTypeHandshake {
 		return errors.New("expected handshake message")
 	}
@@ -456,58 +1018,97 @@ func (s *Server) initiateHandshake(peer *Peer) error {
 
 	log.Printf("Handshake berhasil dengan %s (version: %s, height: %d)", peer.conn.RemoteAddr(), peerHandshake.Version, peerHandshake.Height)
 
-	// Setelah bertukar handshake, tangani perbandingan chain
-	return s.handleHandshake(peer, &peerHandshake)
+	// Setelah bertukar handshake, sinkronisasi ditangani ProcessMessages
+	s.queueHandshake(peer, responseMsg.Payload)
+	return nil
 }
 
-// handleHandshake contains the logic for comparing chain heights and syncing.
-func (s *Server) handleHandshake(peer *Peer, payload *HandshakePayload) error {
-	// Bandingkan tinggi chain
-	if payload.Height > s.blockchain.Head().Height {
-		// Peer memiliki chain yang lebih panjang, minta block dari mereka
-		log.Printf("P2P: Peer %s has a longer chain (height %d > our %d). Requesting blocks.", peer.conn.RemoteAddr(), payload.Height, s.blockchain.Head().Height)
-
-		getBlocksPayload := GetBlocksPayload{
-			// Minta block mulai dari block teratas yang kita punya
-			From: s.blockchain.Head().Hash(),
-		}
-		buf := new(bytes.Buffer)
-		if err := gob.NewEncoder(buf).Encode(getBlocksPayload); err != nil {
-			return err
-		}
-		msg := &Message{
-			Type:    MessageTypeGetBlocks,
-			Payload: buf.Bytes(),
-		}
-		return peer.Send(msg)
+// requestHistory meminta block history mulai dari genesis ke peer yang tidak
+// di-prune. Peer membalas paling banyak MaxBlocksPerMessage block; halaman
+// berikutnya diminta oleh continueHistory.
+func (s *Server) requestHistory(peer *Peer) error {
+	genesisHash, err := s.blockchain.GetHashByHeight(0)
+	if err != nil {
+		return err
+	}
+	log.Printf("P2P: Requesting block history below the UTXO snapshot from %s.", peer.conn.RemoteAddr())
+	return s.requestHistoryFrom(peer, genesisHash, 0)
+}
 
-	} else if payload.Height < s.blockchain.Head().Height {
-		// Kita memiliki chain yang lebih panjang, kirim block kita ke peer
-		log.Printf("P2P: Our chain is longer (height %d > peer %d). Sending blocks to %s.", s.blockchain.Head().Height, payload.Height, peer.conn.RemoteAddr())
+// requestHistoryFrom meminta satu halaman block history mulai dari hash di height.
+func (s *Server) requestHistoryFrom(peer *Peer, from crypto.Hash, height uint32) error {
+	peer.historyRequested = true
+	peer.historyEnd = height + MaxBlocksPerMessage - 1
+	peer.expectReplies(MaxBlocksPerMessage)
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(GetBlocksPayload{From: from}); err != nil {
+		return err
+	}
+	return peer.Send(&Message{Type: MessageTypeGetBlocks, Payload: buf.Bytes()})
+}
 
-		blocksToSend, err := s.blockchain.GetBlocksFrom(payload.HeadHash)
-		if err != nil {
-			return err
-		}
+// continueHistory meminta halaman history berikutnya setelah block terakhir
+// halaman sebelumnya tiba, selama block di bawah base snapshot masih kurang.
+func (s *Server) continueHistory(peer *Peer, b *core.Block) {
+	if !peer.historyRequested || b.Header.Height != peer.historyEnd {
+		return
+	}
+	if !s.blockchain.NeedsHistory() || b.Header.Height >= s.blockchain.PrunedHeight() {
+		return
+	}
+	blockHash, _ := b.Hash()
+	if err := s.requestHistoryFrom(peer, blockHash, b.Header.Height); err != nil {
+		log.Printf("P2P: Error requesting more block history from %s: %v", peer.conn.RemoteAddr(), err)
+	}
+}
 
-		log.Printf("P2P: Found %d blocks to send to %s", len(blocksToSend), peer.conn.RemoteAddr())
+// newHandshake membuat payload handshake dari state chain kita saat ini.
+func (s *Server) newHandshake() HandshakePayload {
+	head := s.blockchain.Head()
+	return HandshakePayload{
+		Version:        "swatantra-0.1",
+		Height:         head.Height,
+		HeadHash:       head.Hash(),
+		CumulativeWork: head.CumulativeWork,
+		ListenAddr:     s.listenAddr,
+		Pruned:         s.blockchain.IsPruned(),
+		PrunedHeight:   s.blockchain.PrunedHeight(),
+		Nonce:          s.nonce,
+	}
+}
 
-		for _, block := range blocksToSend {
-			blockPayload := BlockPayload{Block: block}
-			buf := new(bytes.Buffer)
-			if err := gob.NewEncoder(buf).Encode(blockPayload); err != nil {
-				return err
-			}
-			msg := &Message{
-					Type:    MessageTypeBlock,
-					Payload: buf.Bytes(),
-				}
-			if err := peer.Send(msg); err != nil {
-				blockHash, _ := block.Hash() // Safely get hash for logging
-				return fmt.Errorf("error sending block %s to peer %s: %v", blockHash.ToHex(), peer.conn.RemoteAddr(), err)
-			}
+// queueHandshake mengantrekan handshake peer ke ProcessMessages.
+func (s *Server) queueHandshake(peer *Peer, payload []byte) {
+	s.msgCh <- &RPC{
+		From:    peer.conn.RemoteAddr(),
+		Payload: payload,
+		Type:    MessageTypeHandshake,
+	}
+}
+
+// handleHandshake memulai sinkronisasi headers-first dengan peer jika peer
+// mengklaim cumulative work lebih besar dari best header kita. Height tidak
+// dipakai karena fork yang lebih panjang bisa saja memiliki work lebih kecil.
+// Klaim tersebut hanya memicu getheaders; tip peer baru dicatat setelah
+// header-nya tervalidasi.
+func (s *Server) handleHandshake(peer *Peer, payload *HandshakePayload) error {
+	s.registerPeerAddr(peer, payload.ListenAddr)
+	if s.blockchain.NeedsHistory() && !payload.Pruned {
+		// Kita dimulai dari snapshot UTXO, minta history dari genesis untuk validasi di background
+		if err := s.requestHistory(peer); err != nil {
+			return err
 		}
 	}
-	// If heights are equal, do nothing.
-	return nil
-}
\ No newline at end of file
+
+	if payload.Pruned && s.blockchain.Head().Height < payload.PrunedHeight {
+		// Peer sudah menghapus block yang kita butuhkan, jangan sinkronisasi darinya
+		log.Printf("P2P: Peer %s is pruned up to height %d, cannot sync from our height %d.", peer.conn.RemoteAddr(), payload.PrunedHeight, s.blockchain.Head().Height)
+		return nil
+	}
+	s.updatePeerTip(peer, payload.HeadHash)
+	if !s.hasMoreWork(payload.CumulativeWork) {
+		// Peer akan meminta header dari kita jika chain kita yang lebih baik
+		return nil
+	}
+	return s.requestHeaders(peer)
+}
@@ -0,0 +1,238 @@
+package p2p
+
+import (
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"time"
+
+	"swatantra/core"
+	"swatantra/crypto"
+)
+
+const (
+	// MaxHeadersPerMessage adalah jumlah header maksimum dalam satu pesan headers.
+	MaxHeadersPerMessage = 2000
+
+	// MaxBlocksPerMessage adalah jumlah block maksimum yang dikirim sebagai
+	// balasan satu getblocks. Harus di bawah kapasitas antrean kirim block.
+	MaxBlocksPerMessage = 500
+)
+
+// requestHeaders meminta header setelah best header kita ke peer.
+func (s *Server) requestHeaders(peer *Peer) error {
+	peer.headersRequested++
+	peer.expectReplies(1)
+	return sendPayload(peer, MessageTypeGetHeaders, GetHeadersPayload{Locator: s.blockchain.HeaderLocator()})
+}
+
+// handleGetHeaders membalas getheaders dengan header main chain kita.
+func (s *Server) handleGetHeaders(from net.Addr, payload *GetHeadersPayload) {
+	peer, ok := s.getPeer(from)
+	if !ok {
+		return
+	}
+	headers, err := s.blockchain.GetHeadersFrom(payload.Locator, payload.Stop, MaxHeadersPerMessage)
+	if err != nil {
+		log.Printf("P2P: Error getting headers for %s: %v", from, err)
+		return
+	}
+	if len(headers) == 0 {
+		return
+	}
+	log.Printf("P2P: Sending %d headers to %s", len(headers), from)
+	if err := sendPayload(peer, MessageTypeHeaders, HeadersPayload{Headers: headers}); err != nil {
+		log.Printf("P2P: Error sending headers to %s: %v", from, err)
+	}
+}
+
+// handleHeaders memvalidasi header dari peer, meminta lanjutan jika pesan
+// penuh, lalu menjadwalkan pengunduhan body block yang belum kita miliki.
+func (s *Server) handleHeaders(from net.Addr, payload *HeadersPayload) {
+	peer, ok := s.getPeer(from)
+	if !ok {
+		return
+	}
+	if len(payload.Headers) > MaxHeadersPerMessage {
+		log.Printf("P2P: Ignoring %d headers from %s (maximum %d)", len(payload.Headers), from, MaxHeadersPerMessage)
+		s.misbehaving(from, scoreMalformedMessage, "oversized headers")
+		return
+	}
+	if peer.headersRequested > 0 {
+		peer.headersRequested--
+	} else {
+		s.misbehaving(from, scoreUnsolicited, "unrequested headers")
+	}
+	added, err := s.blockchain.ProcessHeaders(payload.Headers)
+	if errors.Is(err, core.ErrUnconnectedHeader) {
+		// Peer mengirim header di luar permintaan kita, minta ulang dari locator kita
+		log.Printf("P2P: Headers from %s do not connect, requesting from our locator", from)
+		if err := s.requestHeaders(peer); err != nil {
+			log.Printf("P2P: Error requesting headers from %s: %v", from, err)
+		}
+		return
+	}
+	if errors.Is(err, core.ErrFutureBlock) {
+		// Bisa terjadi karena perbedaan jam, bukan pelanggaran
+		log.Printf("P2P: Headers from %s are too far in the future: %v", from, err)
+		return
+	}
+	if err != nil {
+		log.Printf("P2P: Invalid headers from %s: %v", from, err)
+		s.misbehaving(from, scoreInvalidBlock, "invalid headers: "+err.Error())
+		return
+	}
+	if len(payload.Headers) > 0 {
+		s.updatePeerTip(peer, payload.Headers[len(payload.Headers)-1].Hash())
+	}
+	best := s.blockchain.BestHeader()
+	log.Printf("P2P: Received %d headers (%d new) from %s, best header at height %d", len(payload.Headers), added, from, best.Height)
+
+	if len(payload.Headers) == MaxHeadersPerMessage {
+		if err := s.requestHeaders(peer); err != nil {
+			log.Printf("P2P: Error requesting headers from %s: %v", from, err)
+		}
+	}
+	s.scheduleDownloads()
+}
+
+// scheduleDownloads meminta body block berikutnya di chain best header ke
+// peer yang memilikinya, sesuai window dan batas permintaan per peer.
+func (s *Server) scheduleDownloads() {
+	best := s.blockchain.BestHeader()
+	if bestHash := best.Hash(); s.downloads.needsChain(bestHash) {
+		chain, err := s.blockchain.MissingBlocks()
+		if err != nil {
+			log.Printf("P2P: Error finding missing blocks: %v", err)
+			return
+		}
+		s.downloads.setChain(bestHash, chain)
+	}
+
+	s.lock.RLock()
+	peers := make([]downloadPeer, 0, len(s.peers))
+	for addr, peer := range s.peers {
+		peers = append(peers, downloadPeer{addr: addr, height: peer.bestHeight})
+	}
+	s.lock.RUnlock()
+
+	for addr, hashes := range s.downloads.schedule(peers, s.blockchain.HasBlock, time.Now()) {
+		peer, ok := s.getPeer(addr)
+		if !ok {
+			continue
+		}
+		for _, hash := range hashes {
+			peer.requested.Add(InvTypeBlock, hash)
+		}
+		peer.expectReplies(len(hashes))
+		if err := sendPayload(peer, MessageTypeGetData, GetDataPayload{Type: InvTypeBlock, Hashes: hashes}); err != nil {
+			log.Printf("P2P: Error requesting blocks from %s: %v", addr, err)
+		}
+	}
+}
+
+// checkStalledDownloads mengalihkan permintaan block yang tidak dibalas
+// dalam blockStallTimeout ke peer lain.
+func (s *Server) checkStalledDownloads() {
+	expired := s.downloads.expire(time.Now())
+	for hash, addr := range expired {
+		log.Printf("P2P: Block %s requested from %s stalled, reassigning", hash.ToHex(), addr)
+	}
+	s.scheduleDownloads()
+}
+
+// handleBlock memproses block dari peer. Block yang kita minta tetapi
+// parent-nya belum ter-connect ditahan hingga parent tersebut tiba, sehingga
+// block dari beberapa peer tetap di-connect secara berurutan.
+func (s *Server) handleBlock(b *core.Block, from net.Addr) {
+	blockHash, _ := b.Hash()
+	log.Printf("P2P: Received Block %s (height %d) from %s", blockHash.ToHex(), b.Header.Height, from)
+	peer, ok := s.getPeer(from)
+	if ok {
+		peer.knownInv.Add(InvTypeBlock, blockHash)
+	}
+	head := s.blockchain.Head().Hash()
+
+	requested := s.downloads.received(blockHash)
+	if ok && !requested && !peer.requested.Has(InvTypeBlock, blockHash) && !peer.historyRequested {
+		s.misbehaving(from, scoreUnsolicited, "unrequested block "+blockHash.ToHex())
+	}
+	if requested && !s.blockchain.HasBlock(b.Header.PrevHash) {
+		s.downloads.buffer(b, from)
+	} else {
+		queue := []*bufferedBlock{{block: b, from: from}}
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+			for _, hash := range s.connectBlock(next.block, next.from) {
+				if child := s.downloads.takeChild(hash); child != nil {
+					queue = append(queue, child)
+				}
+			}
+		}
+	}
+	if ok {
+		s.updatePeerTip(peer, blockHash)
+		s.continueHistory(peer, b)
+	}
+	if s.blockchain.Head().Hash() != head {
+		s.broadcastStatus()
+	}
+	if requested {
+		s.scheduleDownloads()
+	}
+}
+
+// updatePeerTip mencatat block sebagai tip peer berdasarkan cumulative work
+// header yang sudah kita validasi.
+func (s *Server) updatePeerTip(peer *Peer, hash crypto.Hash) {
+	header, err := s.blockchain.GetHeader(hash)
+	if err != nil || header.CumulativeWork == nil {
+		return
+	}
+	peer.updateTip(header.Height, hash, header.CumulativeWork)
+}
+
+// hasMoreWork memeriksa apakah work lebih besar dari cumulative work best
+// header kita.
+func (s *Server) hasMoreWork(work *big.Int) bool {
+	return work != nil && work.Cmp(s.blockchain.BestHeader().CumulativeWork) > 0
+}
+
+// broadcastStatus mengirim tip main chain kita ke semua peer.
+func (s *Server) broadcastStatus() {
+	head := s.blockchain.Head()
+	status := StatusPayload{Height: head.Height, HeadHash: head.Hash(), CumulativeWork: head.CumulativeWork}
+	for _, peer := range s.peerList() {
+		if err := sendPayload(peer, MessageTypeStatus, status); err != nil {
+			log.Printf("P2P: Error sending status to %s: %v", peer.conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// handleStatus meminta header jika peer mengklaim chain dengan work lebih
+// besar. Klaim height dan work tidak dicatat sebagai tip peer; tip baru dicatat
+// setelah header-nya tervalidasi oleh ProcessHeaders. Tip yang sudah diumumkan
+// lewat inv diminta lewat getdata, sehingga header-nya tidak perlu diminta lagi.
+func (s *Server) handleStatus(from net.Addr, payload *StatusPayload) {
+	peer, ok := s.getPeer(from)
+	if !ok {
+		return
+	}
+	s.updatePeerTip(peer, payload.HeadHash)
+	if !s.hasMoreWork(payload.CumulativeWork) || peer.knownInv.Has(InvTypeBlock, payload.HeadHash) {
+		return
+	}
+	if peer.headersRequested > 0 {
+		// Klaim dibuktikan oleh balasan getheaders yang masih ditunggu
+		return
+	}
+	if _, err := s.blockchain.GetHeader(payload.HeadHash); err == nil {
+		return
+	}
+	log.Printf("P2P: Peer %s has more work at height %d, requesting headers", from, payload.Height)
+	if err := s.requestHeaders(peer); err != nil {
+		log.Printf("P2P: Error requesting headers from %s: %v", from, err)
+	}
+}
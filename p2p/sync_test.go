@@ -0,0 +1,77 @@
+package p2p
+
+import (
+	"math/big"
+	"net"
+	"testing"
+
+	"swatantra/core"
+	"swatantra/crypto"
+	"swatantra/mempool"
+	"swatantra/storage"
+)
+
+func TestPeerTipFollowsWork(t *testing.T) {
+	peer := &Peer{}
+	peer.updateTip(10, crypto.Hash{1}, big.NewInt(500))
+	if peer.bestHeight != 10 || peer.bestHash != (crypto.Hash{1}) {
+		t.Fatalf("First tip should be recorded, got height %d", peer.bestHeight)
+	}
+
+	// Fork yang lebih panjang tetapi dengan work lebih kecil tidak menggantikan tip.
+	peer.updateTip(20, crypto.Hash{2}, big.NewInt(400))
+	if peer.bestHash != (crypto.Hash{1}) {
+		t.Error("Longer tip with less work should not replace the known tip")
+	}
+	peer.updateTip(10, crypto.Hash{3}, big.NewInt(500))
+	if peer.bestHash != (crypto.Hash{1}) {
+		t.Error("Tip with equal work should not replace the known tip")
+	}
+
+	peer.updateTip(8, crypto.Hash{4}, big.NewInt(600))
+	if peer.bestHeight != 8 || peer.bestHash != (crypto.Hash{4}) {
+		t.Errorf("Shorter tip with more work should replace the known tip, got height %d", peer.bestHeight)
+	}
+	peer.updateTip(30, crypto.Hash{5}, nil)
+	if peer.bestHash != (crypto.Hash{4}) {
+		t.Error("Tip without work should be ignored")
+	}
+}
+
+func TestClaimedWorkNeedsValidatedHeaders(t *testing.T) {
+	store, err := storage.NewLevelDBStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLevelDBStore failed: %v", err)
+	}
+	bc, err := core.NewBlockchain(store, 10)
+	if err != nil {
+		t.Fatalf("NewBlockchain failed: %v", err)
+	}
+	s := NewServerWithOptions("127.0.0.1:0", bc, mempool.NewMempool(bc, 100), Options{Magic: MagicRegtest})
+	local, remote := net.Pipe()
+	defer remote.Close()
+	peer := NewPeer(local, MagicRegtest)
+	defer peer.close()
+	s.peers[local.RemoteAddr()] = peer
+
+	// Klaim work yang besar memicu getheaders, tetapi tidak dicatat sebagai tip peer.
+	status := &StatusPayload{Height: 1000, HeadHash: crypto.Hash{9}, CumulativeWork: new(big.Int).Lsh(big.NewInt(1), 200)}
+	s.handleStatus(local.RemoteAddr(), status)
+	if peer.bestWork != nil || peer.bestHeight != 0 {
+		t.Errorf("Unvalidated tip was recorded: height %d, work %v", peer.bestHeight, peer.bestWork)
+	}
+	if peer.headersRequested != 1 {
+		t.Fatalf("Claim of more work should request headers, got %d requests", peer.headersRequested)
+	}
+	s.handleStatus(local.RemoteAddr(), status)
+	if peer.headersRequested != 1 {
+		t.Errorf("Repeated claim should wait for the outstanding getheaders, got %d requests", peer.headersRequested)
+	}
+
+	// Tip yang header-nya sudah tervalidasi dicatat dari header kita sendiri.
+	genesis := bc.Head()
+	s.handleStatus(local.RemoteAddr(), &StatusPayload{Height: 1000, HeadHash: genesis.Hash(), CumulativeWork: status.CumulativeWork})
+	if peer.bestHash != genesis.Hash() || peer.bestWork.Cmp(genesis.CumulativeWork) != 0 {
+		t.Errorf("Validated tip should use our header's work, got %v", peer.bestWork)
+	}
+}
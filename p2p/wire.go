@@ -0,0 +1,101 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"swatantra/crypto"
+)
+
+// Format frame (big endian):
+//
+//	magic (4) | version (1) | command (1) | panjang payload (4) | checksum (4) | payload
+//
+// Checksum adalah 4 byte pertama Keccak256 dari payload.
+const (
+	FrameVersion    byte = 1
+	frameHeaderSize      = 4 + 1 + 1 + 4 + 4
+
+	// MaxPayloadSize adalah batas keras ukuran payload satu frame.
+	MaxPayloadSize = 8 << 20
+)
+
+// Magic membedakan jaringan; node hanya menerima frame dengan magic yang sama.
+type Magic [4]byte
+
+var (
+	MagicMainnet = Magic{0x53, 0x57, 0x54, 0x01}
+	MagicTestnet = Magic{0x53, 0x57, 0x54, 0x02}
+	MagicRegtest = Magic{0x53, 0x57, 0x54, 0x03}
+)
+
+// NetworkMagic mengembalikan magic untuk nama jaringan. Nama kosong berarti mainnet.
+func NetworkMagic(network string) (Magic, error) {
+	switch network {
+	case "", "mainnet":
+		return MagicMainnet, nil
+	case "testnet":
+		return MagicTestnet, nil
+	case "regtest":
+		return MagicRegtest, nil
+	}
+	return Magic{}, fmt.Errorf("unknown network %q", network)
+}
+
+// ErrInvalidFrame dikembalikan untuk frame yang rusak, terlalu besar atau
+// berasal dari jaringan lain. Koneksi peer harus diputus.
+var ErrInvalidFrame = errors.New("invalid frame")
+
+func frameChecksum(payload []byte) [4]byte {
+	var sum [4]byte
+	hash := crypto.Keccak256(payload)
+	copy(sum[:], hash[:4])
+	return sum
+}
+
+// writeFrame menulis pesan sebagai satu frame ke w.
+func writeFrame(w io.Writer, magic Magic, msg *Message) error {
+	if len(msg.Payload) > MaxPayloadSize {
+		return fmt.Errorf("payload of %d bytes exceeds maximum of %d", len(msg.Payload), MaxPayloadSize)
+	}
+	frame := make([]byte, frameHeaderSize+len(msg.Payload))
+	copy(frame[0:4], magic[:])
+	frame[4] = FrameVersion
+	frame[5] = byte(msg.Type)
+	binary.BigEndian.PutUint32(frame[6:10], uint32(len(msg.Payload)))
+	sum := frameChecksum(msg.Payload)
+	copy(frame[10:14], sum[:])
+	copy(frame[frameHeaderSize:], msg.Payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame membaca satu frame dari r. Panjang payload diperiksa sebelum
+// payload dibaca, sehingga peer tidak bisa memaksa alokasi besar.
+func readFrame(r io.Reader, magic Magic) (*Message, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[0:4], magic[:]) {
+		return nil, fmt.Errorf("%w: network magic %x, expected %x", ErrInvalidFrame, header[0:4], magic[:])
+	}
+	if header[4] != FrameVersion {
+		return nil, fmt.Errorf("%w: unsupported frame version %d", ErrInvalidFrame, header[4])
+	}
+	length := binary.BigEndian.Uint32(header[6:10])
+	if length > MaxPayloadSize {
+		return nil, fmt.Errorf("%w: payload of %d bytes exceeds maximum of %d", ErrInvalidFrame, length, MaxPayloadSize)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if sum := frameChecksum(payload); !bytes.Equal(sum[:], header[10:14]) {
+		return nil, fmt.Errorf("%w: checksum mismatch for %d byte payload", ErrInvalidFrame, length)
+	}
+	return &Message{Type: MessageType(header[5]), Payload: payload}, nil
+}
@@ -0,0 +1,52 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	msg := &Message{Type: MessageTypeTx, Payload: []byte("payload")}
+	if err := writeFrame(buf, MagicTestnet, msg); err != nil {
+		t.Fatalf("writeFrame failed: %v", err)
+	}
+	got, err := readFrame(bytes.NewReader(buf.Bytes()), MagicTestnet)
+	if err != nil {
+		t.Fatalf("readFrame failed: %v", err)
+	}
+	if got.Type != msg.Type || !bytes.Equal(got.Payload, msg.Payload) {
+		t.Errorf("Frame round trip: got %v %q, expected %v %q", got.Type, got.Payload, msg.Type, msg.Payload)
+	}
+
+	// Frame dari jaringan lain ditolak.
+	if _, err := readFrame(bytes.NewReader(buf.Bytes()), MagicMainnet); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Wrong magic: expected ErrInvalidFrame, got %v", err)
+	}
+
+	// Payload yang rusak gagal checksum.
+	corrupted := append([]byte{}, buf.Bytes()...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if _, err := readFrame(bytes.NewReader(corrupted), MagicTestnet); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Corrupted payload: expected ErrInvalidFrame, got %v", err)
+	}
+}
+
+func TestFrameRejectsOversizedPayload(t *testing.T) {
+	// Hanya header yang dikirim; panjang ditolak sebelum payload dibaca.
+	header := make([]byte, frameHeaderSize)
+	copy(header, MagicMainnet[:])
+	header[4] = FrameVersion
+	header[5] = byte(MessageTypeBlock)
+	binary.BigEndian.PutUint32(header[6:10], MaxPayloadSize+1)
+	if _, err := readFrame(bytes.NewReader(header), MagicMainnet); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Oversized frame: expected ErrInvalidFrame, got %v", err)
+	}
+
+	msg := &Message{Type: MessageTypeBlock, Payload: make([]byte, MaxPayloadSize+1)}
+	if err := writeFrame(new(bytes.Buffer), MagicMainnet, msg); err == nil {
+		t.Error("Expected an error when writing an oversized frame")
+	}
+}
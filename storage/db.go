@@ -1,9 +1,6 @@
 package storage
 
 import (
-	"encoding/hex"
-	"log"
-
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
@@ -18,6 +15,14 @@ type Iterator interface {
 	Close()
 }
 
+// Batch mengumpulkan beberapa penulisan yang diterapkan secara atomik oleh Write.
+type Batch interface {
+	Put([]byte, []byte)
+	Delete([]byte)
+	Len() int
+	Write() error
+}
+
 // Store adalah interface untuk penyimpanan key-value.
 type Store interface {
 	Put([]byte, []byte) error
@@ -26,6 +31,7 @@ type Store interface {
 	Has([]byte) (bool, error)
 	Close() error
 	NewIterator(prefix []byte) Iterator
+	NewBatch() Batch
 }
 
 // LevelDBStore adalah implementasi dari Store menggunakan LevelDB.
@@ -50,18 +56,12 @@ func NewLevelDBStore(path string) (*LevelDBStore, error) {
 
 // Put menyimpan pasangan key-value.
 func (s *LevelDBStore) Put(key, value []byte) error {
-	log.Printf("STORAGE: PUT key=%s", hex.EncodeToString(key))
 	return s.db.Put(key, value, nil)
 }
 
 // Get mengambil nilai berdasarkan key.
 func (s *LevelDBStore) Get(key []byte) ([]byte, error) {
-	log.Printf("STORAGE: GET key=%s", hex.EncodeToString(key))
-	val, err := s.db.Get(key, nil)
-	if err != nil {
-		log.Printf("STORAGE: GET key=%s, err: %v", hex.EncodeToString(key), err)
-	}
-	return val, err
+	return s.db.Get(key, nil)
 }
 
 // Has memeriksa apakah sebuah key ada di dalam database.
@@ -106,3 +106,30 @@ func (s *LevelDBStore) NewIterator(prefix []byte) Iterator {
 		it: s.db.NewIterator(util.BytesPrefix(prefix), nil),
 	}
 }
+
+// levelDBBatch is an implementation of Batch for LevelDB.
+type levelDBBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *levelDBBatch) Put(key, value []byte) {
+	b.batch.Put(key, value)
+}
+
+func (b *levelDBBatch) Delete(key []byte) {
+	b.batch.Delete(key)
+}
+
+func (b *levelDBBatch) Len() int {
+	return b.batch.Len()
+}
+
+func (b *levelDBBatch) Write() error {
+	return b.db.Write(b.batch, nil)
+}
+
+// NewBatch creates a new write batch.
+func (s *LevelDBStore) NewBatch() Batch {
+	return &levelDBBatch{db: s.db, batch: new(leveldb.Batch)}
+}